@@ -0,0 +1,35 @@
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// PulseProAnalysisTemplateSpec defines checks that rollout analyses share by referring to the template
+type PulseProAnalysisTemplateSpec struct {
+	// Metrics are the checks of the template; they run with the metrics of the analysis referring to it
+	// +kubebuilder:validation:MinItems=1
+	Metrics []AnalysisMetric `json:"metrics"`
+}
+
+// +kubebuilder:object:root=true
+
+// PulseProAnalysisTemplate is the Schema for the pulseproanalysistemplates API
+type PulseProAnalysisTemplate struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec PulseProAnalysisTemplateSpec `json:"spec,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// PulseProAnalysisTemplateList contains a list of PulseProAnalysisTemplate
+type PulseProAnalysisTemplateList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []PulseProAnalysisTemplate `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&PulseProAnalysisTemplate{}, &PulseProAnalysisTemplateList{})
+}
@@ -0,0 +1,49 @@
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// PulseProApprovalSpec defines the approval of a single gate on a PulseProRollout
+type PulseProApprovalSpec struct {
+	// RolloutName is the name of the PulseProRollout, in the same namespace, being approved
+	RolloutName string `json:"rolloutName"`
+
+	// Gate is the name of the approval gate being approved
+	Gate string `json:"gate"`
+
+	// Approver is the user that created the approval; it is set by the webhook
+	Approver string `json:"approver,omitempty"`
+
+	// RolloutUID is the UID of the approved rollout; it is set by the webhook, so that the approval does not
+	// count for a later rollout of the same name
+	RolloutUID types.UID `json:"rolloutUID,omitempty"`
+
+	// ImageVersion is the imageVersion of the rollout when it was approved; it is set by the webhook, so that
+	// the approval does not release another version
+	ImageVersion string `json:"imageVersion,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// PulseProApproval is the Schema for the pulseproapprovals API
+type PulseProApproval struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec PulseProApprovalSpec `json:"spec,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// PulseProApprovalList contains a list of PulseProApproval
+type PulseProApprovalList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []PulseProApproval `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&PulseProApproval{}, &PulseProApprovalList{})
+}
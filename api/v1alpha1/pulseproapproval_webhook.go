@@ -0,0 +1,148 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+// log is for logging in this package.
+var pulseproapprovallog = logf.Log.WithName("pulseproapproval-resource")
+
+// SetupWebhookWithManager will setup the manager to manage the webhooks
+func (r *PulseProApproval) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	hook := &PulseProApprovalCustomWebhook{Client: mgr.GetClient()}
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(r).
+		WithDefaulter(hook).
+		WithValidator(hook).
+		Complete()
+}
+
+// PulseProApprovalCustomWebhook records the approver of a PulseProApproval and checks it against the gate's groups
+// +kubebuilder:object:generate=false
+type PulseProApprovalCustomWebhook struct {
+	Client client.Reader
+}
+
+// +kubebuilder:webhook:path=/mutate-pulsepro-pulsepro-io-v1alpha1-pulseproapproval,mutating=true,failurePolicy=fail,sideEffects=None,groups=pulsepro.pulsepro.io,resources=pulseproapprovals,verbs=create;update,versions=v1alpha1,name=mpulseproapproval.kb.io,admissionReviewVersions=v1
+
+var _ webhook.CustomDefaulter = &PulseProApprovalCustomWebhook{}
+
+// Default sets the approver to the requesting user and ties the approval to the UID and image version of the
+// rollout on create, and keeps them unchanged afterwards
+func (w *PulseProApprovalCustomWebhook) Default(ctx context.Context, obj runtime.Object) error {
+	approval, ok := obj.(*PulseProApproval)
+	if !ok {
+		return fmt.Errorf("expected a PulseProApproval but got %T", obj)
+	}
+	pulseproapprovallog.Info("default", "name", approval.Name)
+
+	req, err := admission.RequestFromContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	if len(req.OldObject.Raw) == 0 {
+		approval.Spec.Approver = req.UserInfo.Username
+		approval.Spec.RolloutUID, approval.Spec.ImageVersion = "", ""
+		// A missing rollout is reported by the validating webhook
+		rollout := &PulseProRollout{}
+		key := types.NamespacedName{Name: approval.Spec.RolloutName, Namespace: approval.Namespace}
+		if err := w.Client.Get(ctx, key, rollout); err == nil {
+			approval.Spec.RolloutUID = rollout.UID
+			approval.Spec.ImageVersion = rollout.Spec.ImageVersion
+		}
+		return nil
+	}
+
+	old := &PulseProApproval{}
+	if err := json.Unmarshal(req.OldObject.Raw, old); err != nil {
+		return fmt.Errorf("failed to decode previous PulseProApproval: %v", err)
+	}
+	approval.Spec.Approver = old.Spec.Approver
+	approval.Spec.RolloutUID = old.Spec.RolloutUID
+	approval.Spec.ImageVersion = old.Spec.ImageVersion
+	return nil
+}
+
+// +kubebuilder:webhook:path=/validate-pulsepro-pulsepro-io-v1alpha1-pulseproapproval,mutating=false,failurePolicy=fail,sideEffects=None,groups=pulsepro.pulsepro.io,resources=pulseproapprovals,verbs=create;update,versions=v1alpha1,name=vpulseproapproval.kb.io,admissionReviewVersions=v1
+
+var _ webhook.CustomValidator = &PulseProApprovalCustomWebhook{}
+
+// ValidateCreate checks that the approval is tied to the current rollout and version, that the approved gate
+// exists and that the requesting user may approve it
+func (w *PulseProApprovalCustomWebhook) ValidateCreate(ctx context.Context, obj runtime.Object) (admission.Warnings, error) {
+	approval, ok := obj.(*PulseProApproval)
+	if !ok {
+		return nil, fmt.Errorf("expected a PulseProApproval but got %T", obj)
+	}
+	pulseproapprovallog.Info("validate create", "name", approval.Name)
+
+	rollout := &PulseProRollout{}
+	key := types.NamespacedName{Name: approval.Spec.RolloutName, Namespace: approval.Namespace}
+	if err := w.Client.Get(ctx, key, rollout); err != nil {
+		return nil, fmt.Errorf("failed to get PulseProRollout %s: %v", key, err)
+	}
+	if approval.Spec.RolloutUID != rollout.UID || approval.Spec.ImageVersion != rollout.Spec.ImageVersion {
+		return nil, fmt.Errorf("the approval must be for PulseProRollout %s with UID %s at imageVersion %s", key, rollout.UID, rollout.Spec.ImageVersion)
+	}
+
+	gate := rollout.Spec.GateNamed(approval.Spec.Gate)
+	if gate == nil {
+		return nil, fmt.Errorf("PulseProRollout %s has no approval gate %q", key, approval.Spec.Gate)
+	}
+
+	req, err := admission.RequestFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return nil, authorizeApprover(gate, req.UserInfo)
+}
+
+// ValidateUpdate rejects changes to an approval once it has been given
+func (w *PulseProApprovalCustomWebhook) ValidateUpdate(ctx context.Context, oldObj, newObj runtime.Object) (admission.Warnings, error) {
+	approval, ok := newObj.(*PulseProApproval)
+	if !ok {
+		return nil, fmt.Errorf("expected a PulseProApproval but got %T", newObj)
+	}
+	old, ok := oldObj.(*PulseProApproval)
+	if !ok {
+		return nil, fmt.Errorf("expected a PulseProApproval but got %T", oldObj)
+	}
+	pulseproapprovallog.Info("validate update", "name", approval.Name)
+
+	if approval.Spec != old.Spec {
+		return nil, fmt.Errorf("the spec of a PulseProApproval cannot be changed")
+	}
+	return nil, nil
+}
+
+// ValidateDelete implements webhook.CustomValidator so a webhook will be registered for the type
+func (w *PulseProApprovalCustomWebhook) ValidateDelete(ctx context.Context, obj runtime.Object) (admission.Warnings, error) {
+	return nil, nil
+}
@@ -0,0 +1,150 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"context"
+	"encoding/json"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	apimachineryruntime "k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+var _ = Describe("PulseProApproval Webhook", func() {
+	var (
+		rollout  *PulseProRollout
+		approval *PulseProApproval
+		hook     *PulseProApprovalCustomWebhook
+	)
+
+	requestBy := func(username string, groups ...string) context.Context {
+		return admission.NewContextWithRequest(context.Background(), admission.Request{
+			AdmissionRequest: admissionv1.AdmissionRequest{
+				UserInfo: authenticationv1.UserInfo{Username: username, Groups: groups},
+			},
+		})
+	}
+
+	BeforeEach(func() {
+		rollout = &PulseProRollout{
+			ObjectMeta: metav1.ObjectMeta{Name: "rollout", Namespace: "default", UID: "rollout-uid"},
+			Spec: PulseProRolloutSpec{
+				Namespace:    "default",
+				ImageVersion: "2.4.0",
+				ApprovalGates: []ApprovalGate{{
+					Name:           "production",
+					Category:       "production",
+					ApproverGroups: []string{"release-managers"},
+				}},
+			},
+		}
+		approval = &PulseProApproval{
+			ObjectMeta: metav1.ObjectMeta{Name: "approval", Namespace: "default"},
+			Spec:       PulseProApprovalSpec{RolloutName: "rollout", Gate: "production"},
+		}
+
+		scheme := apimachineryruntime.NewScheme()
+		Expect(AddToScheme(scheme)).To(Succeed())
+		hook = &PulseProApprovalCustomWebhook{Client: fake.NewClientBuilder().WithScheme(scheme).WithObjects(rollout).Build()}
+	})
+
+	Context("When creating an approval", func() {
+		It("Should tie the approval to the requesting user and the current rollout and version", func() {
+			approval.Spec.Approver = "someone-else"
+			approval.Spec.RolloutUID = "deleted-uid"
+			approval.Spec.ImageVersion = "2.3.0"
+
+			Expect(hook.Default(requestBy("alice", "release-managers"), approval)).To(Succeed())
+			Expect(approval.Spec.Approver).To(Equal("alice"))
+			Expect(approval.Spec.RolloutUID).To(BeEquivalentTo("rollout-uid"))
+			Expect(approval.Spec.ImageVersion).To(Equal("2.4.0"))
+
+			_, err := hook.ValidateCreate(requestBy("alice", "release-managers"), approval)
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("Should deny approvals of another rollout or version", func() {
+			Expect(hook.Default(requestBy("alice", "release-managers"), approval)).To(Succeed())
+
+			stale := approval.DeepCopy()
+			stale.Spec.RolloutUID = "deleted-uid"
+			_, err := hook.ValidateCreate(requestBy("alice", "release-managers"), stale)
+			Expect(err).To(HaveOccurred())
+
+			stale = approval.DeepCopy()
+			stale.Spec.ImageVersion = "2.3.0"
+			_, err = hook.ValidateCreate(requestBy("alice", "release-managers"), stale)
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("Should deny users outside the approver groups", func() {
+			Expect(hook.Default(requestBy("bob", "developers"), approval)).To(Succeed())
+
+			_, err := hook.ValidateCreate(requestBy("bob", "developers"), approval)
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("Should deny approvals of unknown gates and rollouts", func() {
+			approval.Spec.Gate = "staging"
+			Expect(hook.Default(requestBy("alice", "release-managers"), approval)).To(Succeed())
+			_, err := hook.ValidateCreate(requestBy("alice", "release-managers"), approval)
+			Expect(err).To(HaveOccurred())
+
+			approval.Spec.Gate = "production"
+			approval.Spec.RolloutName = "missing"
+			Expect(hook.Default(requestBy("alice", "release-managers"), approval)).To(Succeed())
+			_, err = hook.ValidateCreate(requestBy("alice", "release-managers"), approval)
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Context("When updating an approval", func() {
+		It("Should keep the fields set by the webhook", func() {
+			Expect(hook.Default(requestBy("alice", "release-managers"), approval)).To(Succeed())
+			raw, err := json.Marshal(approval)
+			Expect(err).NotTo(HaveOccurred())
+
+			updated := approval.DeepCopy()
+			updated.Spec.Approver = "bob"
+			updated.Spec.RolloutUID = "other-uid"
+			updated.Spec.ImageVersion = "2.5.0"
+			ctx := admission.NewContextWithRequest(context.Background(), admission.Request{
+				AdmissionRequest: admissionv1.AdmissionRequest{
+					UserInfo:  authenticationv1.UserInfo{Username: "bob"},
+					OldObject: apimachineryruntime.RawExtension{Raw: raw},
+				},
+			})
+			Expect(hook.Default(ctx, updated)).To(Succeed())
+			Expect(updated.Spec).To(Equal(approval.Spec))
+		})
+
+		It("Should deny changes to the spec", func() {
+			updated := approval.DeepCopy()
+			updated.Spec.Gate = "staging"
+
+			_, err := hook.ValidateUpdate(requestBy("alice", "release-managers"), approval, updated)
+			Expect(err).To(HaveOccurred())
+		})
+	})
+})
@@ -12,20 +12,42 @@ type PulseProDeploymentSpec struct {
 	// GitRepoURL is the URL of the Git repository used for GitOps sync
 	GitRepoURL string `json:"gitRepoURL,omitempty"`
 
+	// GitBranch is the branch of the Git repository to sync; defaults to the repository's default branch
+	GitBranch string `json:"gitBranch,omitempty"`
+
 	// HelmChart is the Helm chart to be used for deployment
 	HelmChart string `json:"helmChart"`
 
-	// HelmChartVersion is the version of the Helm chart to be used for deployment
+	// HelmChartVersion is the version of the Helm chart to be used for deployment, either exact or a
+	// semver range such as "~2.4" or ">=2.3 <3" that is resolved against the chart repository
 	HelmChartVersion string `json:"helmChartVersion"`
 
 	// HelmfileType is the type of Helmfile to be used for deployment
 	HelmfileType string `json:"helmfileType,omitempty"`
 
-	// PulseProVersion is the specific version of PulsePro to be deployed
+	// PulseProVersion is the version of PulsePro to be deployed, either exact or a semver range
+	// that is resolved against the tags of ImageRepository
 	PulseProVersion string `json:"pulseProVersion"`
 
-	// HelmValuesConfigMap is a reference to the ConfigMap containing Helm chart values
-	HelmValuesConfigMap ConfigMapReference `json:"helmValuesConfigMap"`
+	// ImageRepository is the OCI repository of the PulsePro image, e.g. "registry.example.com/pulsepro/pulse-pro".
+	// It is required when PulseProVersion is a range.
+	ImageRepository string `json:"imageRepository,omitempty"`
+
+	// UpdatePolicy decides when version ranges are resolved again. Auto picks up new matching versions on
+	// every sync, Notify keeps the resolved versions until the spec changes and reports newer matching
+	// versions, and Manual keeps them until the spec changes.
+	// +kubebuilder:validation:Enum=Auto;Notify;Manual
+	// +kubebuilder:default=Auto
+	UpdatePolicy string `json:"updatePolicy,omitempty"`
+
+	// HelmValuesConfigMap is a reference to the ConfigMap containing Helm chart values.
+	// Its values are the first layer the values of ValuesFrom are merged over.
+	// +optional
+	HelmValuesConfigMap ConfigMapReference `json:"helmValuesConfigMap,omitempty"`
+
+	// ValuesFrom are further sources of Helm values, deep-merged in order over HelmValuesConfigMap:
+	// maps are merged key by key, and any other value of a later source replaces the earlier one
+	ValuesFrom []ValuesSource `json:"valuesFrom,omitempty"`
 
 	// Secrets contains a list of Kubernetes secrets required for the deployment
 	Secrets []SecretReference `json:"secrets"`
@@ -44,6 +66,160 @@ type PulseProDeploymentSpec struct {
 
 	// Category groups deployments into categories (e.g., "production", "staging", "sandbox")
 	Category string `json:"category,omitempty"`
+
+	// MaintenanceWindows restricts version-changing releases to the given windows; releases are unrestricted when empty
+	MaintenanceWindows []MaintenanceWindow `json:"maintenanceWindows,omitempty"`
+
+	// DriftPolicy enables drift detection between the GitOps desired state and the live objects.
+	// Report only sets the Drifted condition, Correct also re-syncs the release to undo the drift.
+	// When empty, drift is not checked and the release is synced on every SyncInterval.
+	// In Plan mode drift is only reported; it is corrected by applying a new plan.
+	// +kubebuilder:validation:Enum=Report;Correct
+	DriftPolicy string `json:"driftPolicy,omitempty"`
+
+	// Mode is Apply to sync changes as soon as they are seen, or Plan to record them in a PulseProPlan
+	// that is only synced once it is approved
+	// +kubebuilder:validation:Enum=Apply;Plan
+	// +kubebuilder:default=Apply
+	Mode string `json:"mode,omitempty"`
+
+	// CommitVerification requires the synced commit to be signed by a trusted key before it is released
+	CommitVerification *CommitVerification `json:"commitVerification,omitempty"`
+
+	// KubeconfigSecretRef selects the kubeconfig of the cluster PulsePro is deployed to.
+	// When empty, the operator's own cluster is used.
+	KubeconfigSecretRef *KubeconfigSecretReference `json:"kubeconfigSecretRef,omitempty"`
+
+	// RegistryCredentials are Secrets with credentials for the OCI registries the charts are pulled from.
+	// They take precedence over the operator's registry token providers.
+	RegistryCredentials []RegistryCredentialsReference `json:"registryCredentials,omitempty"`
+
+	// ValuesMirror materializes values files of the GitOps repository into a ConfigMap owned by the deployment
+	ValuesMirror *ValuesMirror `json:"valuesMirror,omitempty"`
+
+	// ChartVerification requires the chart to be signed by a trusted key before it is released
+	ChartVerification *ChartVerification `json:"chartVerification,omitempty"`
+}
+
+const (
+	// UpdatePolicyAuto resolves version ranges on every reconcile
+	UpdatePolicyAuto = "Auto"
+
+	// UpdatePolicyNotify keeps the resolved versions until the spec changes and reports newer matching versions
+	UpdatePolicyNotify = "Notify"
+
+	// UpdatePolicyManual keeps the resolved versions until the spec changes
+	UpdatePolicyManual = "Manual"
+)
+
+const (
+	// DriftPolicyReport reports drift in the Drifted condition without changing the live objects
+	DriftPolicyReport = "Report"
+
+	// DriftPolicyCorrect re-syncs the release when drift is detected
+	DriftPolicyCorrect = "Correct"
+)
+
+// MaintenanceWindow is a recurring period in which version-changing releases are allowed
+type MaintenanceWindow struct {
+	// Schedule is a cron expression for the start of each window (e.g., "0 1 * * *" for 01:00 every day)
+	Schedule string `json:"schedule"`
+
+	// Duration is how long each window stays open (e.g., "3h")
+	Duration string `json:"duration"`
+
+	// TimeZone is the IANA time zone the schedule is evaluated in (e.g., "Europe/Berlin"); defaults to UTC
+	TimeZone string `json:"timeZone,omitempty"`
+}
+
+// CommitVerification references the keys trusted to sign the commits of the GitOps repository
+type CommitVerification struct {
+	// SecretName is the Secret in the deployment's namespace holding the trusted keys. Each value holds
+	// armored GPG public keys, or SSH public keys in authorized_keys or allowed_signers format.
+	SecretName string `json:"secretName"`
+}
+
+// KubeconfigSecretReference references a kubeconfig held in a Secret of the deployment's namespace
+type KubeconfigSecretReference struct {
+	// Name is the name of the Secret
+	Name string `json:"name"`
+
+	// Key is the key of the kubeconfig in the Secret
+	// +kubebuilder:default=kubeconfig
+	Key string `json:"key,omitempty"`
+
+	// Context is the kubeconfig context to use; defaults to the kubeconfig's current context
+	Context string `json:"context,omitempty"`
+}
+
+// RegistryCredentialsReference references registry credentials held in a Secret of the deployment's namespace
+type RegistryCredentialsReference struct {
+	// SecretName is a Secret of type kubernetes.io/dockerconfigjson, or of type kubernetes.io/basic-auth
+	// for the registry given by Host
+	SecretName string `json:"secretName"`
+
+	// Host is the registry host of a basic-auth Secret (e.g., "registry.example.com" or "localhost:5000")
+	Host string `json:"host,omitempty"`
+}
+
+// ChartVerification references the keys trusted to sign the chart and how the chart is signed.
+// The verified chart archive is passed to helmfile as the state value helmChart, with its digest as
+// helmChartDigest; the helmfile must install {{ .StateValues.helmChart }} so that the tag is not resolved again.
+type ChartVerification struct {
+	// Provider is how the chart is signed: Helm for a .prov provenance file next to the chart,
+	// Cosign for a cosign signature of an OCI chart
+	// +kubebuilder:validation:Enum=Helm;Cosign
+	Provider string `json:"provider"`
+
+	// SecretName is the Secret in the deployment's namespace holding the trusted keys: armored or binary
+	// GPG public keys for Helm, PEM public keys for Cosign
+	SecretName string `json:"secretName"`
+
+	// IgnoreTransparencyLog accepts cosign signatures that were not uploaded to a transparency log,
+	// e.g. of charts signed for a private registry
+	IgnoreTransparencyLog bool `json:"ignoreTransparencyLog,omitempty"`
+}
+
+const (
+	// ChartVerificationHelm verifies the chart against its Helm provenance file
+	ChartVerificationHelm = "Helm"
+
+	// ChartVerificationCosign verifies the cosign signature of the OCI chart
+	ChartVerificationCosign = "Cosign"
+)
+
+// ValuesSource is one layer of Helm values. Exactly one of ConfigMapKeyRef, SecretKeyRef, GitFile and Inline is set.
+type ValuesSource struct {
+	// ConfigMapKeyRef selects a key of a ConfigMap in the deployment's namespace
+	ConfigMapKeyRef *ConfigMapReference `json:"configMapKeyRef,omitempty"`
+
+	// SecretKeyRef selects a key of a Secret in the deployment's namespace; its values are redacted
+	SecretKeyRef *SecretKeyReference `json:"secretKeyRef,omitempty"`
+
+	// GitFile is the path of a values file in the GitOps repository, relative to the repository root
+	GitFile string `json:"gitFile,omitempty"`
+
+	// Inline is a YAML document of values
+	Inline string `json:"inline,omitempty"`
+
+	// Optional skips a ConfigMap, Secret, key or file that does not exist instead of failing the reconcile
+	Optional bool `json:"optional,omitempty"`
+}
+
+// ValuesMirror selects the repository files mirrored into a ConfigMap
+type ValuesMirror struct {
+	// ConfigMapName is the name of the mirror ConfigMap; defaults to "<deployment>-repo-values"
+	ConfigMapName string `json:"configMapName,omitempty"`
+
+	// Paths are files or directories of the repository, relative to its root, whose files are mirrored. They must
+	// be inside environments/<project>-<environment>/values, which is mirrored when empty. Mirrored files are redacted.
+	Paths []string `json:"paths,omitempty"`
+}
+
+// SecretKeyReference selects a key of a Secret
+type SecretKeyReference struct {
+	Name string `json:"name"`
+	Key  string `json:"key"`
 }
 
 // ConfigMapReference defines a reference to a ConfigMap
@@ -52,6 +228,27 @@ type ConfigMapReference struct {
 	Key  string `json:"key"`
 }
 
+// ResolvedVersions are the exact versions the version ranges of a deployment resolved to
+type ResolvedVersions struct {
+	// PulseProVersion is the exact version of PulsePro that is deployed
+	PulseProVersion string `json:"pulseProVersion,omitempty"`
+
+	// HelmChartVersion is the exact version of the Helm chart that is deployed
+	HelmChartVersion string `json:"helmChartVersion,omitempty"`
+
+	// AvailablePulseProVersion is a newer PulsePro version matching the range, with the Notify update policy
+	AvailablePulseProVersion string `json:"availablePulseProVersion,omitempty"`
+
+	// AvailableHelmChartVersion is a newer chart version matching the range, with the Notify update policy
+	AvailableHelmChartVersion string `json:"availableHelmChartVersion,omitempty"`
+
+	// Generation is the spec generation the versions were resolved for
+	Generation int64 `json:"generation,omitempty"`
+
+	// ResolvedAt is when the versions were last resolved
+	ResolvedAt *metav1.Time `json:"resolvedAt,omitempty"`
+}
+
 // SecretReference defines a reference to a Kubernetes Secret
 type SecretReference struct {
 	Name      string `json:"name"`
@@ -80,8 +277,191 @@ type PulseProDeploymentStatus struct {
 
 	// RollbackInProgress is true when a rollback is happening
 	RollbackInProgress bool `json:"rollbackInProgress,omitempty"`
+
+	// LastAppliedRevision is the Git commit of the last successful sync
+	LastAppliedRevision string `json:"lastAppliedRevision,omitempty"`
+
+	// ObservedGeneration is the generation of the spec of the last successful sync
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// ValuesHash is the digest of the merged Helm values of the last reconcile, e.g. "sha256:..."
+	ValuesHash string `json:"valuesHash,omitempty"`
+
+	// LastAppliedValuesHash is the digest of the merged Helm values of the last successful sync
+	LastAppliedValuesHash string `json:"lastAppliedValuesHash,omitempty"`
+
+	// LastAppliedChartVersion is the exact chart version of the last successful sync
+	LastAppliedChartVersion string `json:"lastAppliedChartVersion,omitempty"`
+
+	// EffectiveValuesConfigMap is the ConfigMap holding the redacted merged Helm values of the last reconcile
+	EffectiveValuesConfigMap string `json:"effectiveValuesConfigMap,omitempty"`
+
+	// ValuesMirrorConfigMap is the ConfigMap the repository values files are mirrored into
+	ValuesMirrorConfigMap string `json:"valuesMirrorConfigMap,omitempty"`
+
+	// ResolvedVersions are the exact versions the version ranges of the spec resolved to
+	ResolvedVersions *ResolvedVersions `json:"resolvedVersions,omitempty"`
+
+	// VerifiedChartDigest is the digest of the chart whose signature was last verified, e.g. "sha256:..."
+	VerifiedChartDigest string `json:"verifiedChartDigest,omitempty"`
+
+	// LatestPlan is the name of the most recent PulseProPlan of the deployment in Plan mode
+	LatestPlan string `json:"latestPlan,omitempty"`
+
+	// LastFailure describes the last failed reconcile and when it is retried; it is cleared once a reconcile succeeds
+	LastFailure *ReconcileFailure `json:"lastFailure,omitempty"`
+
+	// DriftedObjects lists the live objects that differ from the desired state
+	DriftedObjects []DriftedObject `json:"driftedObjects,omitempty"`
+
+	// Conditions describe the latest observations of the deployment, e.g. why a release is deferred
+	// +listType=map
+	// +listMapKey=type
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
+// ReconcileFailure is a failed reconcile of a PulseProDeployment and its retry schedule
+type ReconcileFailure struct {
+	// Class is how the failure is retried: Transient failures back off exponentially, Configuration
+	// failures are retried slowly or on a spec change, and Permanent failures wait for a spec change
+	// +kubebuilder:validation:Enum=Transient;Configuration;Permanent
+	Class string `json:"class"`
+
+	// Reason is a CamelCase identifier of the failed step, e.g. "SecretsMissing"
+	Reason string `json:"reason"`
+
+	// Message describes the failure
+	Message string `json:"message,omitempty"`
+
+	// RetryCount is the number of consecutive failures of the current spec generation, Git revision and values
+	RetryCount int32 `json:"retryCount"`
+
+	// NextRetryTime is when the reconcile is retried; unset when it waits for a spec change
+	NextRetryTime *metav1.Time `json:"nextRetryTime,omitempty"`
+
+	// Generation is the spec generation that failed
+	Generation int64 `json:"generation"`
+
+	// Revision is the Git revision the failed reconcile checked out, if it got that far.
+	// A new revision is retried right away.
+	Revision string `json:"revision,omitempty"`
+
+	// ValuesHash is the hash of the values the failed reconcile merged, if it got that far.
+	// Changed values are retried right away.
+	ValuesHash string `json:"valuesHash,omitempty"`
 }
 
+// DriftedObject is a live object that differs from the desired state rendered from Git
+type DriftedObject struct {
+	// Kind is the kind of the object, with its API group if any (e.g., "Deployment.apps")
+	Kind string `json:"kind"`
+
+	// Namespace of the object; empty for cluster-scoped objects
+	Namespace string `json:"namespace,omitempty"`
+
+	// Name of the object
+	Name string `json:"name"`
+
+	// Change is what a sync would do to the object: Add, Change or Remove
+	Change string `json:"change"`
+
+	// Fields are the names of the fields that differ, for changed objects
+	Fields []string `json:"fields,omitempty"`
+}
+
+const (
+	// SourceRepositoryAnnotation records the repository a values mirror ConfigMap was read from
+	SourceRepositoryAnnotation = "pulsepro.pulsepro.io/source-repository"
+
+	// SourceRevisionAnnotation records the commit a values mirror ConfigMap was read from
+	SourceRevisionAnnotation = "pulsepro.pulsepro.io/source-revision"
+)
+
+// ValuesHashAnnotation records the digest of the merged Helm values on the effective values ConfigMap
+const ValuesHashAnnotation = "pulsepro.pulsepro.io/values-hash"
+
+const (
+	// ConditionReleaseDeferred is True while a version change is held back by a maintenance window or a change freeze
+	ConditionReleaseDeferred = "ReleaseDeferred"
+
+	// ConditionDrifted is True while live objects differ from the desired state
+	ConditionDrifted = "Drifted"
+
+	// ConditionSignatureVerified is True when the synced commit is signed by a trusted key
+	ConditionSignatureVerified = "SignatureVerified"
+
+	// ConditionClusterConnected is True when the target cluster of the deployment is reachable with its kubeconfig
+	ConditionClusterConnected = "ClusterConnected"
+
+	// ConditionChartVerified is True when the chart is signed by a trusted key
+	ConditionChartVerified = "ChartVerified"
+
+	// ConditionUpdateAvailable is True while a newer version matches a range held back by the Notify update policy
+	ConditionUpdateAvailable = "UpdateAvailable"
+)
+
+const (
+	// ReasonOutsideMaintenanceWindow means the current time is outside every maintenance window of the deployment
+	ReasonOutsideMaintenanceWindow = "OutsideMaintenanceWindow"
+
+	// ReasonChangeFreeze means a PulseProFreezeCalendar freezes the deployment's category
+	ReasonChangeFreeze = "ChangeFreeze"
+
+	// ReasonInvalidMaintenanceWindow means a maintenance window of the deployment cannot be evaluated
+	ReasonInvalidMaintenanceWindow = "InvalidMaintenanceWindow"
+
+	// ReasonReleaseAllowed means nothing holds back releases of the deployment
+	ReasonReleaseAllowed = "ReleaseAllowed"
+
+	// ReasonDriftDetected means live objects were changed outside of GitOps
+	ReasonDriftDetected = "DriftDetected"
+
+	// ReasonDriftCorrected means drift was detected and undone by re-syncing the release
+	ReasonDriftCorrected = "DriftCorrected"
+
+	// ReasonInSync means the live objects match the desired state
+	ReasonInSync = "InSync"
+
+	// ReasonTrustedSignature means the commit is signed by a trusted key
+	ReasonTrustedSignature = "TrustedSignature"
+
+	// ReasonUnsignedCommit means the commit has no signature
+	ReasonUnsignedCommit = "UnsignedCommit"
+
+	// ReasonUntrustedSignature means the commit signature is invalid or not made by a trusted key
+	ReasonUntrustedSignature = "UntrustedSignature"
+
+	// ReasonTrustedKeysUnavailable means the trusted keys could not be read
+	ReasonTrustedKeysUnavailable = "TrustedKeysUnavailable"
+
+	// ReasonVerificationRequired means the deployment's category requires commit verification but none is configured
+	ReasonVerificationRequired = "VerificationRequired"
+
+	// ReasonConnected means the target cluster accepted the deployment's credentials
+	ReasonConnected = "Connected"
+
+	// ReasonKubeconfigInvalid means the kubeconfig Secret is missing or does not hold a usable kubeconfig
+	ReasonKubeconfigInvalid = "KubeconfigInvalid"
+
+	// ReasonCredentialsRejected means the target cluster rejected the kubeconfig's credentials
+	ReasonCredentialsRejected = "CredentialsRejected"
+
+	// ReasonClusterUnreachable means the target cluster could not be reached
+	ReasonClusterUnreachable = "ClusterUnreachable"
+
+	// ReasonChartSigned means the chart is signed by a trusted key
+	ReasonChartSigned = "ChartSigned"
+
+	// ReasonChartRejected means the chart is unsigned, or its signature is invalid or not made by a trusted key
+	ReasonChartRejected = "ChartRejected"
+
+	// ReasonNewerVersionAvailable means a newer version matches a version range of the deployment
+	ReasonNewerVersionAvailable = "NewerVersionAvailable"
+
+	// ReasonUpToDate means the resolved versions are the newest matching their ranges
+	ReasonUpToDate = "UpToDate"
+)
+
 // +kubebuilder:object:root=true
 // +kubebuilder:subresource:status
 
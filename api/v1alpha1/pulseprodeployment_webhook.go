@@ -17,6 +17,10 @@ limitations under the License.
 package v1alpha1
 
 import (
+	"fmt"
+	"time"
+
+	"github.com/robfig/cron/v3"
 	"k8s.io/apimachinery/pkg/runtime"
 	ctrl "sigs.k8s.io/controller-runtime"
 	logf "sigs.k8s.io/controller-runtime/pkg/log"
@@ -58,16 +62,14 @@ var _ webhook.Validator = &PulseProDeployment{}
 func (r *PulseProDeployment) ValidateCreate() (admission.Warnings, error) {
 	pulseprodeploymentlog.Info("validate create", "name", r.Name)
 
-	// TODO(user): fill in your validation logic upon object creation.
-	return nil, nil
+	return nil, validateMaintenanceWindows(r.Spec.MaintenanceWindows)
 }
 
 // ValidateUpdate implements webhook.Validator so a webhook will be registered for the type
 func (r *PulseProDeployment) ValidateUpdate(old runtime.Object) (admission.Warnings, error) {
 	pulseprodeploymentlog.Info("validate update", "name", r.Name)
 
-	// TODO(user): fill in your validation logic upon object update.
-	return nil, nil
+	return nil, validateMaintenanceWindows(r.Spec.MaintenanceWindows)
 }
 
 // ValidateDelete implements webhook.Validator so a webhook will be registered for the type
@@ -77,3 +79,26 @@ func (r *PulseProDeployment) ValidateDelete() (admission.Warnings, error) {
 	// TODO(user): fill in your validation logic upon object deletion.
 	return nil, nil
 }
+
+// maintenanceWindowParser accepts the cron expressions the operator evaluates maintenance windows with:
+// standard five-field expressions and descriptors such as @daily
+var maintenanceWindowParser = cron.NewParser(cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow | cron.Descriptor)
+
+// validateMaintenanceWindows checks that the schedule, duration and time zone of every window parse, since a
+// deployment with an invalid window cannot be released at all
+func validateMaintenanceWindows(windows []MaintenanceWindow) error {
+	for _, window := range windows {
+		if _, err := maintenanceWindowParser.Parse(window.Schedule); err != nil {
+			return fmt.Errorf("invalid maintenance window schedule %q: %v", window.Schedule, err)
+		}
+		if duration, err := time.ParseDuration(window.Duration); err != nil || duration <= 0 {
+			return fmt.Errorf("invalid maintenance window duration %q", window.Duration)
+		}
+		if window.TimeZone != "" {
+			if _, err := time.LoadLocation(window.TimeZone); err != nil {
+				return fmt.Errorf("invalid maintenance window time zone %q: %v", window.TimeZone, err)
+			}
+		}
+	}
+	return nil
+}
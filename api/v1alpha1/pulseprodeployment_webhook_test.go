@@ -18,6 +18,7 @@ package v1alpha1
 
 import (
 	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
 )
 
 var _ = Describe("PulseProDeployment Webhook", func() {
@@ -30,6 +31,30 @@ var _ = Describe("PulseProDeployment Webhook", func() {
 		})
 	})
 
+	Context("When validating maintenance windows", func() {
+		It("Should deny windows that cannot be evaluated", func() {
+			for _, window := range []MaintenanceWindow{
+				{Schedule: "0 25 * * *", Duration: "3h"},
+				{Schedule: "0 1 * * *", Duration: "three hours"},
+				{Schedule: "0 1 * * *", Duration: "-1h"},
+				{Schedule: "0 1 * * *", Duration: "3h", TimeZone: "Europe/Atlantis"},
+			} {
+				deployment := &PulseProDeployment{Spec: PulseProDeploymentSpec{MaintenanceWindows: []MaintenanceWindow{window}}}
+				_, err := deployment.ValidateCreate()
+				Expect(err).To(HaveOccurred(), "window %+v", window)
+			}
+		})
+
+		It("Should admit valid windows", func() {
+			deployment := &PulseProDeployment{Spec: PulseProDeploymentSpec{MaintenanceWindows: []MaintenanceWindow{
+				{Schedule: "0 1 * * *", Duration: "3h", TimeZone: "Europe/Berlin"},
+				{Schedule: "@weekly", Duration: "30m"},
+			}}}
+			_, err := deployment.ValidateUpdate(deployment.DeepCopy())
+			Expect(err).NotTo(HaveOccurred())
+		})
+	})
+
 	Context("When creating PulseProDeployment under Validating Webhook", func() {
 		It("Should deny if a required field is empty", func() {
 
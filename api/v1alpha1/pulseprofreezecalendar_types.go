@@ -0,0 +1,54 @@
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// PulseProFreezeCalendarSpec defines the change freezes that apply across the cluster
+type PulseProFreezeCalendarSpec struct {
+	// Freezes lists the periods in which version-changing releases are not allowed
+	Freezes []FreezePeriod `json:"freezes"`
+}
+
+// FreezePeriod is a period in which version-changing releases are held back
+// +kubebuilder:validation:XValidation:rule="self.end > self.start",message="end must be later than start"
+type FreezePeriod struct {
+	// Name identifies the freeze in status messages
+	Name string `json:"name"`
+
+	// Start is the beginning of the freeze
+	Start metav1.Time `json:"start"`
+
+	// End is the end of the freeze
+	End metav1.Time `json:"end"`
+
+	// Categories limits the freeze to deployments of these categories; it applies to all deployments when empty
+	Categories []string `json:"categories,omitempty"`
+
+	// Reason explains the freeze (e.g., "Black Friday")
+	Reason string `json:"reason,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:resource:scope=Cluster
+
+// PulseProFreezeCalendar is the Schema for the pulseprofreezecalendars API
+type PulseProFreezeCalendar struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec PulseProFreezeCalendarSpec `json:"spec,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// PulseProFreezeCalendarList contains a list of PulseProFreezeCalendar
+type PulseProFreezeCalendarList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []PulseProFreezeCalendar `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&PulseProFreezeCalendar{}, &PulseProFreezeCalendarList{})
+}
@@ -0,0 +1,127 @@
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ImageWatchLabel labels each PulseProRollout created by a PulseProImageWatch with the name of the watch
+const ImageWatchLabel = "pulsepro.pulsepro.io/image-watch"
+
+const (
+	// ConditionTagsListed is True when the last poll listed the tags of the repository
+	ConditionTagsListed = "TagsListed"
+
+	// ReasonTagsListed means the tags of the repository were listed
+	ReasonTagsListed = "TagsListed"
+
+	// ReasonNoMatchingTag means no tag of the repository passes the filter
+	ReasonNoMatchingTag = "NoMatchingTag"
+
+	// ReasonInvalidFilter means the filter sets both a semver range and a regex, or one of them is invalid
+	ReasonInvalidFilter = "InvalidFilter"
+
+	// ReasonRegistryUnavailable means the registry could not be reached or rejected the credentials
+	ReasonRegistryUnavailable = "RegistryUnavailable"
+
+	// ReasonSuspended means polling is suspended by spec.suspend
+	ReasonSuspended = "Suspended"
+)
+
+// PulseProImageWatchSpec defines the registry repository to poll and the rollouts to create for new tags
+type PulseProImageWatchSpec struct {
+	// Repository is the OCI repository of the PulsePro image, e.g. "registry.example.com/pulsepro/pulse-pro".
+	// Registries on localhost are polled over plain HTTP.
+	Repository string `json:"repository"`
+
+	// Filter selects the tags that are released; all tags are when empty
+	Filter TagFilter `json:"filter,omitempty"`
+
+	// Interval is the time between polls (e.g., "5m"); defaults to five minutes
+	Interval string `json:"interval,omitempty"`
+
+	// Suspend stops polling until it is unset
+	Suspend bool `json:"suspend,omitempty"`
+
+	// RegistryCredentials are Secrets with credentials for the registry. Without them the operator's
+	// registry token providers are used, or the tags are listed anonymously.
+	RegistryCredentials []RegistryCredentialsReference `json:"registryCredentials,omitempty"`
+
+	// Rollout is the template of the PulseProRollouts created for new tags; the tag becomes their imageVersion
+	Rollout ImageWatchRolloutTemplate `json:"rollout"`
+}
+
+// TagFilter selects tags by a semver range or a regular expression; at most one of them may be set
+type TagFilter struct {
+	// Semver is a semver range the tags must satisfy, e.g. "~2.4" or ">=2.3 <3"
+	Semver string `json:"semver,omitempty"`
+
+	// Regex is a regular expression the tags must match, e.g. "^2\\.4\\.[0-9]+-sandbox$".
+	// Matching tags are ordered as semver, or lexically when they are not semver.
+	Regex string `json:"regex,omitempty"`
+}
+
+// ImageWatchRolloutTemplate selects the deployments a created PulseProRollout updates, and how
+type ImageWatchRolloutTemplate struct {
+	// Namespace is the namespace of the PulseProDeployments to update
+	Namespace string `json:"namespace"`
+
+	// Category selects the deployments of a category (e.g., "sandbox")
+	Category string `json:"category,omitempty"`
+
+	// Tags selects the deployments carrying these tags
+	Tags []string `json:"tags,omitempty"`
+
+	// Environments limits the rollout to these environments
+	Environments []string `json:"environments,omitempty"`
+
+	// ApprovalGates require a human sign-off before deployments in a category are updated
+	ApprovalGates []ApprovalGate `json:"approvalGates,omitempty"`
+
+	// Analysis, when set, updates deployments one at a time and checks each one after it syncs
+	Analysis *RolloutAnalysis `json:"analysis,omitempty"`
+}
+
+// PulseProImageWatchStatus defines the observed state of PulseProImageWatch
+type PulseProImageWatchStatus struct {
+	// LatestTag is the newest tag passing the filter that a rollout was created for
+	LatestTag string `json:"latestTag,omitempty"`
+
+	// LastRollout is the name of the PulseProRollout created for LatestTag
+	LastRollout string `json:"lastRollout,omitempty"`
+
+	// LastPollTime is when the repository was last polled
+	LastPollTime *metav1.Time `json:"lastPollTime,omitempty"`
+
+	// ObservedGeneration is the generation of the spec of the last successful poll
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// Conditions describe the result of the last poll
+	// +listType=map
+	// +listMapKey=type
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+
+// PulseProImageWatch is the Schema for the pulseproimagewatches API
+type PulseProImageWatch struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   PulseProImageWatchSpec   `json:"spec,omitempty"`
+	Status PulseProImageWatchStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// PulseProImageWatchList contains a list of PulseProImageWatch
+type PulseProImageWatchList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []PulseProImageWatch `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&PulseProImageWatch{}, &PulseProImageWatchList{})
+}
@@ -0,0 +1,112 @@
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// PulseProPlanSpec identifies the desired state a plan was computed for
+type PulseProPlanSpec struct {
+	// DeploymentName is the name of the PulseProDeployment, in the same namespace, the plan belongs to
+	DeploymentName string `json:"deploymentName"`
+
+	// Revision is the Git commit the plan was computed from
+	Revision string `json:"revision"`
+
+	// DeploymentGeneration is the generation of the PulseProDeployment spec the plan was computed from
+	DeploymentGeneration int64 `json:"deploymentGeneration"`
+
+	// PulseProVersion is the version of PulsePro the plan would deploy
+	PulseProVersion string `json:"pulseProVersion,omitempty"`
+
+	// HelmChartVersion is the version of the chart the plan would deploy
+	HelmChartVersion string `json:"helmChartVersion,omitempty"`
+
+	// ValuesHash is the hash of the merged Helm values the plan was computed with
+	ValuesHash string `json:"valuesHash,omitempty"`
+
+	// Approved applies the plan on the next reconcile of the deployment
+	Approved bool `json:"approved,omitempty"`
+}
+
+// PulseProPlanStatus holds the changes a sync of the planned desired state would make
+type PulseProPlanStatus struct {
+	// Phase is the state of the plan: Pending, Applied or Superseded
+	Phase string `json:"phase,omitempty"`
+
+	// Message describes the plan in one line
+	Message string `json:"message,omitempty"`
+
+	// Resources are the objects that would be added, changed or removed
+	Resources []DriftedObject `json:"resources,omitempty"`
+
+	// ValuesDelta lists the Helm values that changed since the last applied plan
+	ValuesDelta []ValueChange `json:"valuesDelta,omitempty"`
+
+	// Values is the content of the Helm values ConfigMap key the plan was computed with
+	Values string `json:"values,omitempty"`
+
+	// AppliedAt is when the plan was applied
+	AppliedAt *metav1.Time `json:"appliedAt,omitempty"`
+}
+
+// ValueChange is a single Helm value that differs between two plans
+type ValueChange struct {
+	// Key is the dotted path of the value (e.g., "vault.address")
+	Key string `json:"key"`
+
+	// Change is Add, Change or Remove
+	Change string `json:"change"`
+
+	// Previous is the value in the last applied plan
+	Previous string `json:"previous,omitempty"`
+
+	// Current is the value in this plan
+	Current string `json:"current,omitempty"`
+}
+
+const (
+	// ModeApply syncs the desired state as soon as it changes
+	ModeApply = "Apply"
+
+	// ModePlan only computes a PulseProPlan, which is applied once it is approved
+	ModePlan = "Plan"
+)
+
+const (
+	// PlanPhasePending means the plan waits for approval
+	PlanPhasePending = "Pending"
+
+	// PlanPhaseApplied means the plan was synced to the cluster
+	PlanPhaseApplied = "Applied"
+
+	// PlanPhaseSuperseded means a newer plan replaced the plan before it was applied
+	PlanPhaseSuperseded = "Superseded"
+)
+
+// PlanDeploymentLabel labels each PulseProPlan with the name of its PulseProDeployment
+const PlanDeploymentLabel = "pulsepro.pulsepro.io/deployment"
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+
+// PulseProPlan is the Schema for the pulseproplans API
+type PulseProPlan struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   PulseProPlanSpec   `json:"spec,omitempty"`
+	Status PulseProPlanStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// PulseProPlanList contains a list of PulseProPlan
+type PulseProPlanList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []PulseProPlan `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&PulseProPlan{}, &PulseProPlanList{})
+}
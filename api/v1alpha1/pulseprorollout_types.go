@@ -4,6 +4,55 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
+const (
+	// RolloutPhaseScheduled is set while the rollout waits for spec.startAt
+	RolloutPhaseScheduled = "Scheduled"
+
+	// RolloutPhaseAwaitingApproval is set while an approval gate blocks the rollout
+	RolloutPhaseAwaitingApproval = "AwaitingApproval"
+
+	// RolloutPhaseCompleted is set once every matching deployment has been updated
+	RolloutPhaseCompleted = "Completed"
+
+	// RolloutPhasePaused is set while spec.paused holds back further updates
+	RolloutPhasePaused = "Paused"
+
+	// RolloutPhaseAborted is set once spec.abort has reverted the updated deployments
+	RolloutPhaseAborted = "Aborted"
+
+	// RolloutPhaseProgressing is set while updated deployments are being analysed one at a time
+	RolloutPhaseProgressing = "Progressing"
+
+	// RolloutPhaseFailed is set once a failing analysis has halted the rollout and reverted its deployments,
+	// or when spec.notAfter passed before every deployment was updated
+	RolloutPhaseFailed = "Failed"
+
+	// RolloutPhaseDryRun is set while spec.dryRun only lists the deployments the rollout would update
+	RolloutPhaseDryRun = "DryRun"
+
+	// RolloutPhaseWaitingForWindow is set while the remaining deployments are outside their maintenance windows or frozen
+	RolloutPhaseWaitingForWindow = "WaitingForWindow"
+)
+
+const (
+	// AnalysisRunning is set on a target while its analysis has not yet concluded
+	AnalysisRunning = "Running"
+
+	// AnalysisSuccessful is set on a target once its analysis passed often enough to promote it
+	AnalysisSuccessful = "Successful"
+
+	// AnalysisFailed is set on a target whose analysis failed too often
+	AnalysisFailed = "Failed"
+)
+
+const (
+	// ApproveAnnotation names the approval gate a user signs off by annotating the rollout
+	ApproveAnnotation = "pulsepro.pulsepro.io/approve"
+
+	// ApprovedByAnnotation records the user who set ApproveAnnotation; it is stamped by the webhook
+	ApprovedByAnnotation = "pulsepro.pulsepro.io/approved-by"
+)
+
 // PulseProRolloutSpec defines the desired state of PulseProRollout
 type PulseProRolloutSpec struct {
 	Namespace    string   `json:"namespace"`
@@ -11,11 +60,235 @@ type PulseProRolloutSpec struct {
 	Category     string   `json:"category,omitempty"`
 	ImageVersion string   `json:"imageVersion"`
 	Environments []string `json:"environments,omitempty"`
+
+	// ApprovalGates require a human sign-off before deployments in a category are updated
+	ApprovalGates []ApprovalGate `json:"approvalGates,omitempty"`
+
+	// Paused stops the rollout from updating further deployments until it is unset
+	Paused bool `json:"paused,omitempty"`
+
+	// Abort stops the rollout and reverts the deployments it already updated to their previous version
+	Abort bool `json:"abort,omitempty"`
+
+	// DryRun lists the deployments matched by the rollout in status.dryRunTargets without updating any of them
+	DryRun bool `json:"dryRun,omitempty"`
+
+	// StartAt delays the rollout until the given time
+	StartAt *metav1.Time `json:"startAt,omitempty"`
+
+	// NotAfter is the deadline for the rollout; it fails if deployments are still left to update by then
+	NotAfter *metav1.Time `json:"notAfter,omitempty"`
+
+	// Analysis, when set, updates deployments one at a time and checks each one after it syncs
+	// before moving on to the next. A failing analysis halts the rollout and reverts it.
+	Analysis *RolloutAnalysis `json:"analysis,omitempty"`
+}
+
+// RolloutAnalysis defines the checks run against each updated deployment
+type RolloutAnalysis struct {
+	// Interval is the time between analysis runs (e.g., "1m"); defaults to one minute
+	Interval string `json:"interval,omitempty"`
+
+	// SuccessfulRuns is the number of passing runs needed to promote a deployment; defaults to 1
+	// +kubebuilder:validation:Minimum=1
+	SuccessfulRuns int `json:"successfulRuns,omitempty"`
+
+	// FailureLimit is the number of failing runs that halts the rollout; defaults to 1
+	// +kubebuilder:validation:Minimum=1
+	FailureLimit int `json:"failureLimit,omitempty"`
+
+	// Metrics are the checks making up one analysis run; a run passes only if all of them pass
+	Metrics []AnalysisMetric `json:"metrics,omitempty"`
+
+	// Templates are PulseProAnalysisTemplates in the namespace of the rollout whose metrics run with Metrics
+	Templates []AnalysisTemplateReference `json:"templates,omitempty"`
+}
+
+// AnalysisTemplateReference refers to a PulseProAnalysisTemplate
+type AnalysisTemplateReference struct {
+	// Name is the name of the PulseProAnalysisTemplate
+	Name string `json:"name"`
+}
+
+// AnalysisMetric is a single check; exactly one of Prometheus or HTTP must be set
+type AnalysisMetric struct {
+	// Name identifies the check in status messages
+	Name string `json:"name"`
+
+	// Prometheus runs a PromQL query and compares its result with a threshold
+	Prometheus *PrometheusCheck `json:"prometheus,omitempty"`
+
+	// HTTP requests a URL and checks the response status
+	HTTP *HTTPCheck `json:"http,omitempty"`
+}
+
+// PrometheusCheck compares the result of a PromQL query with a threshold.
+// The query is a Go template rendered with the target deployment (.Name, .Namespace, .Version, ...).
+type PrometheusCheck struct {
+	// Address is the Prometheus URL; defaults to the operator's --prometheus-url
+	Address string `json:"address,omitempty"`
+
+	// Query is the PromQL query; it must return a scalar or a single-sample vector
+	Query string `json:"query"`
+
+	// Operator compares the query result (left) with Threshold (right)
+	// +kubebuilder:validation:Enum=">";">=";"<";"<=";"==";"!="
+	Operator string `json:"operator"`
+
+	// Threshold is the number the query result is compared with
+	Threshold string `json:"threshold"`
+}
+
+// HTTPCheck requests a URL and expects a given status code.
+// The URL is a Go template rendered with the target deployment (.Name, .Namespace, .Version, ...).
+type HTTPCheck struct {
+	// URL is the address to request with GET
+	URL string `json:"url"`
+
+	// ExpectedStatus is the HTTP status that makes the check pass; defaults to 200
+	ExpectedStatus int `json:"expectedStatus,omitempty"`
+}
+
+// ApprovalGate holds back the deployments of a category until an authorized user approves
+type ApprovalGate struct {
+	// Name identifies the gate in approval annotations and PulseProApproval objects
+	Name string `json:"name"`
+
+	// Category is the deployment category guarded by this gate (e.g., "production")
+	Category string `json:"category"`
+
+	// ApproverGroups lists the user groups allowed to approve this gate
+	ApproverGroups []string `json:"approverGroups"`
+}
+
+// ApprovalRecord records who approved a gate and when
+type ApprovalRecord struct {
+	// Gate is the name of the approved gate
+	Gate string `json:"gate"`
+
+	// Approver is the username that approved the gate
+	Approver string `json:"approver"`
+
+	// ApprovedAt is the time the operator observed the approval
+	ApprovedAt metav1.Time `json:"approvedAt"`
+
+	// ImageVersion is the version the gate was approved for; the approval is dropped once spec.imageVersion changes
+	ImageVersion string `json:"imageVersion,omitempty"`
+}
+
+// RolloutTarget records a deployment the rollout changed, so the change can be reverted
+type RolloutTarget struct {
+	// Name is the name of the PulseProDeployment
+	Name string `json:"name"`
+
+	// PreviousVersion is the PulseProVersion the deployment had before the rollout updated it
+	PreviousVersion string `json:"previousVersion"`
+
+	// Reverted is true once an abort has restored PreviousVersion
+	Reverted bool `json:"reverted,omitempty"`
+
+	// Analysis is the analysis state of the deployment: Running, Successful or Failed
+	Analysis string `json:"analysis,omitempty"`
+
+	// SuccessfulRuns counts the passing analysis runs of the deployment
+	SuccessfulRuns int `json:"successfulRuns,omitempty"`
+
+	// FailedRuns counts the failing analysis runs of the deployment
+	FailedRuns int `json:"failedRuns,omitempty"`
+}
+
+// DeferredTarget is a deployment the rollout is holding back because releases are not allowed yet
+type DeferredTarget struct {
+	// Name is the name of the PulseProDeployment
+	Name string `json:"name"`
+
+	// Reason is OutsideMaintenanceWindow, ChangeFreeze, or InvalidMaintenanceWindow when a window of the
+	// deployment cannot be evaluated until its spec is fixed
+	Reason string `json:"reason"`
+
+	// Message explains why the deployment is held back and until when
+	Message string `json:"message,omitempty"`
 }
 
 // PulseProRolloutStatus defines the observed state of PulseProRollout
 type PulseProRolloutStatus struct {
 	Phase string `json:"phase,omitempty"`
+
+	// Message explains the current phase, e.g. which analysis check failed
+	Message string `json:"message,omitempty"`
+
+	// UpdatedTargets lists the deployments updated by this rollout
+	UpdatedTargets []RolloutTarget `json:"updatedTargets,omitempty"`
+
+	// DeferredTargets lists the deployments held back by maintenance windows or change freezes
+	DeferredTargets []DeferredTarget `json:"deferredTargets,omitempty"`
+
+	// PendingGates lists the approval gates the rollout is waiting on
+	PendingGates []string `json:"pendingGates,omitempty"`
+
+	// Approvals records the gates that have been approved
+	Approvals []ApprovalRecord `json:"approvals,omitempty"`
+
+	// DryRunTargets lists the deployments the rollout matches, as evaluated by spec.dryRun
+	DryRunTargets []DryRunTarget `json:"dryRunTargets,omitempty"`
+}
+
+// DryRunTarget is a deployment a rollout would update, with the version change it would make
+type DryRunTarget struct {
+	// Name is the name of the PulseProDeployment
+	Name string `json:"name"`
+
+	// Category is the category of the deployment
+	Category string `json:"category,omitempty"`
+
+	// CurrentVersion is the version the deployment is set to now
+	CurrentVersion string `json:"currentVersion,omitempty"`
+
+	// TargetVersion is the version the rollout would set
+	TargetVersion string `json:"targetVersion"`
+
+	// Gate is the approval gate the deployment would wait on, if any
+	Gate string `json:"gate,omitempty"`
+}
+
+// GateFor returns the approval gate guarding the given category, if any
+func (s *PulseProRolloutSpec) GateFor(category string) *ApprovalGate {
+	for i := range s.ApprovalGates {
+		if s.ApprovalGates[i].Category == category {
+			return &s.ApprovalGates[i]
+		}
+	}
+	return nil
+}
+
+// GateNamed returns the approval gate with the given name, if any
+func (s *PulseProRolloutSpec) GateNamed(name string) *ApprovalGate {
+	for i := range s.ApprovalGates {
+		if s.ApprovalGates[i].Name == name {
+			return &s.ApprovalGates[i]
+		}
+	}
+	return nil
+}
+
+// ApprovalFor returns the recorded approval for the named gate, if any
+func (s *PulseProRolloutStatus) ApprovalFor(gate string) *ApprovalRecord {
+	for i := range s.Approvals {
+		if s.Approvals[i].Gate == gate {
+			return &s.Approvals[i]
+		}
+	}
+	return nil
+}
+
+// TargetNamed returns the record of the updated deployment with the given name, if any
+func (s *PulseProRolloutStatus) TargetNamed(name string) *RolloutTarget {
+	for i := range s.UpdatedTargets {
+		if s.UpdatedTargets[i].Name == name {
+			return &s.UpdatedTargets[i]
+		}
+	}
+	return nil
 }
 
 // +kubebuilder:object:root=true
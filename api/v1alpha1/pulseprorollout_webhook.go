@@ -0,0 +1,250 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	authenticationv1 "k8s.io/api/authentication/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/runtime"
+	ctrl "sigs.k8s.io/controller-runtime"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+// log is for logging in this package.
+var pulseprorolloutlog = logf.Log.WithName("pulseprorollout-resource")
+
+// SetupWebhookWithManager will setup the manager to manage the webhooks
+func (r *PulseProRollout) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(r).
+		WithDefaulter(&PulseProRolloutCustomWebhook{}).
+		WithValidator(&PulseProRolloutCustomWebhook{}).
+		Complete()
+}
+
+// PulseProRolloutCustomWebhook stamps and authorizes approvals given through the approve annotation.
+// It needs the admission request to know who is approving, so it uses the custom webhook interfaces.
+// +kubebuilder:object:generate=false
+type PulseProRolloutCustomWebhook struct{}
+
+// +kubebuilder:webhook:path=/mutate-pulsepro-pulsepro-io-v1alpha1-pulseprorollout,mutating=true,failurePolicy=fail,sideEffects=None,groups=pulsepro.pulsepro.io,resources=pulseprorollouts,verbs=create;update,versions=v1alpha1,name=mpulseprorollout.kb.io,admissionReviewVersions=v1
+
+var _ webhook.CustomDefaulter = &PulseProRolloutCustomWebhook{}
+
+// Default records the requesting user in the approved-by annotation whenever the approve annotation changes,
+// and otherwise keeps the previously recorded approver so it cannot be edited by hand. An approval given
+// before spec.imageVersion changed is removed, since it was given for another version.
+func (w *PulseProRolloutCustomWebhook) Default(ctx context.Context, obj runtime.Object) error {
+	rollout, ok := obj.(*PulseProRollout)
+	if !ok {
+		return fmt.Errorf("expected a PulseProRollout but got %T", obj)
+	}
+	pulseprorolloutlog.Info("default", "name", rollout.Name)
+
+	req, err := admission.RequestFromContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	old := &PulseProRollout{}
+	if len(req.OldObject.Raw) > 0 {
+		if err := json.Unmarshal(req.OldObject.Raw, old); err != nil {
+			return fmt.Errorf("failed to decode previous PulseProRollout: %v", err)
+		}
+	}
+
+	gate := rollout.Annotations[ApproveAnnotation]
+	switch {
+	case gate == "":
+		delete(rollout.Annotations, ApprovedByAnnotation)
+	case gate == old.Annotations[ApproveAnnotation] && rollout.Spec.ImageVersion != old.Spec.ImageVersion:
+		delete(rollout.Annotations, ApproveAnnotation)
+		delete(rollout.Annotations, ApprovedByAnnotation)
+	case gate != old.Annotations[ApproveAnnotation]:
+		rollout.Annotations[ApprovedByAnnotation] = req.UserInfo.Username
+	case old.Annotations[ApprovedByAnnotation] != "":
+		rollout.Annotations[ApprovedByAnnotation] = old.Annotations[ApprovedByAnnotation]
+	default:
+		delete(rollout.Annotations, ApprovedByAnnotation)
+	}
+	return nil
+}
+
+// +kubebuilder:webhook:path=/validate-pulsepro-pulsepro-io-v1alpha1-pulseprorollout,mutating=false,failurePolicy=fail,sideEffects=None,groups=pulsepro.pulsepro.io,resources=pulseprorollouts,verbs=create;update,versions=v1alpha1,name=vpulseprorollout.kb.io,admissionReviewVersions=v1
+
+var _ webhook.CustomValidator = &PulseProRolloutCustomWebhook{}
+
+// ValidateCreate implements webhook.CustomValidator so a webhook will be registered for the type
+func (w *PulseProRolloutCustomWebhook) ValidateCreate(ctx context.Context, obj runtime.Object) (admission.Warnings, error) {
+	rollout, ok := obj.(*PulseProRollout)
+	if !ok {
+		return nil, fmt.Errorf("expected a PulseProRollout but got %T", obj)
+	}
+	pulseprorolloutlog.Info("validate create", "name", rollout.Name)
+
+	if err := validateApprovalGates(rollout.Spec.ApprovalGates); err != nil {
+		return nil, err
+	}
+	if err := validateAnalysis(rollout.Spec.Analysis); err != nil {
+		return nil, err
+	}
+	if err := validateSchedule(rollout.Spec); err != nil {
+		return nil, err
+	}
+	return nil, validateApproveAnnotation(ctx, rollout, rollout.Spec, "")
+}
+
+// ValidateUpdate implements webhook.CustomValidator so a webhook will be registered for the type
+func (w *PulseProRolloutCustomWebhook) ValidateUpdate(ctx context.Context, oldObj, newObj runtime.Object) (admission.Warnings, error) {
+	rollout, ok := newObj.(*PulseProRollout)
+	if !ok {
+		return nil, fmt.Errorf("expected a PulseProRollout but got %T", newObj)
+	}
+	old, ok := oldObj.(*PulseProRollout)
+	if !ok {
+		return nil, fmt.Errorf("expected a PulseProRollout but got %T", oldObj)
+	}
+	pulseprorolloutlog.Info("validate update", "name", rollout.Name)
+
+	if err := validateApprovalGates(rollout.Spec.ApprovalGates); err != nil {
+		return nil, err
+	}
+	if err := validateAnalysis(rollout.Spec.Analysis); err != nil {
+		return nil, err
+	}
+	if err := validateSchedule(rollout.Spec); err != nil {
+		return nil, err
+	}
+	if gatesLocked(old) && !equality.Semantic.DeepEqual(rollout.Spec.ApprovalGates, old.Spec.ApprovalGates) {
+		return nil, fmt.Errorf("approvalGates cannot be changed once the rollout has started or a gate was approved")
+	}
+	// Approvers are checked against the gates before the update, so that an update cannot add its own
+	// user's groups to a gate and approve it at once
+	return nil, validateApproveAnnotation(ctx, rollout, old.Spec, old.Annotations[ApproveAnnotation])
+}
+
+// ValidateDelete implements webhook.CustomValidator so a webhook will be registered for the type
+func (w *PulseProRolloutCustomWebhook) ValidateDelete(ctx context.Context, obj runtime.Object) (admission.Warnings, error) {
+	return nil, nil
+}
+
+// validateApprovalGates checks that gates are named uniquely and say who may approve them
+func validateApprovalGates(gates []ApprovalGate) error {
+	seen := make(map[string]bool)
+	for _, gate := range gates {
+		if gate.Name == "" {
+			return fmt.Errorf("approval gate name must not be empty")
+		}
+		if seen[gate.Name] {
+			return fmt.Errorf("approval gate %q is defined more than once", gate.Name)
+		}
+		seen[gate.Name] = true
+
+		if gate.Category == "" {
+			return fmt.Errorf("approval gate %q must set a category", gate.Name)
+		}
+		if len(gate.ApproverGroups) == 0 {
+			return fmt.Errorf("approval gate %q must list at least one approver group", gate.Name)
+		}
+	}
+	return nil
+}
+
+// gatesLocked reports whether the approval gates of a rollout may no longer change: once it has started or a
+// gate was approved, changing them would let users approve gates they were not allowed to
+func gatesLocked(rollout *PulseProRollout) bool {
+	switch rollout.Status.Phase {
+	case "", RolloutPhaseScheduled, RolloutPhaseDryRun:
+		return len(rollout.Status.Approvals) > 0
+	}
+	return true
+}
+
+// validateSchedule checks that the deadline of a scheduled rollout is after its start
+func validateSchedule(spec PulseProRolloutSpec) error {
+	if spec.StartAt != nil && spec.NotAfter != nil && !spec.NotAfter.After(spec.StartAt.Time) {
+		return fmt.Errorf("notAfter must be later than startAt")
+	}
+	return nil
+}
+
+// validateAnalysis checks that the analysis interval parses and that every metric defines exactly one usable check.
+// The metrics of templates are checked when they run, since templates may change after the rollout is admitted.
+func validateAnalysis(analysis *RolloutAnalysis) error {
+	if analysis == nil {
+		return nil
+	}
+	if analysis.Interval != "" {
+		if _, err := time.ParseDuration(analysis.Interval); err != nil {
+			return fmt.Errorf("invalid analysis interval %q: %v", analysis.Interval, err)
+		}
+	}
+	if len(analysis.Metrics) == 0 && len(analysis.Templates) == 0 {
+		return fmt.Errorf("analysis must define at least one metric or template")
+	}
+
+	for _, metric := range analysis.Metrics {
+		if (metric.Prometheus == nil) == (metric.HTTP == nil) {
+			return fmt.Errorf("analysis metric %q must set exactly one of prometheus or http", metric.Name)
+		}
+		if metric.Prometheus != nil {
+			if _, err := strconv.ParseFloat(metric.Prometheus.Threshold, 64); err != nil {
+				return fmt.Errorf("analysis metric %q has an invalid threshold %q", metric.Name, metric.Prometheus.Threshold)
+			}
+		}
+	}
+	return nil
+}
+
+// validateApproveAnnotation checks that a newly set approve annotation names a gate of spec the requesting user may approve
+func validateApproveAnnotation(ctx context.Context, rollout *PulseProRollout, spec PulseProRolloutSpec, oldGate string) error {
+	gateName := rollout.Annotations[ApproveAnnotation]
+	if gateName == "" || gateName == oldGate {
+		return nil
+	}
+
+	gate := spec.GateNamed(gateName)
+	if gate == nil {
+		return fmt.Errorf("annotation %s refers to unknown approval gate %q", ApproveAnnotation, gateName)
+	}
+
+	req, err := admission.RequestFromContext(ctx)
+	if err != nil {
+		return err
+	}
+	return authorizeApprover(gate, req.UserInfo)
+}
+
+// authorizeApprover checks that the user belongs to one of the gate's approver groups
+func authorizeApprover(gate *ApprovalGate, user authenticationv1.UserInfo) error {
+	for _, allowed := range gate.ApproverGroups {
+		for _, group := range user.Groups {
+			if group == allowed {
+				return nil
+			}
+		}
+	}
+	return fmt.Errorf("user %q is not a member of any approver group of gate %q", user.Username, gate.Name)
+}
@@ -0,0 +1,148 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"context"
+	"encoding/json"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	apimachineryruntime "k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+var _ = Describe("PulseProRollout Webhook", func() {
+	var rollout *PulseProRollout
+
+	requestBy := func(username string, groups ...string) context.Context {
+		return admission.NewContextWithRequest(context.Background(), admission.Request{
+			AdmissionRequest: admissionv1.AdmissionRequest{
+				UserInfo: authenticationv1.UserInfo{Username: username, Groups: groups},
+			},
+		})
+	}
+
+	BeforeEach(func() {
+		rollout = &PulseProRollout{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        "rollout",
+				Namespace:   "default",
+				Annotations: map[string]string{},
+			},
+			Spec: PulseProRolloutSpec{
+				Namespace:    "default",
+				ImageVersion: "2.4.0",
+				ApprovalGates: []ApprovalGate{{
+					Name:           "production",
+					Category:       "production",
+					ApproverGroups: []string{"release-managers"},
+				}},
+			},
+		}
+	})
+
+	Context("When approving a gate through the approve annotation", func() {
+		It("Should record the requesting user as approver", func() {
+			rollout.Annotations[ApproveAnnotation] = "production"
+			rollout.Annotations[ApprovedByAnnotation] = "someone-else"
+
+			Expect((&PulseProRolloutCustomWebhook{}).Default(requestBy("alice", "release-managers"), rollout)).To(Succeed())
+			Expect(rollout.Annotations[ApprovedByAnnotation]).To(Equal("alice"))
+		})
+
+		It("Should deny users outside the approver groups", func() {
+			rollout.Annotations[ApproveAnnotation] = "production"
+
+			_, err := (&PulseProRolloutCustomWebhook{}).ValidateCreate(requestBy("bob", "developers"), rollout)
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("Should admit users in an approver group", func() {
+			rollout.Annotations[ApproveAnnotation] = "production"
+
+			_, err := (&PulseProRolloutCustomWebhook{}).ValidateCreate(requestBy("alice", "release-managers"), rollout)
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("Should deny approvals of unknown gates", func() {
+			rollout.Annotations[ApproveAnnotation] = "staging"
+
+			_, err := (&PulseProRolloutCustomWebhook{}).ValidateCreate(requestBy("alice", "release-managers"), rollout)
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Context("When approving a gate in an update", func() {
+		var old *PulseProRollout
+
+		BeforeEach(func() {
+			old = rollout.DeepCopy()
+		})
+
+		It("Should check the approver against the gates before the update", func() {
+			rollout.Spec.ApprovalGates[0].ApproverGroups = append(rollout.Spec.ApprovalGates[0].ApproverGroups, "developers")
+			rollout.Annotations[ApproveAnnotation] = "production"
+
+			_, err := (&PulseProRolloutCustomWebhook{}).ValidateUpdate(requestBy("bob", "developers"), old, rollout)
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("Should deny changes to the gates once the rollout has started", func() {
+			rollout.Spec.ApprovalGates[0].ApproverGroups = []string{"developers"}
+			_, err := (&PulseProRolloutCustomWebhook{}).ValidateUpdate(requestBy("bob", "developers"), old, rollout)
+			Expect(err).NotTo(HaveOccurred())
+
+			old.Status.Phase = RolloutPhaseAwaitingApproval
+			_, err = (&PulseProRolloutCustomWebhook{}).ValidateUpdate(requestBy("bob", "developers"), old, rollout)
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("Should remove an approval once the image version changes", func() {
+			old.Annotations[ApproveAnnotation] = "production"
+			old.Annotations[ApprovedByAnnotation] = "alice"
+			raw, err := json.Marshal(old)
+			Expect(err).NotTo(HaveOccurred())
+			rollout.Annotations[ApproveAnnotation] = "production"
+			rollout.Annotations[ApprovedByAnnotation] = "alice"
+			rollout.Spec.ImageVersion = "2.5.0"
+
+			ctx := admission.NewContextWithRequest(context.Background(), admission.Request{
+				AdmissionRequest: admissionv1.AdmissionRequest{
+					UserInfo:  authenticationv1.UserInfo{Username: "bob"},
+					OldObject: apimachineryruntime.RawExtension{Raw: raw},
+				},
+			})
+			Expect((&PulseProRolloutCustomWebhook{}).Default(ctx, rollout)).To(Succeed())
+			Expect(rollout.Annotations).NotTo(HaveKey(ApproveAnnotation))
+			Expect(rollout.Annotations).NotTo(HaveKey(ApprovedByAnnotation))
+		})
+	})
+
+	Context("When validating approval gates", func() {
+		It("Should deny gates without approver groups", func() {
+			rollout.Spec.ApprovalGates[0].ApproverGroups = nil
+
+			_, err := (&PulseProRolloutCustomWebhook{}).ValidateCreate(requestBy("alice"), rollout)
+			Expect(err).To(HaveOccurred())
+		})
+	})
+})
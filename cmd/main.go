@@ -1,21 +1,26 @@
 package main
 
 import (
+	"bytes"
+	"context"
 	"crypto/tls"
 	"flag"
 	"fmt"
 	"os"
-	"os/exec"
+	"strings"
+	"time"
 
 	// Import all Kubernetes client auth plugins (e.g. Azure, GCP, OIDC, etc.)
 	// to ensure that exec-entrypoint and run can make use of them.
 	_ "k8s.io/client-go/plugin/pkg/client/auth"
 
 	"gopkg.in/yaml.v2"
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/healthz"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
 	"sigs.k8s.io/controller-runtime/pkg/metrics/filters"
@@ -23,7 +28,12 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/webhook"
 
 	pulseprov1alpha1 "github.com/smarter-contracts/pulsepro-operator/api/v1alpha1"
+	"github.com/smarter-contracts/pulsepro-operator/internal/command"
 	"github.com/smarter-contracts/pulsepro-operator/internal/controllers"
+	"github.com/smarter-contracts/pulsepro-operator/internal/gitcache"
+	"github.com/smarter-contracts/pulsepro-operator/internal/locks"
+	"github.com/smarter-contracts/pulsepro-operator/internal/receiver"
+	"github.com/smarter-contracts/pulsepro-operator/internal/registry"
 )
 
 var (
@@ -47,6 +57,17 @@ func main() {
 		enableHTTP2          bool
 		enableWebhooks       bool
 		kubeContext          string // Add kubeContext flag for local development
+		prometheusURL        string
+		analysisTimeout      time.Duration
+		timeouts             controllers.StepTimeouts
+		deploymentWorkers    int
+		rolloutWorkers       int
+		maxConcurrentHelm    int
+		workspaceRoot        string
+		gitReceiverAddr      string
+		gitReceiverSecret    string
+		verifiedCategories   string
+		registryProviders    string
 		tlsOpts              []func(*tls.Config)
 	)
 
@@ -54,6 +75,20 @@ func main() {
 	flag.StringVar(&metricsAddr, "metrics-bind-address", "0", "The address the metrics endpoint binds to.")
 	flag.StringVar(&probeAddr, "health-probe-bind-address", ":8081", "The address the probe endpoint binds to.")
 	flag.StringVar(&kubeContext, "kube-context", "", "The Kubernetes context to use for local development (leave empty for in-cluster config)")
+	flag.StringVar(&prometheusURL, "prometheus-url", "", "The Prometheus server queried by rollout analyses that do not set their own address.")
+	flag.DurationVar(&analysisTimeout, "analysis-timeout", 30*time.Second, "The maximum duration of each Prometheus query and HTTP check of rollout analyses.")
+	flag.DurationVar(&timeouts.GitSync, "git-sync-timeout", 2*time.Minute, "The maximum duration of cloning or pulling a GitOps repository.")
+	flag.DurationVar(&timeouts.DependencyCheck, "dependency-check-timeout", 30*time.Second, "The maximum duration of the connectivity check of each external service.")
+	flag.DurationVar(&timeouts.Helmfile, "helmfile-timeout", 15*time.Minute, "The maximum duration of each helmfile sync or diff.")
+	flag.DurationVar(&timeouts.ChartVerification, "chart-verification-timeout", 2*time.Minute, "The maximum duration of pulling and verifying the signature of a chart.")
+	flag.IntVar(&deploymentWorkers, "deployment-workers", 4, "The number of PulseProDeployments reconciled in parallel.")
+	flag.IntVar(&rolloutWorkers, "rollout-workers", 2, "The number of PulseProRollouts reconciled in parallel.")
+	flag.IntVar(&maxConcurrentHelm, "max-concurrent-helm", 4, "The maximum number of helmfile operations running at the same time (0 for no limit).")
+	flag.StringVar(&workspaceRoot, "workspace-root", "/tmp/repos", "The directory holding one checkout per GitOps repository.")
+	flag.StringVar(&gitReceiverAddr, "git-receiver-bind-address", "0", "The address the Git push webhook receiver binds to. Use 0 to disable it.")
+	flag.StringVar(&gitReceiverSecret, "git-receiver-secret-file", "", "The file holding the secret that Git push webhooks are signed with.")
+	flag.StringVar(&verifiedCategories, "verified-commit-categories", "production", "Comma-separated deployment categories that may only release commits signed by a trusted key.")
+	flag.StringVar(&registryProviders, "registry-token-providers", "", "Comma-separated host=provider pairs of registries that are authenticated with a token provider, e.g. europe-docker.pkg.dev=gcloud.")
 	flag.BoolVar(&enableLeaderElection, "leader-elect", false, "Enable leader election for controller manager.")
 	flag.BoolVar(&secureMetrics, "metrics-secure", true, "Serve the metrics endpoint securely via HTTPS.")
 	flag.BoolVar(&enableHTTP2, "enable-http2", false, "Enable HTTP/2 for the metrics and webhook servers.")
@@ -106,6 +141,11 @@ func main() {
 		HealthProbeBindAddress: probeAddr,
 		LeaderElection:         enableLeaderElection,
 		LeaderElectionID:       "cf2fb68a.pulsepro.io",
+		// Secrets are read from the API server on demand: the operator may only get them, and caching
+		// them would take a cluster-wide informer that lists and watches every Secret
+		Client: client.Options{
+			Cache: &client.CacheOptions{DisableFor: []client.Object{&corev1.Secret{}}},
+		},
 		// LeaderElectionReleaseOnCancel defines if the leader should step down voluntarily
 		// when the Manager ends. This requires the binary to immediately end when the
 		// Manager is stopped, otherwise, this setting is unsafe. Setting this significantly
@@ -124,25 +164,106 @@ func main() {
 		os.Exit(1)
 	}
 
+	// Set up the token providers of the registries the deployments have no credentials for
+	hostProviders := registry.HostProviders{}
+	for _, item := range splitList(registryProviders) {
+		host, name, ok := strings.Cut(item, "=")
+		if !ok {
+			setupLog.Error(fmt.Errorf("expected host=provider, got %q", item), "invalid --registry-token-providers")
+			os.Exit(1)
+		}
+		provider, err := registry.NewTokenProvider(name, timeouts.DependencyCheck)
+		if err != nil {
+			setupLog.Error(err, "invalid --registry-token-providers")
+			os.Exit(1)
+		}
+		hostProviders[host] = provider
+	}
+
 	// Register the PulseProDeploymentReconciler with the manager and pass kubeContext
-	if err := (&controllers.PulseProDeploymentReconciler{
+	deploymentReconciler := &controllers.PulseProDeploymentReconciler{
 		Client:      mgr.GetClient(),
 		Log:         ctrl.Log.WithName("controllers").WithName("PulseProDeployment"),
 		Scheme:      mgr.GetScheme(),
+		Recorder:    mgr.GetEventRecorderFor("pulseprodeployment-controller"),
 		KubeContext: kubeContext,
-	}).SetupWithManager(mgr); err != nil {
+		Timeouts:    timeouts,
+
+		MaxConcurrentReconciles: deploymentWorkers,
+		Repositories:            gitcache.New(workspaceRoot, timeouts.GitSync, ctrl.Log.WithName("gitcache")),
+		HelmSlots:               locks.NewSemaphore(maxConcurrentHelm),
+
+		VerifiedCommitCategories: splitList(verifiedCategories),
+		RegistryProviders:        hostProviders,
+	}
+	if err := deploymentReconciler.SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "PulseProDeployment")
 		os.Exit(1)
 	}
 
+	if err := (&controllers.PulseProRolloutReconciler{
+		Client:          mgr.GetClient(),
+		Scheme:          mgr.GetScheme(),
+		Recorder:        mgr.GetEventRecorderFor("pulseprorollout-controller"),
+		PrometheusURL:   prometheusURL,
+		AnalysisTimeout: analysisTimeout,
+		// Only the webhooks record who approved a gate
+		ApprovalsVerified: enableWebhooks,
+
+		MaxConcurrentReconciles: rolloutWorkers,
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "PulseProRollout")
+		os.Exit(1)
+	}
+
+	if err := (&controllers.PulseProImageWatchReconciler{
+		Client:   mgr.GetClient(),
+		Scheme:   mgr.GetScheme(),
+		Recorder: mgr.GetEventRecorderFor("pulseproimagewatch-controller"),
+
+		RegistryProviders: hostProviders,
+		Timeout:           timeouts.DependencyCheck,
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "PulseProImageWatch")
+		os.Exit(1)
+	}
+
+	// Serve the Git push webhook receiver if enabled
+	if gitReceiverAddr != "0" {
+		secret, err := os.ReadFile(gitReceiverSecret)
+		if err != nil || len(bytes.TrimSpace(secret)) == 0 {
+			setupLog.Error(err, "the Git push webhook receiver requires a non-empty --git-receiver-secret-file")
+			os.Exit(1)
+		}
+		if err := mgr.Add(&receiver.Server{
+			Addr: gitReceiverAddr,
+			Handler: &receiver.Receiver{
+				Secret: bytes.TrimSpace(secret),
+				Log:    ctrl.Log.WithName("git-receiver"),
+				OnPush: deploymentReconciler.HandlePush,
+			},
+		}); err != nil {
+			setupLog.Error(err, "unable to add the Git push webhook receiver")
+			os.Exit(1)
+		}
+	}
+
 	// Register webhook if enabled
 	if enableWebhooks {
 		if err = (&pulseprov1alpha1.PulseProDeployment{}).SetupWebhookWithManager(mgr); err != nil {
 			setupLog.Error(err, "unable to create webhook", "webhook", "PulseProDeployment")
 			os.Exit(1)
 		}
+		if err = (&pulseprov1alpha1.PulseProRollout{}).SetupWebhookWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "PulseProRollout")
+			os.Exit(1)
+		}
+		if err = (&pulseprov1alpha1.PulseProApproval{}).SetupWebhookWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "PulseProApproval")
+			os.Exit(1)
+		}
 	} else {
-		setupLog.Info("Webhooks are disabled.")
+		setupLog.Info("Webhooks are disabled; approvals of rollout gates are ignored.")
 	}
 
 	// Add health and readiness checks
@@ -191,6 +312,17 @@ func loadConfig(data string) (*PulseProValues, error) {
 	return &values, nil
 }
 
+// splitList splits a comma-separated flag value, dropping empty items
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
 // checkConnectivity pings the hostnames from the values
 func checkConnectivity(values *PulseProValues) error {
 	services := map[string]string{
@@ -205,11 +337,11 @@ func checkConnectivity(values *PulseProValues) error {
 		if host == "" {
 			return fmt.Errorf("hostname for %s is empty in the ConfigMap", service)
 		}
-		cmd := exec.Command("ping", "-c", "1", host)
+		cmd := command.New(context.Background(), 10*time.Second, "ping", "-c", "1", host)
 		if err := cmd.Run(); err != nil {
 			return fmt.Errorf("failed to connect to %s (%s): %v", service, host, err)
 		}
-		fmt.Printf("Successfully connected to %s (%s)\n", service, host)
+		setupLog.Info("Connected", "service", service, "host", host)
 	}
 	return nil
 }
@@ -0,0 +1,174 @@
+package analysis
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"text/template"
+	"time"
+
+	pulseprov1alpha1 "github.com/smarter-contracts/pulsepro-operator/api/v1alpha1"
+)
+
+// Target describes the deployment an analysis runs against.
+// Its fields are available to PromQL queries and URLs as template data.
+type Target struct {
+	Name        string
+	Namespace   string
+	Version     string
+	Category    string
+	Project     string
+	Environment string
+}
+
+// TargetFor returns the analysis target for a PulseProDeployment.
+// Namespace is the namespace PulsePro is deployed into, not that of the custom resource.
+func TargetFor(deployment *pulseprov1alpha1.PulseProDeployment) Target {
+	// A version range of the spec is reported as the exact version it resolved to
+	version := deployment.Spec.PulseProVersion
+	if resolved := deployment.Status.ResolvedVersions; resolved != nil && resolved.PulseProVersion != "" {
+		version = resolved.PulseProVersion
+	}
+	return Target{
+		Name:        deployment.Name,
+		Namespace:   deployment.Spec.Namespace,
+		Version:     version,
+		Category:    deployment.Spec.Category,
+		Project:     deployment.Spec.ProjectName,
+		Environment: deployment.Spec.EnvironmentName,
+	}
+}
+
+// DefaultTimeout bounds each Prometheus query and HTTP check of runners without their own HTTP client
+const DefaultTimeout = 30 * time.Second
+
+// defaultClient gives up on checks whose server does not answer, so that they fail instead of stalling the rollout
+var defaultClient = &http.Client{Timeout: DefaultTimeout}
+
+// Runner runs the metrics of a rollout analysis
+type Runner struct {
+	// PrometheusURL is used by Prometheus checks that do not set their own address
+	PrometheusURL string
+
+	// HTTPClient is used for Prometheus queries and HTTP checks; a client with DefaultTimeout when nil
+	HTTPClient *http.Client
+}
+
+// Run runs every metric against the target and returns an error describing the first failing one.
+// A check that cannot be evaluated, e.g. because Prometheus is unreachable, counts as failing.
+func (r *Runner) Run(ctx context.Context, metrics []pulseprov1alpha1.AnalysisMetric, target Target) error {
+	for _, metric := range metrics {
+		var err error
+		switch {
+		case metric.Prometheus != nil:
+			err = r.runPrometheus(ctx, metric.Prometheus, target)
+		case metric.HTTP != nil:
+			err = r.runHTTP(ctx, metric.HTTP, target)
+		default:
+			err = fmt.Errorf("no check defined")
+		}
+		if err != nil {
+			return fmt.Errorf("analysis %q failed for %s: %v", metric.Name, target.Name, err)
+		}
+	}
+	return nil
+}
+
+// runPrometheus runs the query and compares its value with the threshold
+func (r *Runner) runPrometheus(ctx context.Context, check *pulseprov1alpha1.PrometheusCheck, target Target) error {
+	query, err := render(check.Query, target)
+	if err != nil {
+		return err
+	}
+	threshold, err := strconv.ParseFloat(check.Threshold, 64)
+	if err != nil {
+		return fmt.Errorf("invalid threshold %q: %v", check.Threshold, err)
+	}
+
+	address := check.Address
+	if address == "" {
+		address = r.PrometheusURL
+	}
+	client := &PrometheusClient{URL: address, HTTPClient: r.httpClient()}
+	value, err := client.Query(ctx, query)
+	if err != nil {
+		return err
+	}
+
+	ok, err := compare(value, check.Operator, threshold)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return fmt.Errorf("query result %g is not %s %g", value, check.Operator, threshold)
+	}
+	return nil
+}
+
+// runHTTP requests the URL and checks the response status
+func (r *Runner) runHTTP(ctx context.Context, check *pulseprov1alpha1.HTTPCheck, target Target) error {
+	address, err := render(check.URL, target)
+	if err != nil {
+		return err
+	}
+	expected := check.ExpectedStatus
+	if expected == 0 {
+		expected = http.StatusOK
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, address, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := r.httpClient().Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != expected {
+		return fmt.Errorf("received HTTP status %d from %s, expected %d", resp.StatusCode, address, expected)
+	}
+	return nil
+}
+
+func (r *Runner) httpClient() *http.Client {
+	if r.HTTPClient == nil {
+		return defaultClient
+	}
+	return r.HTTPClient
+}
+
+// render executes text as a Go template with the target as data
+func render(text string, target Target) (string, error) {
+	tmpl, err := template.New("check").Option("missingkey=error").Parse(text)
+	if err != nil {
+		return "", fmt.Errorf("invalid template %q: %v", text, err)
+	}
+	var out bytes.Buffer
+	if err := tmpl.Execute(&out, target); err != nil {
+		return "", fmt.Errorf("failed to render template %q: %v", text, err)
+	}
+	return out.String(), nil
+}
+
+// compare evaluates "value operator threshold"
+func compare(value float64, operator string, threshold float64) (bool, error) {
+	switch operator {
+	case ">":
+		return value > threshold, nil
+	case ">=":
+		return value >= threshold, nil
+	case "<":
+		return value < threshold, nil
+	case "<=":
+		return value <= threshold, nil
+	case "==":
+		return value == threshold, nil
+	case "!=":
+		return value != threshold, nil
+	}
+	return false, fmt.Errorf("unknown operator %q", operator)
+}
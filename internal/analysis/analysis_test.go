@@ -0,0 +1,87 @@
+package analysis
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	pulseprov1alpha1 "github.com/smarter-contracts/pulsepro-operator/api/v1alpha1"
+)
+
+var _ = Describe("Analysis Runner", func() {
+	var (
+		prometheus *httptest.Server
+		lastQuery  string
+		result     string
+		runner     *Runner
+		target     Target
+	)
+
+	BeforeEach(func() {
+		result = `{"status":"success","data":{"resultType":"vector","result":[{"metric":{},"value":[1700000000,"0.01"]}]}}`
+		prometheus = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			lastQuery = r.URL.Query().Get("query")
+			_, _ = fmt.Fprint(w, result)
+		}))
+		runner = &Runner{PrometheusURL: prometheus.URL}
+		target = Target{Name: "acme-prod", Namespace: "pulsepro-acme", Version: "2.4.0"}
+	})
+
+	AfterEach(func() {
+		prometheus.Close()
+	})
+
+	errorRate := func(operator, threshold string) []pulseprov1alpha1.AnalysisMetric {
+		return []pulseprov1alpha1.AnalysisMetric{{
+			Name: "error-rate",
+			Prometheus: &pulseprov1alpha1.PrometheusCheck{
+				Query:     `sum(rate(http_errors_total{namespace="{{ .Namespace }}"}[5m]))`,
+				Operator:  operator,
+				Threshold: threshold,
+			},
+		}}
+	}
+
+	It("should render the query with the target and pass when the threshold holds", func() {
+		Expect(runner.Run(context.Background(), errorRate("<", "0.05"), target)).To(Succeed())
+		Expect(lastQuery).To(Equal(`sum(rate(http_errors_total{namespace="pulsepro-acme"}[5m]))`))
+	})
+
+	It("should fail when the threshold does not hold", func() {
+		Expect(runner.Run(context.Background(), errorRate(">", "0.05"), target)).To(MatchError(ContainSubstring("error-rate")))
+	})
+
+	It("should accept scalar results", func() {
+		result = `{"status":"success","data":{"resultType":"scalar","result":[1700000000,"3"]}}`
+		Expect(runner.Run(context.Background(), errorRate("==", "3"), target)).To(Succeed())
+	})
+
+	It("should fail when the query returns no samples", func() {
+		result = `{"status":"success","data":{"resultType":"vector","result":[]}}`
+		Expect(runner.Run(context.Background(), errorRate("<", "0.05"), target)).NotTo(Succeed())
+	})
+
+	It("should check the status of HTTP endpoints", func() {
+		health := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/acme-prod/health" {
+				w.WriteHeader(http.StatusOK)
+				return
+			}
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}))
+		defer health.Close()
+
+		metrics := []pulseprov1alpha1.AnalysisMetric{{
+			Name: "health",
+			HTTP: &pulseprov1alpha1.HTTPCheck{URL: health.URL + "/{{ .Name }}/health"},
+		}}
+		Expect(runner.Run(context.Background(), metrics, target)).To(Succeed())
+
+		metrics[0].HTTP.URL = health.URL + "/other/health"
+		Expect(runner.Run(context.Background(), metrics, target)).NotTo(Succeed())
+	})
+})
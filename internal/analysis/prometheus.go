@@ -0,0 +1,93 @@
+package analysis
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// PrometheusClient runs instant queries against the Prometheus HTTP API
+type PrometheusClient struct {
+	// URL is the base address of the Prometheus server, e.g. http://prometheus:9090
+	URL string
+
+	// HTTPClient is used for requests; a client with DefaultTimeout when nil
+	HTTPClient *http.Client
+}
+
+// prometheusResponse is the part of the /api/v1/query response the client reads
+type prometheusResponse struct {
+	Status string `json:"status"`
+	Error  string `json:"error"`
+	Data   struct {
+		ResultType string          `json:"resultType"`
+		Result     json.RawMessage `json:"result"`
+	} `json:"data"`
+}
+
+// Query runs an instant PromQL query and returns its value.
+// The query must evaluate to a scalar or to a vector with exactly one sample.
+func (c *PrometheusClient) Query(ctx context.Context, query string) (float64, error) {
+	if c.URL == "" {
+		return 0, fmt.Errorf("no Prometheus address configured")
+	}
+
+	endpoint := strings.TrimSuffix(c.URL, "/") + "/api/v1/query?" + url.Values{"query": {query}}.Encode()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return 0, err
+	}
+
+	httpClient := c.HTTPClient
+	if httpClient == nil {
+		httpClient = defaultClient
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("failed to query Prometheus: %v", err)
+	}
+	defer resp.Body.Close()
+
+	var body prometheusResponse
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return 0, fmt.Errorf("failed to decode Prometheus response (HTTP %d): %v", resp.StatusCode, err)
+	}
+	if body.Status != "success" {
+		return 0, fmt.Errorf("prometheus query failed: %s", body.Error)
+	}
+
+	var sample []interface{}
+	switch body.Data.ResultType {
+	case "scalar":
+		if err := json.Unmarshal(body.Data.Result, &sample); err != nil {
+			return 0, fmt.Errorf("failed to decode scalar result: %v", err)
+		}
+	case "vector":
+		var vector []struct {
+			Value []interface{} `json:"value"`
+		}
+		if err := json.Unmarshal(body.Data.Result, &vector); err != nil {
+			return 0, fmt.Errorf("failed to decode vector result: %v", err)
+		}
+		if len(vector) != 1 {
+			return 0, fmt.Errorf("query returned %d samples, expected exactly one", len(vector))
+		}
+		sample = vector[0].Value
+	default:
+		return 0, fmt.Errorf("unsupported result type %q", body.Data.ResultType)
+	}
+
+	// Samples are [<unix time>, "<value>"]
+	if len(sample) != 2 {
+		return 0, fmt.Errorf("malformed sample %v", sample)
+	}
+	value, ok := sample[1].(string)
+	if !ok {
+		return 0, fmt.Errorf("malformed sample value %v", sample[1])
+	}
+	return strconv.ParseFloat(value, 64)
+}
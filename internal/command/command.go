@@ -0,0 +1,98 @@
+package command
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"time"
+)
+
+// waitDelay bounds how long Wait keeps reading the output of a killed process,
+// e.g. when a grandchild outside the process group still holds the pipes open
+const waitDelay = 5 * time.Second
+
+// TimeoutError is returned when a command did not finish within its timeout
+type TimeoutError struct {
+	// Command is the name of the command that timed out
+	Command string
+
+	// Timeout is the timeout the command exceeded
+	Timeout time.Duration
+
+	// Err is the error the killed command returned
+	Err error
+}
+
+func (e *TimeoutError) Error() string {
+	return fmt.Sprintf("%s timed out after %s", e.Command, e.Timeout)
+}
+
+func (e *TimeoutError) Unwrap() error {
+	return e.Err
+}
+
+// IsTimeout reports whether err is or wraps a TimeoutError
+func IsTimeout(err error) bool {
+	var timeoutErr *TimeoutError
+	return errors.As(err, &timeoutErr)
+}
+
+// Cmd is an external command bound to a context and a timeout. When either ends,
+// the whole process group of the command is killed, so helpers spawned by
+// helm or helmfile do not outlive it.
+type Cmd struct {
+	*exec.Cmd
+
+	ctx     context.Context
+	cancel  context.CancelFunc
+	timeout time.Duration
+}
+
+// New returns a command that is killed when ctx is done or timeout has passed; a zero timeout only uses ctx
+func New(ctx context.Context, timeout time.Duration, name string, args ...string) *Cmd {
+	cancel := context.CancelFunc(func() {})
+	if timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+	}
+
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.WaitDelay = waitDelay
+	setProcessGroup(cmd)
+	return &Cmd{Cmd: cmd, ctx: ctx, cancel: cancel, timeout: timeout}
+}
+
+// Run starts the command and waits for it to finish
+func (c *Cmd) Run() error {
+	defer c.cancel()
+	return c.wrap(c.Cmd.Run())
+}
+
+// Output runs the command and returns its standard output
+func (c *Cmd) Output() ([]byte, error) {
+	defer c.cancel()
+	output, err := c.Cmd.Output()
+	return output, c.wrap(err)
+}
+
+// CombinedOutput runs the command and returns its combined standard output and standard error
+func (c *Cmd) CombinedOutput() ([]byte, error) {
+	defer c.cancel()
+	output, err := c.Cmd.CombinedOutput()
+	return output, c.wrap(err)
+}
+
+// wrap turns the error of a command killed by its timeout into a TimeoutError, and of a command
+// killed by cancellation into an error wrapping context.Canceled
+func (c *Cmd) wrap(err error) error {
+	if err == nil {
+		return nil
+	}
+	switch {
+	case errors.Is(c.ctx.Err(), context.DeadlineExceeded):
+		return &TimeoutError{Command: c.Args[0], Timeout: c.timeout, Err: err}
+	case errors.Is(c.ctx.Err(), context.Canceled):
+		return fmt.Errorf("%s was cancelled: %w", c.Args[0], context.Canceled)
+	}
+	return err
+}
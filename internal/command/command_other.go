@@ -0,0 +1,8 @@
+//go:build !unix
+
+package command
+
+import "os/exec"
+
+// setProcessGroup keeps the default cancellation, which only kills the command itself
+func setProcessGroup(cmd *exec.Cmd) {}
@@ -0,0 +1,49 @@
+package command
+
+import (
+	"context"
+	"errors"
+	"os/exec"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Command", func() {
+	It("should return the output of a command that finishes in time", func() {
+		output, err := New(context.Background(), time.Minute, "sh", "-c", "echo ok").CombinedOutput()
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(output)).To(Equal("ok\n"))
+	})
+
+	It("should keep the exit error of a failing command", func() {
+		err := New(context.Background(), time.Minute, "sh", "-c", "exit 2").Run()
+
+		var exitErr *exec.ExitError
+		Expect(errors.As(err, &exitErr)).To(BeTrue())
+		Expect(exitErr.ExitCode()).To(Equal(2))
+		Expect(IsTimeout(err)).To(BeFalse())
+	})
+
+	It("should kill the process group and report a timeout", func() {
+		start := time.Now()
+		// The background sleep keeps the output pipe open unless the whole group is killed
+		_, err := New(context.Background(), 200*time.Millisecond, "sh", "-c", "sleep 30 & sleep 30").CombinedOutput()
+
+		Expect(IsTimeout(err)).To(BeTrue())
+		Expect(err.Error()).To(Equal("sh timed out after 200ms"))
+		Expect(time.Since(start)).To(BeNumerically("<", 3*time.Second))
+	})
+
+	It("should report cancellation of the parent context", func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		time.AfterFunc(100*time.Millisecond, cancel)
+
+		err := New(ctx, time.Minute, "sleep", "30").Run()
+
+		Expect(errors.Is(err, context.Canceled)).To(BeTrue())
+		Expect(IsTimeout(err)).To(BeFalse())
+	})
+})
@@ -0,0 +1,14 @@
+package command
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func TestCommand(t *testing.T) {
+	RegisterFailHandler(Fail)
+
+	RunSpecs(t, "Command Suite")
+}
@@ -0,0 +1,217 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	pulseprov1alpha1 "github.com/smarter-contracts/pulsepro-operator/api/v1alpha1"
+	"github.com/smarter-contracts/pulsepro-operator/internal/command"
+	"github.com/smarter-contracts/pulsepro-operator/internal/provenance"
+	"github.com/smarter-contracts/pulsepro-operator/internal/redact"
+	"github.com/smarter-contracts/pulsepro-operator/internal/registry"
+	"github.com/smarter-contracts/pulsepro-operator/internal/retry"
+)
+
+// verifyChart checks that the deployment's chart is signed by a key the deployment trusts and records the
+// verified digest in status. It returns the path of the verified chart archive, which the release installs
+// instead of pulling the chart version again, and a cleanup removing it. It sets the ChartVerified condition
+// and returns a classified error when the chart must not be released.
+func (r *PulseProDeploymentReconciler) verifyChart(ctx context.Context, instance *pulseprov1alpha1.PulseProDeployment, redactor *redact.Redactor, target helmTarget, timeout time.Duration) (string, func(), error) {
+	setCondition := func(status metav1.ConditionStatus, reason, message string) {
+		meta.SetStatusCondition(&instance.Status.Conditions, metav1.Condition{
+			Type:               pulseprov1alpha1.ConditionChartVerified,
+			Status:             status,
+			Reason:             reason,
+			Message:            message,
+			ObservedGeneration: instance.Generation,
+		})
+	}
+
+	verification := instance.Spec.ChartVerification
+	if verification == nil {
+		meta.RemoveStatusCondition(&instance.Status.Conditions, pulseprov1alpha1.ConditionChartVerified)
+		instance.Status.VerifiedChartDigest = ""
+		return "", func() {}, nil
+	}
+
+	secret := &corev1.Secret{}
+	if err := r.Get(ctx, types.NamespacedName{Name: verification.SecretName, Namespace: instance.Namespace}, secret); err != nil {
+		notFound := errors.IsNotFound(err)
+		err = fmt.Errorf("unable to read trusted chart keys from Secret %s: %v", verification.SecretName, err)
+		setCondition(metav1.ConditionFalse, pulseprov1alpha1.ReasonTrustedKeysUnavailable, err.Error())
+		if notFound {
+			return "", nil, retry.Configuration("TrustedKeysMissing", err)
+		}
+		return "", nil, retry.Transient("TrustedKeysUnavailable", err)
+	}
+
+	dir, err := os.MkdirTemp("", "chart-verification-")
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to create chart directory: %v", err)
+	}
+	cleanup := func() { _ = os.RemoveAll(dir) }
+
+	chart, version := instance.Spec.HelmChart, desiredChartVersion(instance)
+	var result provenance.Result
+	switch verification.Provider {
+	case pulseprov1alpha1.ChartVerificationCosign:
+		result, err = verifyCosignSignature(ctx, timeout, dir, chart, version, secret.Data, verification.IgnoreTransparencyLog, target)
+	default:
+		result, err = verifyHelmProvenance(ctx, timeout, dir, chart, version, secret.Data, target)
+	}
+	var archive string
+	if err == nil {
+		archive, err = chartArchive(dir)
+	}
+	if keysErr, invalidKeys := err.(*invalidKeysError); invalidKeys {
+		cleanup()
+		keysErr.secret = verification.SecretName
+		setCondition(metav1.ConditionFalse, pulseprov1alpha1.ReasonTrustedKeysUnavailable, keysErr.Error())
+		return "", nil, retry.Configuration("InvalidTrustedKeys", keysErr)
+	}
+	if err != nil {
+		cleanup()
+		err = redactor.Error(err)
+		instance.Status.VerifiedChartDigest = ""
+		setCondition(metav1.ConditionFalse, pulseprov1alpha1.ReasonChartRejected, err.Error())
+		if command.IsTimeout(err) {
+			return "", nil, retry.Transient("ChartVerificationTimedOut", err)
+		}
+		return "", nil, retry.Configuration(pulseprov1alpha1.ReasonChartRejected, err)
+	}
+
+	instance.Status.VerifiedChartDigest = result.Digest
+	message := fmt.Sprintf("Chart %s %s (%s) is signed", chart, version, result.Digest)
+	if result.Signer != "" {
+		message += " by " + result.Signer
+	}
+	setCondition(metav1.ConditionTrue, pulseprov1alpha1.ReasonChartSigned, message)
+	return archive, cleanup, nil
+}
+
+// chartArchive returns the path of the chart archive pulled into the charts directory of dir
+func chartArchive(dir string) (string, error) {
+	archives, err := filepath.Glob(filepath.Join(dir, "charts", "*.tgz"))
+	if err != nil || len(archives) != 1 {
+		return "", fmt.Errorf("expected one pulled chart archive, found %d", len(archives))
+	}
+	return archives[0], nil
+}
+
+// invalidKeysError is returned when the trusted keys of a chart verification cannot be used
+type invalidKeysError struct {
+	secret string
+	err    error
+}
+
+func (e *invalidKeysError) Error() string {
+	return fmt.Sprintf("invalid trusted chart keys in Secret %s: %v", e.secret, e.err)
+}
+
+// verifyHelmProvenance pulls the chart into the charts directory of dir with its provenance file and has helm
+// verify it against the keys
+func verifyHelmProvenance(ctx context.Context, timeout time.Duration, dir, chart, version string, keys map[string][]byte, target helmTarget) (provenance.Result, error) {
+	keyring := filepath.Join(dir, "keyring.gpg")
+	if err := provenance.WriteKeyring(keyring, keys); err != nil {
+		return provenance.Result{}, &invalidKeysError{err: err}
+	}
+
+	args := []string{"pull", chart, "--verify", "--keyring", keyring, "--destination", filepath.Join(dir, "charts")}
+	if version != "" {
+		args = append(args, "--version", version)
+	}
+	cmd := command.New(ctx, timeout, "helm", args...)
+	if env := target.registryEnv(); len(env) > 0 {
+		cmd.Env = append(os.Environ(), env...)
+	}
+	output, err := cmd.CombinedOutput()
+	if command.IsTimeout(err) {
+		return provenance.Result{}, fmt.Errorf("chart verification failed: %w", err)
+	}
+	if err != nil {
+		return provenance.Result{}, fmt.Errorf("chart %s %s failed provenance verification: %v\nOutput: %s", chart, version, err, strings.TrimSpace(string(output)))
+	}
+	return provenance.ParseHelmVerification(string(output))
+}
+
+// verifyCosignSignature has cosign verify the signature of the OCI chart with each key until one matches, and
+// pulls the chart into the charts directory of dir, checking that it is the signed manifest
+func verifyCosignSignature(ctx context.Context, timeout time.Duration, dir, chart, version string, keys map[string][]byte, ignoreTlog bool, target helmTarget) (provenance.Result, error) {
+	ref, err := provenance.CosignReference(chart, version)
+	if err != nil {
+		return provenance.Result{}, err
+	}
+	publicKeys, err := provenance.CosignKeys(keys)
+	if err != nil {
+		return provenance.Result{}, &invalidKeysError{err: err}
+	}
+
+	var failures []string
+	for i, publicKey := range publicKeys {
+		keyFile := filepath.Join(dir, fmt.Sprintf("cosign-%d.pub", i))
+		if err := os.WriteFile(keyFile, publicKey, 0o600); err != nil {
+			return provenance.Result{}, fmt.Errorf("failed to write cosign key: %v", err)
+		}
+
+		args := []string{"verify", "--key", keyFile, "--output", "json"}
+		if ignoreTlog {
+			args = append(args, "--insecure-ignore-tlog=true")
+		}
+		if registry.IsLocal(registry.Host(ref)) {
+			args = append(args, "--allow-http-registry=true")
+		}
+		cmd := command.New(ctx, timeout, "cosign", append(args, ref)...)
+		if env := target.registryEnv(); len(env) > 0 {
+			cmd.Env = append(os.Environ(), env...)
+		}
+		var stderr strings.Builder
+		cmd.Stderr = &stderr
+		output, err := cmd.Output()
+		if command.IsTimeout(err) {
+			return provenance.Result{}, fmt.Errorf("chart verification failed: %w", err)
+		}
+		if err == nil {
+			result, err := provenance.ParseCosignVerification(output)
+			if err != nil {
+				return provenance.Result{}, err
+			}
+			return result, pullSignedChart(ctx, timeout, dir, chart, version, result.Digest, target)
+		}
+		failures = append(failures, strings.TrimSpace(stderr.String()))
+	}
+	return provenance.Result{}, fmt.Errorf("chart %s has no cosign signature made by a trusted key: %s", ref, strings.Join(failures, "; "))
+}
+
+// pullSignedChart pulls the OCI chart into the charts directory of dir and checks that the tag still points at
+// the manifest whose signature was verified
+func pullSignedChart(ctx context.Context, timeout time.Duration, dir, chart, version, digest string, target helmTarget) error {
+	cmd := command.New(ctx, timeout, "helm", "pull", chart, "--version", version, "--destination", filepath.Join(dir, "charts"))
+	if env := target.registryEnv(); len(env) > 0 {
+		cmd.Env = append(os.Environ(), env...)
+	}
+	output, err := cmd.CombinedOutput()
+	if command.IsTimeout(err) {
+		return fmt.Errorf("chart verification failed: %w", err)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to pull chart %s %s: %v\nOutput: %s", chart, version, err, strings.TrimSpace(string(output)))
+	}
+	pulled, err := provenance.ParsePullDigest(string(output))
+	if err != nil {
+		return err
+	}
+	if pulled != digest {
+		return fmt.Errorf("chart %s %s was pulled as %s, not as the signed %s", chart, version, pulled, digest)
+	}
+	return nil
+}
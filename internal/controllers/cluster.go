@@ -0,0 +1,194 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/tools/clientcmd"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+
+	pulseprov1alpha1 "github.com/smarter-contracts/pulsepro-operator/api/v1alpha1"
+	"github.com/smarter-contracts/pulsepro-operator/internal/command"
+	"github.com/smarter-contracts/pulsepro-operator/internal/redact"
+	"github.com/smarter-contracts/pulsepro-operator/internal/retry"
+)
+
+// defaultKubeconfigKey is the key of the kubeconfig in a kubeconfig Secret that does not set one
+const defaultKubeconfigKey = "kubeconfig"
+
+// helmTarget is the cluster the Helm operations of a deployment run against, and the registries they pull from
+type helmTarget struct {
+	// kubeconfig is the path of the kubeconfig file, empty for the operator's own configuration
+	kubeconfig string
+	// context is the kubeconfig context, empty for the current context
+	context string
+	// registryConfig is the path of the registry credentials file, empty when no registry needs credentials
+	registryConfig string
+	// chart is the path of the verified chart archive, empty when the chart is not verified
+	chart string
+}
+
+// apply points a helm or helmfile command at the cluster and the registry credentials
+func (t helmTarget) apply(cmd *command.Cmd) {
+	if t.context != "" {
+		cmd.Args = append(cmd.Args, "--kube-context", t.context)
+	}
+	env := t.registryEnv()
+	if t.kubeconfig != "" {
+		env = append(env, "KUBECONFIG="+t.kubeconfig)
+	}
+	if len(env) > 0 {
+		cmd.Env = append(os.Environ(), env...)
+	}
+}
+
+// chartStateValues passes the verified chart archive and its digest to helmfile as the state values helmChart
+// and helmChartDigest, so that the release installs the chart that was verified rather than pulling its version again
+func (t helmTarget) chartStateValues(instance *pulseprov1alpha1.PulseProDeployment) []string {
+	if t.chart == "" {
+		return nil
+	}
+	return []string{
+		"--state-values-set", "helmChart=" + t.chart,
+		"--state-values-set", "helmChartDigest=" + instance.Status.VerifiedChartDigest,
+	}
+}
+
+// registryEnv returns the environment pointing helm and cosign at the registry credentials
+func (t helmTarget) registryEnv() []string {
+	if t.registryConfig == "" {
+		return nil
+	}
+	return []string{"HELM_REGISTRY_CONFIG=" + t.registryConfig, "DOCKER_CONFIG=" + filepath.Dir(t.registryConfig)}
+}
+
+// clusterFor returns the target cluster of the deployment. With a kubeconfig Secret, it checks that the
+// cluster accepts the credentials, sets the ClusterConnected condition and writes the kubeconfig to a
+// temporary file that the returned cleanup removes.
+func (r *PulseProDeploymentReconciler) clusterFor(ctx context.Context, instance *pulseprov1alpha1.PulseProDeployment, redactor *redact.Redactor, timeout time.Duration) (helmTarget, func(), error) {
+	ref := instance.Spec.KubeconfigSecretRef
+	if ref == nil {
+		meta.RemoveStatusCondition(&instance.Status.Conditions, pulseprov1alpha1.ConditionClusterConnected)
+		return helmTarget{context: r.KubeContext}, func() {}, nil
+	}
+
+	setCondition := func(status metav1.ConditionStatus, reason, message string) {
+		meta.SetStatusCondition(&instance.Status.Conditions, metav1.Condition{
+			Type:               pulseprov1alpha1.ConditionClusterConnected,
+			Status:             status,
+			Reason:             reason,
+			Message:            message,
+			ObservedGeneration: instance.Generation,
+		})
+	}
+
+	secret := &corev1.Secret{}
+	if err := r.Get(ctx, types.NamespacedName{Name: ref.Name, Namespace: instance.Namespace}, secret); err != nil {
+		notFound := errors.IsNotFound(err)
+		err = fmt.Errorf("unable to read kubeconfig Secret %s: %v", ref.Name, err)
+		setCondition(metav1.ConditionFalse, pulseprov1alpha1.ReasonKubeconfigInvalid, err.Error())
+		if notFound {
+			return helmTarget{}, nil, retry.Configuration("KubeconfigMissing", err)
+		}
+		return helmTarget{}, nil, retry.Transient("KubeconfigUnavailable", err)
+	}
+	key := ref.Key
+	if key == "" {
+		key = defaultKubeconfigKey
+	}
+	data, ok := secret.Data[key]
+	if !ok {
+		err := fmt.Errorf("kubeconfig Secret %s has no key %s", ref.Name, key)
+		setCondition(metav1.ConditionFalse, pulseprov1alpha1.ReasonKubeconfigInvalid, err.Error())
+		return helmTarget{}, nil, retry.Configuration("KubeconfigMissing", err)
+	}
+
+	config, err := clientcmd.Load(data)
+	if err != nil {
+		err = fmt.Errorf("invalid kubeconfig in Secret %s: %v", ref.Name, err)
+		setCondition(metav1.ConditionFalse, pulseprov1alpha1.ReasonKubeconfigInvalid, err.Error())
+		return helmTarget{}, nil, retry.Configuration("InvalidKubeconfig", err)
+	}
+	for _, authInfo := range config.AuthInfos {
+		redactor.Add(authInfo.Token, authInfo.Password)
+	}
+	if err := checkKubeconfig(config); err != nil {
+		err = fmt.Errorf("kubeconfig in Secret %s is not allowed: %v", ref.Name, err)
+		setCondition(metav1.ConditionFalse, pulseprov1alpha1.ReasonKubeconfigInvalid, err.Error())
+		return helmTarget{}, nil, retry.Configuration("KubeconfigNotAllowed", err)
+	}
+	restConfig, err := clientcmd.NewDefaultClientConfig(*config, &clientcmd.ConfigOverrides{CurrentContext: ref.Context}).ClientConfig()
+	if err != nil {
+		err = fmt.Errorf("invalid kubeconfig in Secret %s: %v", ref.Name, err)
+		setCondition(metav1.ConditionFalse, pulseprov1alpha1.ReasonKubeconfigInvalid, err.Error())
+		return helmTarget{}, nil, retry.Configuration("InvalidKubeconfig", err)
+	}
+
+	// Check the credentials before any Helm operation, so that they are reported per deployment
+	restConfig.Timeout = timeout
+	discoveryClient, err := discovery.NewDiscoveryClientForConfig(restConfig)
+	if err != nil {
+		err = fmt.Errorf("invalid kubeconfig in Secret %s: %v", ref.Name, err)
+		setCondition(metav1.ConditionFalse, pulseprov1alpha1.ReasonKubeconfigInvalid, err.Error())
+		return helmTarget{}, nil, retry.Configuration("InvalidKubeconfig", err)
+	}
+	version, err := discoveryClient.ServerVersion()
+	if err != nil {
+		if errors.IsUnauthorized(err) || errors.IsForbidden(err) {
+			err = fmt.Errorf("cluster %s rejected the credentials: %v", restConfig.Host, err)
+			setCondition(metav1.ConditionFalse, pulseprov1alpha1.ReasonCredentialsRejected, err.Error())
+			return helmTarget{}, nil, retry.Configuration("ClusterCredentialsRejected", err)
+		}
+		err = fmt.Errorf("cluster %s is unreachable: %v", restConfig.Host, err)
+		setCondition(metav1.ConditionFalse, pulseprov1alpha1.ReasonClusterUnreachable, err.Error())
+		return helmTarget{}, nil, retry.Transient("ClusterUnreachable", err)
+	}
+	setCondition(metav1.ConditionTrue, pulseprov1alpha1.ReasonConnected, fmt.Sprintf("Connected to %s (Kubernetes %s)", restConfig.Host, version.GitVersion))
+
+	file, err := os.CreateTemp("", "kubeconfig-")
+	if err != nil {
+		return helmTarget{}, nil, fmt.Errorf("failed to write kubeconfig: %v", err)
+	}
+	cleanup := func() { _ = os.Remove(file.Name()) }
+	_, err = file.Write(data)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		cleanup()
+		return helmTarget{}, nil, fmt.Errorf("failed to write kubeconfig: %v", err)
+	}
+	return helmTarget{kubeconfig: file.Name(), context: ref.Context}, cleanup, nil
+}
+
+// checkKubeconfig rejects kubeconfigs that run commands or read files of the operator's pod, so that a
+// kubeconfig Secret can only use the credentials embedded in it
+func checkKubeconfig(config *clientcmdapi.Config) error {
+	for name, authInfo := range config.AuthInfos {
+		switch {
+		case authInfo.Exec != nil:
+			return fmt.Errorf("user %s runs an exec credential plugin", name)
+		case authInfo.AuthProvider != nil:
+			return fmt.Errorf("user %s uses the auth provider %s", name, authInfo.AuthProvider.Name)
+		case authInfo.TokenFile != "":
+			return fmt.Errorf("user %s reads its token from a file", name)
+		case authInfo.ClientCertificate != "" || authInfo.ClientKey != "":
+			return fmt.Errorf("user %s reads its client certificate from a file", name)
+		}
+	}
+	for name, cluster := range config.Clusters {
+		if cluster.CertificateAuthority != "" {
+			return fmt.Errorf("cluster %s reads its certificate authority from a file", name)
+		}
+	}
+	return nil
+}
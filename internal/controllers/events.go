@@ -0,0 +1,112 @@
+package controllers
+
+// Reasons of the events recorded on PulseProDeployments
+const (
+	// EventReasonValuesMissing means the ConfigMap holding the Helm values could not be read
+	EventReasonValuesMissing = "ValuesMissing"
+
+	// EventReasonValuesTemplateFailed means the values template of the environment could not be rendered
+	EventReasonValuesTemplateFailed = "ValuesTemplateFailed"
+
+	// EventReasonEffectiveValuesFailed means the effective values could not be published to their ConfigMap
+	EventReasonEffectiveValuesFailed = "EffectiveValuesFailed"
+
+	// EventReasonValuesMirrorFailed means the values files of the repository could not be mirrored into a ConfigMap
+	EventReasonValuesMirrorFailed = "ValuesMirrorFailed"
+
+	// EventReasonGitPulled means a new revision was pulled from the GitOps repository
+	EventReasonGitPulled = "GitPulled"
+
+	// EventReasonGitSyncFailed means the GitOps repository could not be cloned or pulled
+	EventReasonGitSyncFailed = "GitSyncFailed"
+
+	// EventReasonSignatureRejected means the pulled commit is not signed by a trusted key and is not released
+	EventReasonSignatureRejected = "SignatureRejected"
+
+	// EventReasonClusterUnavailable means the target cluster of the deployment cannot be used with its kubeconfig
+	EventReasonClusterUnavailable = "ClusterUnavailable"
+
+	// EventReasonRegistryAuthFailed means the credentials for the chart registries could not be resolved or were rejected
+	EventReasonRegistryAuthFailed = "RegistryAuthFailed"
+
+	// EventReasonChartRejected means the chart is not signed by a trusted key and is not released
+	EventReasonChartRejected = "ChartRejected"
+
+	// EventReasonVersionResolved means a version range of the deployment resolved to another exact version
+	EventReasonVersionResolved = "VersionResolved"
+
+	// EventReasonVersionResolutionFailed means the versions matching a version range could not be listed or none matched
+	EventReasonVersionResolutionFailed = "VersionResolutionFailed"
+
+	// EventReasonVersionAvailable means a newer version matches a range held back by the Notify update policy
+	EventReasonVersionAvailable = "VersionAvailable"
+
+	// EventReasonSecretsMissing means the secrets file of the environment is missing from the repository
+	EventReasonSecretsMissing = "SecretsMissing"
+
+	// EventReasonDependencyCheckFailed means an external service the deployment depends on is unreachable
+	EventReasonDependencyCheckFailed = "DependencyCheckFailed"
+
+	// EventReasonReleaseDeferred means a version change waits for a maintenance window or the end of a change freeze
+	EventReasonReleaseDeferred = "ReleaseDeferred"
+
+	// EventReasonVersionChanging means a sync is about to move the deployment to another version
+	EventReasonVersionChanging = "VersionChanging"
+
+	// EventReasonRollingBack means a sync is about to move the deployment back to its previous version
+	EventReasonRollingBack = "RollingBack"
+
+	// EventReasonReleaseSucceeded means helmfile synced the release
+	EventReasonReleaseSucceeded = "ReleaseSucceeded"
+
+	// EventReasonReleaseFailed means helmfile failed to sync the release
+	EventReasonReleaseFailed = "ReleaseFailed"
+
+	// EventReasonDriftDetected means live objects differ from the desired state
+	EventReasonDriftDetected = "DriftDetected"
+
+	// EventReasonDriftCorrected means drift was undone by re-syncing the release
+	EventReasonDriftCorrected = "DriftCorrected"
+
+	// EventReasonPlanCreated means a PulseProPlan was computed and waits for approval
+	EventReasonPlanCreated = "PlanCreated"
+
+	// EventReasonPlanApplied means an approved PulseProPlan was synced
+	EventReasonPlanApplied = "PlanApplied"
+
+	// EventReasonTimedOut means an external step was killed after exceeding its timeout
+	EventReasonTimedOut = "TimedOut"
+)
+
+// Reasons of the events recorded on PulseProRollouts, besides "Rollout<Phase>" for each phase change
+const (
+	// EventReasonApproved means an approval gate was signed off
+	EventReasonApproved = "Approved"
+
+	// EventReasonApprovalIgnored means an approval was not honoured because no webhook verified its approver
+	EventReasonApprovalIgnored = "ApprovalIgnored"
+
+	// EventReasonTargetUpdated means a deployment was moved to the rollout's version
+	EventReasonTargetUpdated = "TargetUpdated"
+
+	// EventReasonTargetUpdateFailed means a deployment could not be moved to the rollout's version
+	EventReasonTargetUpdateFailed = "TargetUpdateFailed"
+
+	// EventReasonTargetReverted means a deployment was moved back to the version it had before the rollout
+	EventReasonTargetReverted = "TargetReverted"
+
+	// EventReasonAnalysisPassed means the analysis of a deployment passed and the rollout moves on
+	EventReasonAnalysisPassed = "AnalysisPassed"
+
+	// EventReasonAnalysisRunFailed means a single analysis run of a deployment failed
+	EventReasonAnalysisRunFailed = "AnalysisRunFailed"
+)
+
+// Reasons of the events recorded on PulseProImageWatches
+const (
+	// EventReasonRolloutCreated means a PulseProRollout was created for a new tag
+	EventReasonRolloutCreated = "RolloutCreated"
+
+	// EventReasonPollFailed means the tags of the repository could not be listed
+	EventReasonPollFailed = "PollFailed"
+)
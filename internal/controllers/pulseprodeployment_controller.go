@@ -7,20 +7,42 @@ import (
 	"net/url"
 	"os"
 	"os/exec"
+	"slices"
 	"strings"
 	"time"
 
 	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
 	"github.com/go-logr/logr"
 	"gopkg.in/yaml.v2"
 	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
 
 	"github.com/smarter-contracts/pulsepro-operator/api/v1alpha1"
 	pulseprov1alpha1 "github.com/smarter-contracts/pulsepro-operator/api/v1alpha1"
+	"github.com/smarter-contracts/pulsepro-operator/internal/command"
+	"github.com/smarter-contracts/pulsepro-operator/internal/drift"
+	"github.com/smarter-contracts/pulsepro-operator/internal/gitcache"
+	"github.com/smarter-contracts/pulsepro-operator/internal/locks"
+	"github.com/smarter-contracts/pulsepro-operator/internal/metrics"
+	"github.com/smarter-contracts/pulsepro-operator/internal/plan"
+	"github.com/smarter-contracts/pulsepro-operator/internal/receiver"
+	"github.com/smarter-contracts/pulsepro-operator/internal/redact"
+	"github.com/smarter-contracts/pulsepro-operator/internal/registry"
+	"github.com/smarter-contracts/pulsepro-operator/internal/retry"
+	"github.com/smarter-contracts/pulsepro-operator/internal/schedule"
+	"github.com/smarter-contracts/pulsepro-operator/internal/signature"
 	"github.com/smarter-contracts/pulsepro-operator/internal/utils"
 	corev1 "k8s.io/api/core/v1"
 	ctrl "sigs.k8s.io/controller-runtime"
@@ -31,7 +53,87 @@ type PulseProDeploymentReconciler struct {
 	client.Client
 	Log         logr.Logger
 	Scheme      *runtime.Scheme
+	Recorder    record.EventRecorder
 	KubeContext string
+
+	// Timeouts bounds the external steps of a reconcile
+	Timeouts StepTimeouts
+
+	// MaxConcurrentReconciles is the number of deployments reconciled in parallel; defaults to 1
+	MaxConcurrentReconciles int
+
+	// Repositories shares the GitOps checkouts between deployments and fetches each one at most once per sync interval
+	Repositories *gitcache.Cache
+
+	// pushes carries the deployments to reconcile right away after a push to their repository
+	pushes chan event.TypedGenericEvent[*v1alpha1.PulseProDeployment]
+
+	// RegistryProviders provide registry credentials for the hosts the deployments have no credentials for
+	RegistryProviders registry.Provider
+
+	// VerifiedCommitCategories are the deployment categories that may only release signed commits
+	VerifiedCommitCategories []string
+
+	// HelmSlots limits the helmfile operations running at the same time across all deployments
+	HelmSlots *locks.Semaphore
+}
+
+// defaultWorkspaceRoot holds the repository checkouts when Repositories is not set
+const defaultWorkspaceRoot = "/tmp/repos"
+
+// StepTimeouts bounds each external step of a reconcile; zero values use the defaults
+type StepTimeouts struct {
+	// GitSync bounds cloning or pulling the GitOps repository
+	GitSync time.Duration
+
+	// DependencyCheck bounds the connectivity check of each external service
+	DependencyCheck time.Duration
+
+	// Helmfile bounds each helmfile sync or diff
+	Helmfile time.Duration
+
+	// ChartVerification bounds pulling and verifying the signature of the chart
+	ChartVerification time.Duration
+}
+
+const (
+	defaultGitSyncTimeout           = 2 * time.Minute
+	defaultDependencyCheckTimeout   = 30 * time.Second
+	defaultHelmfileTimeout          = 15 * time.Minute
+	defaultChartVerificationTimeout = 2 * time.Minute
+)
+
+// withDefaults fills in the default of every timeout that is not set
+func (t StepTimeouts) withDefaults() StepTimeouts {
+	if t.GitSync <= 0 {
+		t.GitSync = defaultGitSyncTimeout
+	}
+	if t.DependencyCheck <= 0 {
+		t.DependencyCheck = defaultDependencyCheckTimeout
+	}
+	if t.Helmfile <= 0 {
+		t.Helmfile = defaultHelmfileTimeout
+	}
+	if t.ChartVerification <= 0 {
+		t.ChartVerification = defaultChartVerificationTimeout
+	}
+	return t
+}
+
+// eventReason returns EventReasonTimedOut for timeouts and the given reason for other failures
+func eventReason(reason string, err error) string {
+	if command.IsTimeout(err) {
+		return EventReasonTimedOut
+	}
+	return reason
+}
+
+// failureStatus returns the status of a failed step, telling timeouts apart from other failures
+func failureStatus(failed string, timedOut string, err error) string {
+	if command.IsTimeout(err) {
+		return timedOut
+	}
+	return failed
 }
 
 // PulseProValues holds the configuration for external services
@@ -68,59 +170,216 @@ type Rollout struct {
 }
 
 // +kubebuilder:rbac:groups=pulsepro.io,resources=pulseprodeployments,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=pulsepro.pulsepro.io,resources=pulseprofreezecalendars,verbs=get;list;watch
+// +kubebuilder:rbac:groups=pulsepro.pulsepro.io,resources=pulseproplans,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=pulsepro.pulsepro.io,resources=pulseproplans/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=core,resources=events,verbs=create;patch
+// +kubebuilder:rbac:groups=core,resources=secrets,verbs=get
+// +kubebuilder:rbac:groups=core,resources=configmaps,verbs=get;list;watch;create;update;patch
 
 // SetupWithManager sets up the controller with the Manager.
 func (r *PulseProDeploymentReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	if r.Repositories == nil {
+		r.Repositories = gitcache.New(defaultWorkspaceRoot, r.Timeouts.withDefaults().GitSync, r.Log)
+	}
+
+	// Reconcile every deployment of a repository branch as soon as one of them fetched a new commit
+	changes := make(chan event.TypedGenericEvent[gitcache.Change], 100)
+	r.Repositories.Subscribe(func(change gitcache.Change) {
+		select {
+		case changes <- event.TypedGenericEvent[gitcache.Change]{Object: change}:
+		default:
+			// The deployments still pick up the commit on their next sync interval
+			r.Log.Info("Dropped repository change notification", "repoURL", change.URL, "revision", change.Revision)
+		}
+	})
+
+	r.pushes = make(chan event.TypedGenericEvent[*v1alpha1.PulseProDeployment], 100)
+
 	return ctrl.NewControllerManagedBy(mgr).
 		For(&v1alpha1.PulseProDeployment{}).
+		Owns(&v1alpha1.PulseProPlan{}).
+		WatchesRawSource(source.Channel(changes, handler.TypedEnqueueRequestsFromMapFunc(r.deploymentsForRepository))).
+		WatchesRawSource(source.Channel(r.pushes, &handler.TypedEnqueueRequestForObject[*v1alpha1.PulseProDeployment]{})).
+		WithOptions(controller.Options{MaxConcurrentReconciles: max(r.MaxConcurrentReconciles, 1)}).
 		Complete(r)
 }
 
 // Reconcile is the core function that checks the CRD and applies the desired state
 func (r *PulseProDeploymentReconciler) Reconcile(ctx context.Context, req reconcile.Request) (reconcile.Result, error) {
 	log := r.Log.WithValues("pulseprodeployment", req.NamespacedName)
+	if r.Repositories == nil {
+		return reconcile.Result{}, fmt.Errorf("the reconciler has no repository cache; set Repositories or set it up with SetupWithManager")
+	}
 
 	// Fetch the PulseProDeployment instance
 	instance := &pulseprov1alpha1.PulseProDeployment{}
 	if err := r.Get(ctx, req.NamespacedName, instance); err != nil {
 		if errors.IsNotFound(err) {
 			// Object not found, return without requeue
+			metrics.ForgetDeployment(req.Namespace, req.Name)
 			return reconcile.Result{}, nil
 		}
 		// Error reading the object, requeue the request
 		return reconcile.Result{}, err
 	}
+	defer metrics.RecordDeployment(instance)
 
-	// Fetch ConfigMap for Helm values
-	cm := &corev1.ConfigMap{}
-	if err := r.Get(ctx, types.NamespacedName{Name: instance.Spec.HelmValuesConfigMap.Name, Namespace: req.Namespace}, cm); err != nil {
-		log.Error(err, "Unable to fetch ConfigMap")
-		instance.Status.Status = "Failed to fetch ConfigMap"
-		_ = r.Status().Update(ctx, instance)
-		return reconcile.Result{}, err
+	// Requeue the request after the sync interval for periodic reconciliation
+	syncInterval, err := time.ParseDuration(instance.Spec.SyncInterval)
+	if err != nil {
+		// Default requeue time if parsing fails
+		syncInterval = 10 * time.Minute
+	}
+
+	// After a failure, wait for its retry time or, when it is not retried, for a spec change.
+	// A push or another deployment fetching a new revision, or changed values, retry it right away.
+	if failure := instance.Status.LastFailure; failure != nil && failure.Generation == instance.Generation && !r.inputsChanged(ctx, instance, failure, syncInterval) {
+		if failure.NextRetryTime == nil {
+			log.V(1).Info("Waiting for a spec change", "reason", failure.Reason)
+			return reconcile.Result{}, nil
+		}
+		if wait := time.Until(failure.NextRetryTime.Time); wait > 0 {
+			return reconcile.Result{RequeueAfter: wait}, nil
+		}
+	}
+
+	timeouts := r.Timeouts.withDefaults()
+	var current attempt
+
+	// Mask the values of the deployment's secrets in everything that is logged or written to status and events
+	redactor := r.redactorFor(ctx, instance)
+
+	// Resolve version ranges to the exact versions that are released
+	if err := r.resolveVersions(ctx, instance, timeouts.DependencyCheck); err != nil {
+		err = redactor.Error(err)
+		log.Error(err, "Version resolution failed")
+		r.Recorder.Eventf(instance, corev1.EventTypeWarning, EventReasonVersionResolutionFailed, "Version resolution failed: %v", err)
+		return r.fail(ctx, instance, current, "Version resolution failed", err)
+	}
+
+	// Hold back version changes outside the maintenance windows or during a change freeze.
+	// Resyncs of the version that is already running are always allowed.
+	if desiredVersion(instance) != instance.Status.CurrentVersion {
+		decision, err := r.releaseDecision(ctx, instance, time.Now())
+		if err != nil {
+			log.Error(err, "Failed to evaluate maintenance windows")
+			if retry.Classify(err).Class == retry.ClassPermanent {
+				return r.fail(ctx, instance, current, "Invalid maintenance window", err)
+			}
+			return r.fail(ctx, instance, current, "Failed to evaluate maintenance windows", err)
+		}
+
+		condition := metav1.Condition{
+			Type:               pulseprov1alpha1.ConditionReleaseDeferred,
+			Status:             metav1.ConditionFalse,
+			Reason:             decision.Reason,
+			Message:            decision.Message,
+			ObservedGeneration: instance.Generation,
+		}
+		if !decision.Allowed {
+			log.Info("Deferring release", "version", desiredVersion(instance), "reason", decision.Reason, "message", decision.Message)
+			condition.Status = metav1.ConditionTrue
+			meta.SetStatusCondition(&instance.Status.Conditions, condition)
+			r.Recorder.Eventf(instance, corev1.EventTypeNormal, EventReasonReleaseDeferred, "Version %s deferred: %s", desiredVersion(instance), decision.Message)
+			instance.Status.Status = "Deferred"
+			instance.Status.LastFailure = nil
+			if err := r.Status().Update(ctx, instance); err != nil {
+				return reconcile.Result{}, err
+			}
+			return reconcile.Result{RequeueAfter: requeueUntil(decision.NextAttempt, syncInterval)}, nil
+		}
+		meta.SetStatusCondition(&instance.Status.Conditions, condition)
 	}
 
-	helmValues := cm.Data[instance.Spec.HelmValuesConfigMap.Key]
+	// Resolve the cluster the release is synced to, reporting credential problems on the deployment
+	target, cleanupTarget, err := r.clusterFor(ctx, instance, redactor, timeouts.DependencyCheck)
+	if err != nil {
+		err = redactor.Error(err)
+		log.Error(err, "Target cluster unavailable")
+		r.Recorder.Eventf(instance, corev1.EventTypeWarning, EventReasonClusterUnavailable, "Target cluster unavailable: %v", err)
+		return r.fail(ctx, instance, current, "Target cluster unavailable", err)
+	}
+	defer cleanupTarget()
 
-	// Load PulseProValues from ConfigMap data
-	values, err := loadConfig(helmValues)
+	// Resolve the credentials for the registries the charts are pulled from
+	registryConfig, cleanupRegistryConfig, err := r.registryConfigFor(ctx, instance, redactor, timeouts.DependencyCheck)
 	if err != nil {
-		log.Error(err, "Failed to load PulsePro values from ConfigMap")
-		return reconcile.Result{}, err
+		err = redactor.Error(err)
+		log.Error(err, "Registry authentication failed")
+		r.Recorder.Eventf(instance, corev1.EventTypeWarning, EventReasonRegistryAuthFailed, "Registry authentication failed: %v", err)
+		return r.fail(ctx, instance, current, "Registry authentication failed", err)
 	}
+	defer cleanupRegistryConfig()
+	target.registryConfig = registryConfig
 
 	// GitOps Sync: pull latest changes from GitHub repository
-	if err := r.syncFromGitRepo(instance.Spec.GitRepoURL, "/tmp/repo"); err != nil {
+	gitSyncStart := time.Now()
+	workspace, err := r.Repositories.Checkout(ctx, instance.Spec.GitRepoURL, instance.Spec.GitBranch, syncInterval)
+	metrics.ObserveGitSync(instance.Spec.GitRepoURL, gitSyncStart, err)
+	if err != nil {
+		err = redactor.Error(err)
 		log.Error(err, "GitOps sync failed")
-		return reconcile.Result{}, err
+		r.Recorder.Eventf(instance, corev1.EventTypeWarning, eventReason(EventReasonGitSyncFailed, err), "GitOps sync failed: %v", err)
+		return r.fail(ctx, instance, current, failureStatus("Git sync failed", "Git sync timed out", err), retry.Transient("GitSyncFailed", err))
+	}
+	defer workspace.Release()
+	repoDir, revision := workspace.Dir, workspace.Revision
+	current.revision = revision
+	if revision != instance.Status.LastAppliedRevision {
+		r.Recorder.Eventf(instance, corev1.EventTypeNormal, EventReasonGitPulled, "Pulled revision %s", revision)
 	}
 
+	// Only release commits signed by a trusted key when the deployment asks for it
+	if err := r.verifyCommit(ctx, instance, repoDir, revision); err != nil {
+		log.Error(err, "Commit verification failed", "revision", revision)
+		r.Recorder.Eventf(instance, corev1.EventTypeWarning, EventReasonSignatureRejected, "Revision %s is not released: %v", revision, err)
+		return r.fail(ctx, instance, current, "Commit verification failed", err)
+	}
+
+	// Only release charts signed by a trusted key when the deployment asks for it
+	chartArchive, cleanupChart, err := r.verifyChart(ctx, instance, redactor, target, timeouts.ChartVerification)
+	if err != nil {
+		log.Error(err, "Chart verification failed", "chart", instance.Spec.HelmChart, "version", desiredChartVersion(instance))
+		r.Recorder.Eventf(instance, corev1.EventTypeWarning, eventReason(EventReasonChartRejected, err), "Chart %s %s is not released: %v",
+			instance.Spec.HelmChart, desiredChartVersion(instance), err)
+		return r.fail(ctx, instance, current, failureStatus("Chart verification failed", "Chart verification timed out", err), err)
+	}
+	defer cleanupChart()
+	target.chart = chartArchive
+
+	// Merge the Helm values of the deployment's values sources in order
+	merged, err := r.mergeValues(ctx, instance, repoDir, redactor)
+	if err != nil {
+		err = redactor.Error(err)
+		log.Error(err, "Failed to resolve Helm values")
+		r.Recorder.Eventf(instance, corev1.EventTypeWarning, EventReasonValuesMissing, "Failed to resolve Helm values: %v", err)
+		return r.fail(ctx, instance, current, "Failed to resolve Helm values", err)
+	}
+	instance.Status.ValuesHash = merged.hash
+	current.valuesHash = merged.hash
+
+	// Load PulseProValues from the merged values
+	values, err := loadConfig(string(merged.document))
+	if err != nil {
+		err = redactor.Error(err)
+		log.Error(err, "Failed to load PulsePro values")
+		r.Recorder.Eventf(instance, corev1.EventTypeWarning, EventReasonValuesMissing, "Invalid Helm values: %v", err)
+		return r.fail(ctx, instance, current, "Invalid Helm values", retry.Configuration("InvalidValues", err))
+	}
+
+	valuesFile, cleanupValuesFile, err := writeValuesFile(merged)
+	if err != nil {
+		return r.fail(ctx, instance, current, "Failed to write Helm values", err)
+	}
+	defer cleanupValuesFile()
+
 	// Define paths based on project and environment
 	projectName := instance.Spec.ProjectName
 	environmentName := instance.Spec.EnvironmentName
 
 	// Paths to the secrets and values files
-	secretsDir := fmt.Sprintf("/tmp/repo/environments/%s-%s/secrets/pulse-pro", projectName, environmentName)
+	secretsDir := fmt.Sprintf("%s/environments/%s-%s/secrets/pulse-pro", repoDir, projectName, environmentName)
 	secretsFile := fmt.Sprintf("%s/secrets.yaml", secretsDir)
 	secretsEncFile := secretsFile + ".dec"
 
@@ -130,17 +389,45 @@ func (r *PulseProDeploymentReconciler) Reconcile(ctx context.Context, req reconc
 		helmfileType = "gke"
 	}
 
-	helmfilePath := fmt.Sprintf("/tmp/repo/helmfiles/pulse-pro/%s/helmfile.yaml", helmfileType)
-
-	// Define the core values file path
-	// coreValuesFilePath := fmt.Sprintf("/tmp/repo/environments/%s-%s/values/pulse-pro/values.yaml.gotmpl", projectName, environmentName)
+	helmfilePath := fmt.Sprintf("%s/helmfiles/pulse-pro/%s/helmfile.yaml", repoDir, helmfileType)
 
 	// Check if the encrypted secrets file (.yaml.dec) exists
 	if _, err := os.Stat(secretsEncFile); os.IsNotExist(err) {
 		log.Error(err, "Encrypted secrets file does not exist", "file", secretsEncFile)
-		instance.Status.Status = "Encrypted secrets file missing"
-		_ = r.Status().Update(ctx, instance)
-		return reconcile.Result{}, nil
+		r.Recorder.Eventf(instance, corev1.EventTypeWarning, EventReasonSecretsMissing, "Secrets file %s does not exist", secretsEncFile)
+		return r.fail(ctx, instance, current, "Encrypted secrets file missing",
+			retry.Configuration("SecretsMissing", fmt.Errorf("secrets file %s does not exist", secretsEncFile)))
+	}
+
+	// Register the secrets before anything derived from them is rendered or published
+	if data, err := os.ReadFile(secretsEncFile); err == nil {
+		if err := redactor.AddYAML(data); err != nil {
+			log.Error(err, "Failed to parse secrets file for redaction", "file", secretsEncFile)
+		}
+	}
+
+	// Render the core values template like helmfile does, so that template errors are reported before any release
+	coreValuesFilePath := fmt.Sprintf("%s/environments/%s-%s/values/pulse-pro/values.yaml.gotmpl", repoDir, projectName, environmentName)
+	rendered, renderErr := renderValuesTemplate(instance, repoDir, helmfilePath, coreValuesFilePath, merged, redactor)
+	renderErr = redactor.Error(renderErr)
+	merged.redacted = redactor.String(string(merged.document))
+	helmValues := merged.redacted
+	if err := r.publishEffectiveValues(ctx, instance, merged, redactor.String(string(rendered))); err != nil {
+		// The effective values are only published for inspection and do not hold back the release
+		log.Error(err, "Failed to publish effective values")
+		r.Recorder.Eventf(instance, corev1.EventTypeWarning, EventReasonEffectiveValuesFailed, "Failed to publish effective values: %v", err)
+	}
+
+	// Mirror the repository's values files for in-cluster tools once every secret is registered with the
+	// redactor; a failed mirror does not hold back the release
+	if err := r.mirrorRepoValues(ctx, instance, repoDir, revision, redactor); err != nil {
+		log.Error(err, "Failed to mirror values files", "revision", revision)
+		r.Recorder.Eventf(instance, corev1.EventTypeWarning, EventReasonValuesMirrorFailed, "Failed to mirror values files of revision %s: %v", revision, err)
+	}
+	if renderErr != nil {
+		log.Error(renderErr, "Failed to render values template", "file", coreValuesFilePath)
+		r.Recorder.Eventf(instance, corev1.EventTypeWarning, EventReasonValuesTemplateFailed, "Failed to render values template: %v", renderErr)
+		return r.fail(ctx, instance, current, "Failed to render values template", renderErr)
 	}
 
 	// Decrypt the secrets using helmfile's `secrets` integration
@@ -152,39 +439,427 @@ func (r *PulseProDeploymentReconciler) Reconcile(ctx context.Context, req reconc
 	// }
 
 	// Check connectivity to external services (Vault, MidTier, RabbitMQ, Postgres, TimescaleDB)
-	if err := checkConnectivity(values); err != nil {
+	if err := checkConnectivity(ctx, log, timeouts.DependencyCheck, values); err != nil {
+		err = redactor.Error(err)
 		log.Error(err, "Failed to connect to external services")
-		instance.Status.Status = "Failed"
-		_ = r.Status().Update(ctx, instance)
-		return reconcile.Result{}, nil
+		r.Recorder.Event(instance, corev1.EventTypeWarning, eventReason(EventReasonDependencyCheckFailed, err), err.Error())
+		return r.fail(ctx, instance, current, failureStatus("Failed", "Dependency check timed out", err), retry.Transient("DependencyCheckFailed", err))
+	}
+
+	// In Plan mode the changes are recorded in a PulseProPlan and only synced once the plan is approved.
+	// With drift detection enabled, a desired state that was already applied is only compared with the
+	// live objects, and re-synced when drift is found and the policy asks for it to be corrected
+	var approvedPlan *pulseprov1alpha1.PulseProPlan
+	correctingDrift := false
+	if instance.Spec.Mode == pulseprov1alpha1.ModePlan {
+		approvedPlan, err = r.reconcilePlan(ctx, instance, redactor, target, revision, helmValues, helmfilePath, valuesFile)
+		if err != nil {
+			log.Error(err, "Planning failed")
+			return r.fail(ctx, instance, current, failureStatus("Planning failed", "Planning timed out", err), retry.Transient("PlanningFailed", err))
+		}
+		if approvedPlan == nil {
+			instance.Status.LastFailure = nil
+			if err := r.Status().Update(ctx, instance); err != nil {
+				return reconcile.Result{}, err
+			}
+			return reconcile.Result{RequeueAfter: syncInterval}, nil
+		}
+		log.Info("Applying approved plan", "plan", approvedPlan.Name)
+	} else if instance.Spec.DriftPolicy != "" && desiredStateApplied(instance, revision) {
+		drifted, err := r.detectDrift(ctx, instance, redactor, target, helmfilePath, valuesFile)
+		if err != nil {
+			log.Error(err, "Drift detection failed")
+			return r.fail(ctx, instance, current, failureStatus("Drift detection failed", "Drift detection timed out", err), retry.Transient("DriftDetectionFailed", err))
+		}
+
+		if !drifted || instance.Spec.DriftPolicy == pulseprov1alpha1.DriftPolicyReport {
+			instance.Status.Status = "Synced"
+			if drifted {
+				instance.Status.Status = "Drifted"
+			}
+			instance.Status.LastFailure = nil
+			if err := r.Status().Update(ctx, instance); err != nil {
+				return reconcile.Result{}, err
+			}
+			return reconcile.Result{RequeueAfter: syncInterval}, nil
+		}
+
+		log.Info("Correcting drift", "objects", len(instance.Status.DriftedObjects))
+		correctingDrift = true
+	}
+
+	// Announce version changes before syncing them
+	version := desiredVersion(instance)
+	if instance.Status.CurrentVersion != version {
+		switch {
+		case instance.Status.CurrentVersion == "":
+			r.Recorder.Eventf(instance, corev1.EventTypeNormal, EventReasonVersionChanging, "Releasing version %s", version)
+		case version == instance.Status.PreviousVersion:
+			r.Recorder.Eventf(instance, corev1.EventTypeNormal, EventReasonRollingBack, "Rolling back from %s to %s", instance.Status.CurrentVersion, version)
+		default:
+			r.Recorder.Eventf(instance, corev1.EventTypeNormal, EventReasonVersionChanging, "Changing version from %s to %s", instance.Status.CurrentVersion, version)
+		}
 	}
 
 	// Use helmfile to apply Helm changes
-	if err := runHelmfileSync(helmfilePath, projectName, environmentName, r.KubeContext); err != nil {
+	helmfileSyncStart := time.Now()
+	err = r.helmfileSync(ctx, log, redactor, target, repoDir, helmfilePath, valuesFile, instance)
+	metrics.ObserveHelmfileSync(instance.Namespace, instance.Name, helmfileSyncStart, err)
+	if err != nil {
 		log.Error(err, "Helmfile sync failed")
-		instance.Status.Status = "Helmfile sync failed"
-		_ = r.Status().Update(ctx, instance)
-		return reconcile.Result{}, err
+		r.Recorder.Eventf(instance, corev1.EventTypeWarning, eventReason(EventReasonReleaseFailed, err), "Helmfile sync of version %s failed: %s", version, truncate(err.Error(), 1024))
+		return r.fail(ctx, instance, current, failureStatus("Helmfile sync failed", "Helmfile sync timed out", err), retry.Transient("HelmfileSyncFailed", err))
 	}
 
-	// Update the status of the PulseProDeployment to "Synced"
+	r.Recorder.Eventf(instance, corev1.EventTypeNormal, EventReasonReleaseSucceeded, "Synced version %s at revision %s", version, revision)
+
+	// Update the status of the PulseProDeployment to "Synced" and record the version now running
 	instance.Status.Status = "Synced"
+	instance.Status.LastFailure = nil
+	instance.Status.LastAppliedRevision = revision
+	instance.Status.LastAppliedValuesHash = merged.hash
+	instance.Status.LastAppliedChartVersion = desiredChartVersion(instance)
+	instance.Status.ObservedGeneration = instance.Generation
+	if instance.Spec.DriftPolicy != "" {
+		condition := metav1.Condition{
+			Type:               pulseprov1alpha1.ConditionDrifted,
+			Status:             metav1.ConditionFalse,
+			Reason:             pulseprov1alpha1.ReasonInSync,
+			Message:            fmt.Sprintf("Synced revision %s", revision),
+			ObservedGeneration: instance.Generation,
+		}
+		if correctingDrift {
+			condition.Reason = pulseprov1alpha1.ReasonDriftCorrected
+			condition.Message = fmt.Sprintf("Re-synced revision %s to undo drift", revision)
+			r.Recorder.Event(instance, corev1.EventTypeNormal, EventReasonDriftCorrected, condition.Message)
+		}
+		meta.SetStatusCondition(&instance.Status.Conditions, condition)
+		instance.Status.DriftedObjects = nil
+	}
+	if instance.Status.CurrentVersion != version {
+		instance.Status.PreviousVersion = instance.Status.CurrentVersion
+		instance.Status.CurrentVersion = version
+	}
 	if err := r.Status().Update(ctx, instance); err != nil {
 		return reconcile.Result{}, err
 	}
 
-	// Requeue the request after the sync interval for periodic reconciliation
-	syncInterval, err := time.ParseDuration(instance.Spec.SyncInterval)
-	if err != nil {
-		// Default requeue time if parsing fails
-		syncInterval = 10 * time.Minute
+	if approvedPlan != nil {
+		now := metav1.Now()
+		approvedPlan.Status.Phase = pulseprov1alpha1.PlanPhaseApplied
+		approvedPlan.Status.AppliedAt = &now
+		if err := r.Status().Update(ctx, approvedPlan); err != nil {
+			return reconcile.Result{}, err
+		}
+		r.Recorder.Eventf(instance, corev1.EventTypeNormal, EventReasonPlanApplied, "Applied plan %s", approvedPlan.Name)
 	}
+
 	return reconcile.Result{RequeueAfter: syncInterval}, nil
 }
 
-func encryptSecrets(plainFile, encFile string) error {
+// reconcilePlan computes the PulseProPlan for the current desired state if it does not exist yet.
+// It returns the plan once it is approved and still pending, and nil while there is nothing to apply.
+func (r *PulseProDeploymentReconciler) reconcilePlan(ctx context.Context, instance *pulseprov1alpha1.PulseProDeployment, redactor *redact.Redactor, target helmTarget, revision, helmValues, helmfilePath, valuesFile string) (*pulseprov1alpha1.PulseProPlan, error) {
+	// A change of the values or of the resolved versions is a new desired state that needs its own plan
+	name := plan.Name(instance.Name, instance.Generation, revision, instance.Status.ValuesHash, desiredVersion(instance), desiredChartVersion(instance))
+	instance.Status.LatestPlan = name
+
+	existing := &pulseprov1alpha1.PulseProPlan{}
+	err := r.Get(ctx, types.NamespacedName{Name: name, Namespace: instance.Namespace}, existing)
+	if err == nil && existing.Status.Phase == pulseprov1alpha1.PlanPhaseSuperseded {
+		// The desired state went back to a superseded plan, which has to be computed again
+		if err := r.Delete(ctx, existing); err != nil && !errors.IsNotFound(err) {
+			return nil, fmt.Errorf("failed to delete superseded plan %s: %v", name, err)
+		}
+		err = errors.NewNotFound(pulseprov1alpha1.GroupVersion.WithResource("pulseproplans").GroupResource(), name)
+	}
+	if err == nil {
+		switch {
+		case existing.Status.Phase == pulseprov1alpha1.PlanPhasePending && existing.Spec.Approved:
+			return existing, nil
+		case existing.Status.Phase == pulseprov1alpha1.PlanPhasePending:
+			instance.Status.Status = "Planned"
+		case existing.Status.Phase == pulseprov1alpha1.PlanPhaseApplied && instance.Spec.DriftPolicy != "":
+			// Drift is only reported in Plan mode; correcting it needs an approved plan
+			drifted, err := r.detectDrift(ctx, instance, redactor, target, helmfilePath, valuesFile)
+			if err != nil {
+				return nil, err
+			}
+			if drifted {
+				instance.Status.Status = "Drifted"
+			}
+		}
+		return nil, nil
+	}
+	if !errors.IsNotFound(err) {
+		return nil, fmt.Errorf("failed to get plan %s: %v", name, err)
+	}
+
+	output, _, err := r.helmfileDiff(ctx, redactor, target, helmfilePath, valuesFile, instance)
+	if err != nil {
+		return nil, err
+	}
+
+	var plans pulseprov1alpha1.PulseProPlanList
+	if err := r.List(ctx, &plans, client.InNamespace(instance.Namespace), client.MatchingLabels{pulseprov1alpha1.PlanDeploymentLabel: instance.Name}); err != nil {
+		return nil, fmt.Errorf("failed to list plans: %v", err)
+	}
+	previousValues := ""
+	var lastApplied *metav1.Time
+	for _, p := range plans.Items {
+		if p.Status.Phase == pulseprov1alpha1.PlanPhaseApplied && p.Status.AppliedAt != nil &&
+			(lastApplied == nil || p.Status.AppliedAt.After(lastApplied.Time)) {
+			previousValues = p.Status.Values
+			lastApplied = p.Status.AppliedAt
+		}
+	}
+	valuesDelta, err := plan.ValuesDelta(previousValues, helmValues)
+	if err != nil {
+		return nil, err
+	}
+
+	newPlan := &pulseprov1alpha1.PulseProPlan{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: instance.Namespace,
+			Labels:    map[string]string{pulseprov1alpha1.PlanDeploymentLabel: instance.Name},
+		},
+		Spec: pulseprov1alpha1.PulseProPlanSpec{
+			DeploymentName:       instance.Name,
+			Revision:             revision,
+			DeploymentGeneration: instance.Generation,
+			PulseProVersion:      desiredVersion(instance),
+			HelmChartVersion:     desiredChartVersion(instance),
+			ValuesHash:           instance.Status.ValuesHash,
+		},
+	}
+	if err := controllerutil.SetControllerReference(instance, newPlan, r.Scheme); err != nil {
+		return nil, fmt.Errorf("failed to set owner of plan %s: %v", name, err)
+	}
+	if err := r.Create(ctx, newPlan); err != nil {
+		return nil, fmt.Errorf("failed to create plan %s: %v", name, err)
+	}
+
+	resources := drift.Parse(output)
+	newPlan.Status = pulseprov1alpha1.PulseProPlanStatus{
+		Phase:       pulseprov1alpha1.PlanPhasePending,
+		Message:     fmt.Sprintf("%d resource and %d value changes", len(resources), len(valuesDelta)),
+		Resources:   resources,
+		ValuesDelta: valuesDelta,
+		Values:      helmValues,
+	}
+	if summary := drift.Summary(resources); summary != "" {
+		newPlan.Status.Message = truncate(summary, 1024)
+	}
+	if err := r.Status().Update(ctx, newPlan); err != nil {
+		return nil, fmt.Errorf("failed to update status of plan %s: %v", name, err)
+	}
+
+	// Older plans that were never applied no longer describe what a sync would do
+	for i := range plans.Items {
+		p := &plans.Items[i]
+		if p.Name == name || p.Status.Phase != pulseprov1alpha1.PlanPhasePending {
+			continue
+		}
+		p.Status.Phase = pulseprov1alpha1.PlanPhaseSuperseded
+		p.Status.Message = fmt.Sprintf("Superseded by %s", name)
+		if err := r.Status().Update(ctx, p); err != nil {
+			return nil, fmt.Errorf("failed to supersede plan %s: %v", p.Name, err)
+		}
+	}
+
+	r.Log.Info("Created plan", "pulseprodeployment", instance.Name, "plan", name, "resources", len(resources), "values", len(valuesDelta))
+	r.Recorder.Eventf(instance, corev1.EventTypeNormal, EventReasonPlanCreated, "Created plan %s: %s", name, newPlan.Status.Message)
+	instance.Status.Status = "Planned"
+	return nil, nil
+}
+
+// attempt is the Git revision and values hash a reconcile got to, recorded with its failure
+type attempt struct {
+	revision   string
+	valuesHash string
+}
+
+// fail records a failed reconcile step in status and requeues it according to the retry policy of its class
+func (r *PulseProDeploymentReconciler) fail(ctx context.Context, instance *pulseprov1alpha1.PulseProDeployment, current attempt, status string, err error) (reconcile.Result, error) {
+	failure := retry.Classify(err)
+	retryCount := int32(1)
+	if last := instance.Status.LastFailure; last != nil && last.Generation == instance.Generation &&
+		last.Revision == current.revision && last.ValuesHash == current.valuesHash {
+		retryCount = last.RetryCount + 1
+	}
+
+	instance.Status.Status = status
+	instance.Status.LastFailure = &pulseprov1alpha1.ReconcileFailure{
+		Class:      string(failure.Class),
+		Reason:     failure.Reason,
+		Message:    truncate(err.Error(), 1024),
+		RetryCount: retryCount,
+		Generation: instance.Generation,
+		Revision:   current.revision,
+		ValuesHash: current.valuesHash,
+	}
+
+	result := reconcile.Result{}
+	if delay, ok := retry.Delay(failure.Class, retryCount); ok {
+		next := metav1.NewTime(time.Now().Add(delay))
+		instance.Status.LastFailure.NextRetryTime = &next
+		result.RequeueAfter = delay
+	}
+	r.Log.Info("Reconcile failed", "pulseprodeployment", instance.Name, "class", failure.Class, "reason", failure.Reason,
+		"retryCount", retryCount, "requeueAfter", result.RequeueAfter)
+
+	if err := r.Status().Update(ctx, instance); err != nil {
+		return reconcile.Result{}, err
+	}
+	return result, nil
+}
+
+// inputsChanged reports whether the Git revision or the merged values differ from those of the failure.
+// The checkout is only fetched when it is older than maxAge or expired by a push.
+func (r *PulseProDeploymentReconciler) inputsChanged(ctx context.Context, instance *pulseprov1alpha1.PulseProDeployment, failure *pulseprov1alpha1.ReconcileFailure, maxAge time.Duration) bool {
+	if failure.Revision == "" {
+		return false
+	}
+	workspace, err := r.Repositories.Checkout(ctx, instance.Spec.GitRepoURL, instance.Spec.GitBranch, maxAge)
+	if err != nil {
+		return false
+	}
+	defer workspace.Release()
+	if workspace.Revision != failure.Revision {
+		return true
+	}
+	if failure.ValuesHash == "" {
+		return false
+	}
+	merged, err := r.mergeValues(ctx, instance, workspace.Dir, redact.New())
+	return err == nil && merged.hash != failure.ValuesHash
+}
+
+// redactorFor returns a Redactor masking the data of the Secrets referenced by the deployment.
+// Secrets that cannot be read are skipped; the sensitive patterns are masked regardless.
+func (r *PulseProDeploymentReconciler) redactorFor(ctx context.Context, instance *pulseprov1alpha1.PulseProDeployment) *redact.Redactor {
+	redactor := redact.New()
+	for _, ref := range instance.Spec.Secrets {
+		secret := &corev1.Secret{}
+		if err := r.Get(ctx, types.NamespacedName{Name: ref.Name, Namespace: instance.Namespace}, secret); err != nil {
+			r.Log.V(1).Info("Unable to read secret for redaction", "secret", ref.Name, "namespace", instance.Namespace, "error", err.Error())
+			continue
+		}
+		for _, value := range secret.Data {
+			redactor.Add(string(value))
+		}
+		for _, value := range secret.StringData {
+			redactor.Add(value)
+		}
+	}
+	return redactor
+}
+
+// desiredStateApplied reports whether the last successful sync already applied this spec, Git revision, merged
+// values and resolved versions. Deployments synced before the values were hashed have no applied values hash,
+// and deployments synced before chart versions were resolved have no applied chart version.
+func desiredStateApplied(instance *pulseprov1alpha1.PulseProDeployment, revision string) bool {
+	return instance.Status.LastAppliedRevision == revision &&
+		instance.Status.ObservedGeneration == instance.Generation &&
+		instance.Status.CurrentVersion == desiredVersion(instance) &&
+		(instance.Status.LastAppliedValuesHash == "" || instance.Status.LastAppliedValuesHash == instance.Status.ValuesHash) &&
+		(instance.Status.LastAppliedChartVersion == "" || instance.Status.LastAppliedChartVersion == desiredChartVersion(instance))
+}
+
+// detectDrift compares the live objects with the desired state and records the result in status
+func (r *PulseProDeploymentReconciler) detectDrift(ctx context.Context, instance *pulseprov1alpha1.PulseProDeployment, redactor *redact.Redactor, target helmTarget, helmfilePath, valuesFile string) (bool, error) {
+	output, changed, err := r.helmfileDiff(ctx, redactor, target, helmfilePath, valuesFile, instance)
+	if err != nil {
+		return false, err
+	}
+
+	objects := drift.Parse(output)
+	drifted := changed || len(objects) > 0
+	instance.Status.DriftedObjects = objects
+
+	condition := metav1.Condition{
+		Type:               pulseprov1alpha1.ConditionDrifted,
+		Status:             metav1.ConditionFalse,
+		Reason:             pulseprov1alpha1.ReasonInSync,
+		Message:            "Live objects match the desired state",
+		ObservedGeneration: instance.Generation,
+	}
+	if drifted {
+		condition.Status = metav1.ConditionTrue
+		condition.Reason = pulseprov1alpha1.ReasonDriftDetected
+		condition.Message = truncate(drift.Summary(objects), 1024)
+		if condition.Message == "" {
+			condition.Message = "helmfile diff reported changes"
+		}
+		r.Log.Info("Drift detected", "pulseprodeployment", instance.Name, "objects", len(objects))
+		r.Recorder.Event(instance, corev1.EventTypeWarning, EventReasonDriftDetected, condition.Message)
+	}
+	meta.SetStatusCondition(&instance.Status.Conditions, condition)
+	return drifted, nil
+}
+
+// truncate shortens s to at most n bytes, marking the cut
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n-3] + "..."
+}
+
+// releaseDecision decides whether a version change of the deployment may be released at the given time
+func (r *PulseProDeploymentReconciler) releaseDecision(ctx context.Context, instance *pulseprov1alpha1.PulseProDeployment, now time.Time) (schedule.Decision, error) {
+	freezes, err := listFreezePeriods(ctx, r.Client)
+	if err != nil {
+		return schedule.Decision{}, retry.Transient("FreezeCalendarsUnavailable", err)
+	}
+	decision, err := schedule.ReleaseDecision(instance.Spec.MaintenanceWindows, freezes, instance.Spec.Category, now)
+	if err != nil {
+		// Maintenance windows only come from the spec, so they stay invalid until it changes
+		return schedule.Decision{}, retry.Permanent(pulseprov1alpha1.ReasonInvalidMaintenanceWindow, err)
+	}
+	return decision, nil
+}
+
+// listFreezePeriods collects the freezes of every PulseProFreezeCalendar in the cluster
+func listFreezePeriods(ctx context.Context, c client.Client) ([]pulseprov1alpha1.FreezePeriod, error) {
+	var calendars pulseprov1alpha1.PulseProFreezeCalendarList
+	if err := c.List(ctx, &calendars); err != nil {
+		return nil, fmt.Errorf("failed to list freeze calendars: %v", err)
+	}
+
+	// Freezes stored before the CRD validated them never apply, which must not go unnoticed
+	var freezes []pulseprov1alpha1.FreezePeriod
+	for _, calendar := range calendars.Items {
+		for _, freeze := range calendar.Spec.Freezes {
+			if err := schedule.ValidateFreeze(freeze); err != nil {
+				logr.FromContextOrDiscard(ctx).Error(err, "Ignoring invalid freeze", "calendar", calendar.Name)
+				continue
+			}
+			freezes = append(freezes, freeze)
+		}
+	}
+	return freezes, nil
+}
+
+// requeueUntil returns the delay until the given time, capped at maxDelay so changes are still picked up periodically
+func requeueUntil(next time.Time, maxDelay time.Duration) time.Duration {
+	if next.IsZero() {
+		return maxDelay
+	}
+	delay := time.Until(next)
+	if delay <= 0 {
+		return time.Second
+	}
+	if delay > maxDelay {
+		return maxDelay
+	}
+	return delay
+}
+
+func encryptSecrets(ctx context.Context, timeout time.Duration, plainFile, encFile string) error {
 	// Prepare the helm secrets encrypt command
-	cmd := exec.Command("helm", "secrets", "encrypt", plainFile)
+	cmd := command.New(ctx, timeout, "helm", "secrets", "encrypt", plainFile)
 
 	// Create buffers to capture stdout and stderr
 	var outBuf, errBuf bytes.Buffer
@@ -207,9 +882,9 @@ func encryptSecrets(plainFile, encFile string) error {
 
 // decryptSecrets decrypts the encrypted secrets file (.yaml.dec) into the target file (.yaml)
 // using the helm secrets plugin which internally uses sops.
-func decryptSecrets(encFile, outputFile string) error {
+func decryptSecrets(ctx context.Context, timeout time.Duration, encFile, outputFile string) error {
 	// Prepare the command to decrypt the secrets using helm secrets
-	cmd := exec.Command("helm", "secrets", "decrypt", encFile)
+	cmd := command.New(ctx, timeout, "helm", "secrets", "decrypt", encFile)
 
 	// Create buffers to capture stdout and stderr
 	var outBuf, errBuf bytes.Buffer
@@ -230,12 +905,12 @@ func decryptSecrets(encFile, outputFile string) error {
 	return nil
 }
 
-// Function to load ConfigMap data into PulseProValues
+// Function to load the merged Helm values into PulseProValues
 func loadConfig(data string) (*PulseProValues, error) {
 	var values PulseProValues
 	err := yaml.Unmarshal([]byte(data), &values)
 	if err != nil {
-		return nil, fmt.Errorf("failed to parse values from ConfigMap: %v", err)
+		return nil, fmt.Errorf("failed to parse values: %v", err)
 	}
 	return &values, nil
 }
@@ -254,7 +929,7 @@ func sanitizeHost(rawHost string) (string, error) {
 }
 
 // checkConnectivity checks the connectivity for the external services
-func checkConnectivity(values *PulseProValues) error {
+func checkConnectivity(ctx context.Context, log logr.Logger, timeout time.Duration, values *PulseProValues) error {
 	// Services to check for connectivity
 	services := map[string]string{
 		"Vault":       values.Vault.Address,
@@ -268,45 +943,56 @@ func checkConnectivity(values *PulseProValues) error {
 	for service, host := range services {
 		if host == "" {
 			// Skip connectivity check if host is empty
-			fmt.Printf("Skipping connectivity check for %s: no hostname provided in the ConfigMap\n", service)
+			log.V(1).Info("Skipping connectivity check, no hostname provided in the ConfigMap", "service", service)
 			continue
 		}
 
 		// Skip internal services check in local environments
 		if service == "RabbitMQ" || service == "TimescaleDB" || service == "Postgres" || service == "MidTier" {
-			fmt.Printf("Skipping connectivity check for %s in local environment\n", service)
+			log.V(1).Info("Skipping connectivity check in local environment", "service", service)
 			continue
 		}
 
-		// Check HTTP(S) services like Vault and MidTier using curl with -L to follow redirects
-		if service == "Vault" || service == "MidTier" {
-			fmt.Printf("Checking HTTP connectivity for %s at %s\n", service, host)
-			cmd := exec.Command("curl", "-L", "-s", "-o", "/dev/null", "-w", "%{http_code}", host)
-			output, err := cmd.Output()
-			if err != nil {
-				return fmt.Errorf("failed to connect to %s (%s): %v", service, host, err)
-			}
+		start := time.Now()
+		err := checkService(ctx, log, timeout, service, host)
+		metrics.ObserveDependencyCheck(service, start, err)
+		if err != nil {
+			return err
+		}
+	}
 
-			// Check if the HTTP status code is 200
-			if string(output) != "200" {
-				return fmt.Errorf("failed to connect to %s (%s): received HTTP status %s", service, host, string(output))
-			}
+	return nil
+}
 
-			fmt.Printf("Successfully connected to %s (%s) with HTTP status 200\n", service, host)
-			continue
+// checkService checks the connectivity of a single external service
+func checkService(ctx context.Context, log logr.Logger, timeout time.Duration, service, host string) error {
+	// Check HTTP(S) services like Vault and MidTier using curl with -L to follow redirects
+	if service == "Vault" || service == "MidTier" {
+		log.V(1).Info("Checking HTTP connectivity", "service", service, "host", host)
+		cmd := command.New(ctx, timeout, "curl", "-L", "-s", "-o", "/dev/null", "-w", "%{http_code}", host)
+		output, err := cmd.Output()
+		if err != nil {
+			return fmt.Errorf("failed to connect to %s (%s): %v", service, host, err)
 		}
 
-		// For other services, use ping to test connectivity
-		fmt.Printf("Checking connectivity for %s at %s\n", service, host)
-		cmd := exec.Command("ping", "-c", "1", host)
-		if err := cmd.Run(); err != nil {
-			return fmt.Errorf("failed to connect to %s (%s): %v", service, host, err)
+		// Check if the HTTP status code is 200
+		if string(output) != "200" {
+			return fmt.Errorf("failed to connect to %s (%s): received HTTP status %s", service, host, string(output))
 		}
 
-		// Log successful connection
-		fmt.Printf("Successfully connected to %s (%s)\n", service, host)
+		log.V(1).Info("Connected", "service", service, "host", host, "status", 200)
+		return nil
+	}
+
+	// For other services, use ping to test connectivity
+	log.V(1).Info("Checking connectivity", "service", service, "host", host)
+	cmd := command.New(ctx, timeout, "ping", "-c", "1", host)
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("failed to connect to %s (%s): %v", service, host, err)
 	}
 
+	// Log successful connection
+	log.V(1).Info("Connected", "service", service, "host", host)
 	return nil
 }
 
@@ -315,43 +1001,97 @@ func isRunningInCluster() bool {
 	return err == nil
 }
 
-// runHelmfileSync runs the helmfile sync command with the specified parameters
-func runHelmfileSync(helmfilePath, projectName, environmentName, kubeContext string) error {
-	// Construct the helmfile sync command
-	cmdArgs := []string{"-f", helmfilePath, "--environment", projectName + "-" + environmentName, "sync"}
-	if kubeContext != "" {
-		cmdArgs = append(cmdArgs, "--kube-context", kubeContext)
+// helmfileSync runs helmfile sync for the deployment once a helm slot is free
+func (r *PulseProDeploymentReconciler) helmfileSync(ctx context.Context, log logr.Logger, redactor *redact.Redactor, target helmTarget, repoDir, helmfilePath, valuesFile string, instance *pulseprov1alpha1.PulseProDeployment) error {
+	release, err := r.HelmSlots.Acquire(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to wait for a helm slot: %v", err)
 	}
+	defer release()
+	return runHelmfileSync(ctx, log, redactor, r.Timeouts.withDefaults().Helmfile, repoDir, helmfilePath, valuesFile, append(versionStateValues(instance), target.chartStateValues(instance)...),
+		instance.Spec.ProjectName, instance.Spec.EnvironmentName, target)
+}
 
-	// Log the Helmfile command being executed
-	fmt.Printf("DEBUG: Executing Helmfile command: helmfile %s\n", strings.Join(cmdArgs, " "))
+// helmfileDiff runs helmfile diff for the deployment once a helm slot is free
+func (r *PulseProDeploymentReconciler) helmfileDiff(ctx context.Context, redactor *redact.Redactor, target helmTarget, helmfilePath, valuesFile string, instance *pulseprov1alpha1.PulseProDeployment) (string, bool, error) {
+	release, err := r.HelmSlots.Acquire(ctx)
+	if err != nil {
+		return "", false, fmt.Errorf("failed to wait for a helm slot: %v", err)
+	}
+	defer release()
+	return runHelmfileDiff(ctx, redactor, r.Timeouts.withDefaults().Helmfile, helmfilePath, valuesFile, append(versionStateValues(instance), target.chartStateValues(instance)...),
+		instance.Spec.ProjectName, instance.Spec.EnvironmentName, target)
+}
+
+// runHelmfileSync runs the helmfile sync command with the specified parameters.
+// Its output is redacted before it is logged or returned in the error.
+func runHelmfileSync(ctx context.Context, log logr.Logger, redactor *redact.Redactor, timeout time.Duration, repoDir, helmfilePath, valuesFile string, stateValues []string, projectName, environmentName string, target helmTarget) error {
+	// Construct the helmfile sync command
+	cmdArgs := append([]string{"-f", helmfilePath, "--environment", projectName + "-" + environmentName}, stateValues...)
+	cmdArgs = append(cmdArgs, "sync")
+	if valuesFile != "" {
+		cmdArgs = append(cmdArgs, "--values", valuesFile)
+	}
 
 	// Path to the secrets file
-	secretsFilePath := fmt.Sprintf("/tmp/repo/environments/%s-%s/secrets/pulse-pro/secrets.yaml", projectName, environmentName)
+	secretsFilePath := fmt.Sprintf("%s/environments/%s-%s/secrets/pulse-pro/secrets.yaml", repoDir, projectName, environmentName)
 
 	// Check if the secrets file is encrypted or not
 	if !isFileEncrypted(secretsFilePath) {
 		// Skip decryption and log the action
-		fmt.Printf("DEBUG: Secrets file %s is not encrypted, treating as a regular values file.\n", secretsFilePath)
+		log.V(1).Info("Secrets file is not encrypted, treating it as a regular values file", "file", secretsFilePath)
 	}
 
 	// Create the helmfile command
-	helmfileCmd := exec.Command("helmfile", cmdArgs...)
+	helmfileCmd := command.New(ctx, timeout, "helmfile", cmdArgs...)
+	target.apply(helmfileCmd)
+
+	// Log the Helmfile command being executed
+	log.V(1).Info("Executing helmfile", "command", strings.Join(helmfileCmd.Args, " "))
 
 	// Capture the combined output (stdout and stderr)
 	output, err := helmfileCmd.CombinedOutput()
 
+	if command.IsTimeout(err) {
+		return fmt.Errorf("helmfile sync failed: %w\nOutput: %s", err, redactor.String(string(output)))
+	}
 	if err != nil {
-		// Log the command output and the error
-		fmt.Printf("Helmfile sync failed: %v\nOutput: %s\n", err, string(output))
-		return fmt.Errorf("helmfile sync failed: %v\nOutput: %s", err, string(output))
+		return fmt.Errorf("helmfile sync failed: %v\nOutput: %s", err, redactor.String(string(output)))
 	}
 
 	// Log successful sync output
-	fmt.Printf("Helmfile sync succeeded. Output: %s\n", string(output))
+	log.V(1).Info("Helmfile sync succeeded", "output", redactor.String(string(output)))
 	return nil
 }
 
+// runHelmfileDiff compares the desired state rendered by helmfile with the live objects.
+// It returns the redacted diff output and whether helmfile reported any difference.
+func runHelmfileDiff(ctx context.Context, redactor *redact.Redactor, timeout time.Duration, helmfilePath, valuesFile string, stateValues []string, projectName, environmentName string, target helmTarget) (string, bool, error) {
+	cmdArgs := append([]string{"-f", helmfilePath, "--environment", projectName + "-" + environmentName}, stateValues...)
+	// Without a three-way merge helm-diff compares with the manifest stored in the release, so edits made to
+	// the live objects by hand would go unnoticed
+	cmdArgs = append(cmdArgs, "diff", "--detailed-exitcode", "--suppress-secrets", "--diff-args=--three-way-merge")
+	if valuesFile != "" {
+		cmdArgs = append(cmdArgs, "--values", valuesFile)
+	}
+
+	diffCmd := command.New(ctx, timeout, "helmfile", cmdArgs...)
+	target.apply(diffCmd)
+	rawOutput, err := diffCmd.CombinedOutput()
+	output := redactor.String(string(rawOutput))
+	if exitErr, ok := err.(*exec.ExitError); ok && exitErr.ExitCode() == 2 {
+		// --detailed-exitcode exits with 2 when there are changes
+		return output, true, nil
+	}
+	if command.IsTimeout(err) {
+		return output, false, fmt.Errorf("helmfile diff failed: %w", err)
+	}
+	if err != nil {
+		return output, false, fmt.Errorf("helmfile diff failed: %v\nOutput: %s", err, output)
+	}
+	return output, false, nil
+}
+
 // isFileEncrypted checks if a file is encrypted by analyzing its contents or file extension.
 func isFileEncrypted(filePath string) bool {
 	// In this case, assume that any file ending with ".yaml.dec" is decrypted and ".yaml" is encrypted
@@ -359,7 +1099,7 @@ func isFileEncrypted(filePath string) bool {
 }
 
 // runHelmRelease executes the Helm upgrade/install command with the provided values file
-func runHelmRelease(spec pulseprov1alpha1.PulseProDeploymentSpec, valuesFilePath string, secretsFilePath string, coreValuesFilePath string, kubeContext string) error {
+func runHelmRelease(ctx context.Context, log logr.Logger, redactor *redact.Redactor, timeout time.Duration, spec pulseprov1alpha1.PulseProDeploymentSpec, valuesFilePath string, secretsFilePath string, coreValuesFilePath string, target helmTarget) error {
 	// Define the release name
 	releaseName := spec.ProjectName + "-" + spec.EnvironmentName
 
@@ -367,173 +1107,173 @@ func runHelmRelease(spec pulseprov1alpha1.PulseProDeploymentSpec, valuesFilePath
 	chartRepo := spec.HelmChart
 	chartVersion := spec.HelmChartVersion
 
-	// Authenticate with GCP and log into the Artifact Registry (GCR)
-	accessTokenCmd := exec.Command("gcloud", "auth", "print-access-token")
-	accessToken, err := accessTokenCmd.Output()
-	if err != nil {
-		fmt.Printf("Failed to get access token from gcloud: %v\n", err)
-		return err
-	}
-
-	// Use the access token to log into the Artifact Registry
-	helmLoginCmd := exec.Command("helm", "registry", "login", "-u", "oauth2accesstoken", "--password-stdin", "europe-docker.pkg.dev")
-	helmLoginCmd.Stdin = strings.NewReader(string(accessToken))
-	helmLoginOutput, err := helmLoginCmd.CombinedOutput()
-	if err != nil {
-		fmt.Printf("Helm registry login failed: %v\nOutput: %s\n", err, string(helmLoginOutput))
-		return err
-	}
-
-	fmt.Println("Successfully logged into Helm registry")
+	// The registry credentials are passed in the target's registry config, see registryConfigFor
 
 	// Ensure the values file exists
 	if _, err := os.Stat(valuesFilePath); os.IsNotExist(err) {
-		fmt.Printf("Values file does not exist: %s\n", valuesFilePath)
-		return err
+		return fmt.Errorf("values file %s does not exist: %v", valuesFilePath, err)
 	}
 
 	// Ensure the secrets file exists
 	if _, err := os.Stat(secretsFilePath); os.IsNotExist(err) {
-		fmt.Printf("Secrets file does not exist: %s\n", secretsFilePath)
-		return err
+		return fmt.Errorf("secrets file %s does not exist: %v", secretsFilePath, err)
 	}
 
 	// Ensure the core values file exists
 	if _, err := os.Stat(coreValuesFilePath); os.IsNotExist(err) {
-		fmt.Printf("Core values file does not exist: %s\n", coreValuesFilePath)
-		return err
-	}
-
-	// Determine if the operator is running inside a Kubernetes cluster
-	var helmCmd *exec.Cmd
-	if isRunningInCluster() || kubeContext == "" {
-		// Use in-cluster configuration, no need for kube-context
-		helmCmd = exec.Command("helm", "upgrade", "--install", releaseName, chartRepo, "--version", chartVersion,
-			"--values", valuesFilePath,
-			"--values", secretsFilePath,
-			"--values", coreValuesFilePath)
-	} else {
-		// Use the provided kube-context for local development
-		helmCmd = exec.Command("helm", "upgrade", "--install", releaseName, chartRepo, "--version", chartVersion,
-			"--values", valuesFilePath,
-			"--values", secretsFilePath,
-			"--values", coreValuesFilePath,
-			"--kube-context", kubeContext)
+		return fmt.Errorf("core values file %s does not exist: %v", coreValuesFilePath, err)
 	}
 
+	// Target the deployment's cluster, or the operator's own cluster when it has no kubeconfig
+	helmCmd := command.New(ctx, timeout, "helm", "upgrade", "--install", releaseName, chartRepo, "--version", chartVersion,
+		"--values", valuesFilePath,
+		"--values", secretsFilePath,
+		"--values", coreValuesFilePath)
+	if isRunningInCluster() && target.kubeconfig == "" {
+		// In-cluster configuration needs no kube-context
+		target.context = ""
+	}
+	target.apply(helmCmd)
+
 	// Log the Helm command before executing
-	fmt.Printf("Executing Helm command: %s %s\n", helmCmd.Path, strings.Join(helmCmd.Args, " "))
+	log.V(1).Info("Executing helm", "command", strings.Join(helmCmd.Args, " "))
 
 	// Capture the combined output (stdout and stderr)
 	output, err := helmCmd.CombinedOutput()
 
 	if err != nil {
-		// Log the command output and the error
-		fmt.Printf("Helm release failed: %v\nOutput: %s\n", err, string(output))
-		return err
+		return fmt.Errorf("helm release failed: %v\nOutput: %s", err, redactor.String(string(output)))
 	}
 
-	fmt.Printf("Helm release succeeded. Output: %s\n", string(output))
+	log.V(1).Info("Helm release succeeded", "release", releaseName, "output", redactor.String(string(output)))
 	return nil
 }
 
-// syncFromGitRepo clones or pulls the latest changes from the Git repository
-func (r *PulseProDeploymentReconciler) syncFromGitRepo(repoURL, repoDir string) error {
-	// Check if the repo already exists
-	if _, err := os.Stat(repoDir); os.IsNotExist(err) {
-		// Clone the repository if not present
-		r.Log.Info("Cloning repository", "repoURL", repoURL)
-		_, err := git.PlainClone(repoDir, false, &git.CloneOptions{
-			URL: repoURL,
-		})
-		if err != nil {
-			return fmt.Errorf("failed to clone repository: %v", err)
-		}
-	} else {
-		// Check if the .git directory exists inside repoDir
-		if _, err := os.Stat(fmt.Sprintf("%s/.git", repoDir)); os.IsNotExist(err) {
-			return fmt.Errorf("repository exists but does not have a .git directory")
-		}
-
-		// Open the existing repository
-		r.Log.Info("Pulling latest changes from repository", "repoURL", repoURL)
-		repo, err := git.PlainOpen(repoDir)
-		if err != nil {
-			r.Log.Error(err, "Repository does not exist or is not initialized correctly", "repoDir", repoDir)
-			return fmt.Errorf("failed to open repository: %v", err)
-		}
+// HandlePush reconciles the deployments that sync the pushed branch right away, making them check the
+// remote even when their repository was fetched within their sync interval
+func (r *PulseProDeploymentReconciler) HandlePush(ctx context.Context, push receiver.Push) (int, error) {
+	deployments := &v1alpha1.PulseProDeploymentList{}
+	if err := r.List(ctx, deployments); err != nil {
+		return 0, fmt.Errorf("failed to list PulseProDeployments: %v", err)
+	}
 
-		// Log the current branch or commit
-		head, err := repo.Head()
-		if err == nil {
-			r.Log.Info("Repository opened", "branch", head.Name().String(), "commit", head.Hash().String())
-		} else {
-			r.Log.Error(err, "Failed to get repository head")
+	count := 0
+	for i := range deployments.Items {
+		deployment := &deployments.Items[i]
+		if !pushedTo(deployment, push) {
+			continue
 		}
-
-		// Get the worktree and pull the latest changes
-		w, err := repo.Worktree()
-		if err != nil {
-			return fmt.Errorf("failed to get worktree: %v", err)
+		r.Repositories.Expire(deployment.Spec.GitRepoURL, deployment.Spec.GitBranch)
+		select {
+		case r.pushes <- event.TypedGenericEvent[*v1alpha1.PulseProDeployment]{Object: deployment}:
+			count++
+		case <-ctx.Done():
+			return count, ctx.Err()
 		}
+	}
+	return count, nil
+}
 
-		// Pull with additional error handling
-		err = w.Pull(&git.PullOptions{RemoteName: "origin"})
-		if err == git.NoErrAlreadyUpToDate {
-			r.Log.Info("Repository is already up to date", "repoDir", repoDir)
-		} else if err != nil {
-			r.Log.Error(err, "Failed to pull latest changes", "repoDir", repoDir)
-			return fmt.Errorf("failed to pull repository: %v", err)
+// pushedTo reports whether the push updated the repository branch the deployment syncs
+func pushedTo(deployment *v1alpha1.PulseProDeployment, push receiver.Push) bool {
+	branch := deployment.Spec.GitBranch
+	if branch == "" {
+		branch = push.DefaultBranch
+	}
+	if branch != push.Branch {
+		return false
+	}
+	for _, u := range push.URLs {
+		if gitcache.SameRepository(deployment.Spec.GitRepoURL, u) {
+			return true
 		}
 	}
-
-	return nil
+	return false
 }
 
-// updateConfigMap updates the ConfigMap with the latest values from the Git repository
-func (r *PulseProDeploymentReconciler) updateConfigMap(repoDir, valuesFile, valuesSubDir, secretsFile, namespace string) error {
-	// Initialize a map to hold all combined values
-	combinedData := make(map[string]string)
+// verifyCommit checks that the revision checked out in repoDir is signed by a key the deployment trusts.
+// It sets the SignatureVerified condition and returns a classified error when the revision must not be released.
+func (r *PulseProDeploymentReconciler) verifyCommit(ctx context.Context, instance *pulseprov1alpha1.PulseProDeployment, repoDir, revision string) error {
+	setCondition := func(status metav1.ConditionStatus, reason, message string) {
+		meta.SetStatusCondition(&instance.Status.Conditions, metav1.Condition{
+			Type:               pulseprov1alpha1.ConditionSignatureVerified,
+			Status:             status,
+			Reason:             reason,
+			Message:            message,
+			ObservedGeneration: instance.Generation,
+		})
+	}
 
-	// Read the base values file
-	data, err := os.ReadFile(valuesFile)
-	if err != nil {
-		return fmt.Errorf("failed to read base values file: %v", err)
+	verification := instance.Spec.CommitVerification
+	if verification == nil {
+		if slices.Contains(r.VerifiedCommitCategories, instance.Spec.Category) {
+			err := fmt.Errorf("category %s requires spec.commitVerification", instance.Spec.Category)
+			setCondition(metav1.ConditionFalse, pulseprov1alpha1.ReasonVerificationRequired, err.Error())
+			return retry.Configuration("CommitVerificationRequired", err)
+		}
+		meta.RemoveStatusCondition(&instance.Status.Conditions, pulseprov1alpha1.ConditionSignatureVerified)
+		return nil
 	}
-	combinedData["values.yaml"] = string(data)
 
-	// Read the values from the subdirectory (values.yaml.gotmpl)
-	subDirData, err := os.ReadFile(valuesSubDir)
+	secret := &corev1.Secret{}
+	if err := r.Get(ctx, types.NamespacedName{Name: verification.SecretName, Namespace: instance.Namespace}, secret); err != nil {
+		notFound := errors.IsNotFound(err)
+		err = fmt.Errorf("unable to read trusted keys from Secret %s: %v", verification.SecretName, err)
+		setCondition(metav1.ConditionFalse, pulseprov1alpha1.ReasonTrustedKeysUnavailable, err.Error())
+		if notFound {
+			return retry.Configuration("TrustedKeysMissing", err)
+		}
+		return retry.Transient("TrustedKeysUnavailable", err)
+	}
+	keys, err := signature.ParseKeyRing(secret.Data)
 	if err != nil {
-		return fmt.Errorf("failed to read subdirectory values file: %v", err)
+		err = fmt.Errorf("invalid trusted keys in Secret %s: %v", verification.SecretName, err)
+		setCondition(metav1.ConditionFalse, pulseprov1alpha1.ReasonTrustedKeysUnavailable, err.Error())
+		return retry.Configuration("InvalidTrustedKeys", err)
 	}
-	// Change the key to use '.' instead of '/'
-	combinedData["values.values.yaml.gotmpl"] = string(subDirData)
 
-	// Get the existing ConfigMap
-	configMap := &corev1.ConfigMap{}
-	err = r.Get(context.TODO(), types.NamespacedName{Name: "pulsepro-helm-values", Namespace: namespace}, configMap)
+	repo, err := git.PlainOpen(repoDir)
 	if err != nil {
-		return fmt.Errorf("failed to get ConfigMap: %v", err)
+		return fmt.Errorf("failed to open repository: %v", err)
 	}
-
-	// Update the ConfigMap's values with the combined data
-	for key, value := range combinedData {
-		configMap.Data[key] = value
+	commit, err := repo.CommitObject(plumbing.NewHash(revision))
+	if err != nil {
+		return fmt.Errorf("failed to read commit %s: %v", revision, err)
 	}
-
-	// Update the ConfigMap with the new data
-	err = r.Update(context.TODO(), configMap)
+	signer, err := keys.Verify(commit)
 	if err != nil {
-		return fmt.Errorf("failed to update ConfigMap: %v", err)
+		reason := pulseprov1alpha1.ReasonUntrustedSignature
+		if err == signature.ErrUnsigned {
+			reason = pulseprov1alpha1.ReasonUnsignedCommit
+		}
+		err = fmt.Errorf("commit %s rejected: %v", revision, err)
+		setCondition(metav1.ConditionFalse, reason, err.Error())
+		return retry.Configuration(reason, err)
 	}
 
-	r.Log.Info("ConfigMap updated successfully with values and secrets")
+	setCondition(metav1.ConditionTrue, pulseprov1alpha1.ReasonTrustedSignature, fmt.Sprintf("Commit %s is signed by %s", revision, signer))
 	return nil
 }
 
+// deploymentsForRepository returns the deployments that sync the branch of the repository that changed
+func (r *PulseProDeploymentReconciler) deploymentsForRepository(ctx context.Context, change gitcache.Change) []reconcile.Request {
+	deployments := &v1alpha1.PulseProDeploymentList{}
+	if err := r.List(ctx, deployments); err != nil {
+		r.Log.Error(err, "Failed to list PulseProDeployments for repository change", "repoURL", change.URL)
+		return nil
+	}
+
+	var requests []reconcile.Request
+	for _, deployment := range deployments.Items {
+		if deployment.Spec.GitRepoURL == change.URL && deployment.Spec.GitBranch == change.Branch {
+			requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&deployment)})
+		}
+	}
+	return requests
+}
+
 // UpdatePulseProDeployments updates PulsePro deployments based on tags and category
-func UpdatePulseProDeployments(k8sClient client.Client, config *RolloutConfig) error {
+func UpdatePulseProDeployments(log logr.Logger, k8sClient client.Client, config *RolloutConfig) error {
 	ctx := context.TODO()
 
 	for _, rollout := range config.Rollouts {
@@ -545,29 +1285,29 @@ func UpdatePulseProDeployments(k8sClient client.Client, config *RolloutConfig) e
 			}, &pulseProDeployment)
 
 			if err != nil {
-				fmt.Printf("Error fetching PulseProDeployment %s in namespace %s: %v\n", deploymentName, rollout.Namespace, err)
+				log.Error(err, "Failed to fetch PulseProDeployment", "deployment", deploymentName, "namespace", rollout.Namespace)
 				continue
 			}
 
 			// Check if tags or category match the rollout criteria
 			if !utils.MatchesTags(pulseProDeployment.Spec.Tags, rollout.Tags) || !utils.MatchesCategory(pulseProDeployment.Spec.Category, rollout.Category) {
-				fmt.Printf("Skipping deployment %s in namespace %s: tags or category do not match\n", deploymentName, rollout.Namespace)
+				log.Info("Skipping deployment, tags or category do not match", "deployment", deploymentName, "namespace", rollout.Namespace)
 				continue
 			}
 
 			if pulseProDeployment.Spec.PulseProVersion != rollout.ImageVersion {
-				fmt.Printf("Updating %s in namespace %s to image version %s\n", deploymentName, rollout.Namespace, rollout.ImageVersion)
+				log.Info("Updating deployment", "deployment", deploymentName, "namespace", rollout.Namespace, "version", rollout.ImageVersion)
 
 				pulseProDeployment.Spec.PulseProVersion = rollout.ImageVersion
 				err = k8sClient.Update(ctx, &pulseProDeployment)
 
 				if err != nil {
-					fmt.Printf("Error updating PulseProDeployment %s: %v\n", deploymentName, err)
+					log.Error(err, "Failed to update PulseProDeployment", "deployment", deploymentName, "namespace", rollout.Namespace)
 				} else {
-					fmt.Printf("Successfully updated %s in namespace %s to image version %s\n", deploymentName, rollout.Namespace, rollout.ImageVersion)
+					log.Info("Updated deployment", "deployment", deploymentName, "namespace", rollout.Namespace, "version", rollout.ImageVersion)
 				}
 			} else {
-				fmt.Printf("%s is already at version %s\n", deploymentName, rollout.ImageVersion)
+				log.Info("Deployment already at version", "deployment", deploymentName, "namespace", rollout.Namespace, "version", rollout.ImageVersion)
 			}
 		}
 	}
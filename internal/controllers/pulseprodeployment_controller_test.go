@@ -2,18 +2,80 @@ package controllers
 
 import (
 	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
 
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-logr/logr"
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
 	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+	"k8s.io/client-go/tools/record"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
 	pulseprov1alpha1 "github.com/smarter-contracts/pulsepro-operator/api/v1alpha1"
+	"github.com/smarter-contracts/pulsepro-operator/internal/gitcache"
+	"github.com/smarter-contracts/pulsepro-operator/internal/redact"
 )
 
+// newRepositories returns a repository cache and the file:// URL of a local repository whose main branch
+// has a commit with the files
+func newRepositories(files map[string]string) (*gitcache.Cache, string) {
+	origin := GinkgoT().TempDir()
+	repo, err := git.PlainInitWithOptions(origin, &git.PlainInitOptions{
+		InitOptions: git.InitOptions{DefaultBranch: plumbing.NewBranchReferenceName("main")},
+	})
+	Expect(err).NotTo(HaveOccurred())
+	w, err := repo.Worktree()
+	Expect(err).NotTo(HaveOccurred())
+
+	files["README.md"] = "GitOps repository\n"
+	for name, content := range files {
+		path := filepath.Join(origin, name)
+		Expect(os.MkdirAll(filepath.Dir(path), 0o755)).To(Succeed())
+		Expect(os.WriteFile(path, []byte(content), 0o644)).To(Succeed())
+		_, err = w.Add(name)
+		Expect(err).NotTo(HaveOccurred())
+	}
+	_, err = w.Commit("initial commit", &git.CommitOptions{
+		Author: &object.Signature{Name: "test", Email: "test@example.com", When: time.Now()},
+	})
+	Expect(err).NotTo(HaveOccurred())
+
+	return gitcache.New(GinkgoT().TempDir(), time.Minute, logr.Discard()), "file://" + origin
+}
+
+// fakeHelmfile puts a helmfile on PATH that syncs without doing anything and whose diff reports the returned
+// file's content as changes, or no changes while the file is absent. Its invocations are logged to syncs.
+// The diff fails unless helm-diff is asked for a three-way merge, without which live edits are not seen.
+func fakeHelmfile() (diff, syncs string) {
+	bin := GinkgoT().TempDir()
+	script := "#!/bin/sh\n" +
+		"case \" $* \" in\n" +
+		"*\" diff \"*\" --diff-args=--three-way-merge \"*) [ -f \"$0.diff\" ] && cat \"$0.diff\" && exit 2; exit 0;;\n" +
+		"*\" diff \"*) echo \"diff without --three-way-merge\" >&2; exit 1;;\n" +
+		"*\" sync \"*) echo sync >> \"$0.syncs\";;\n" +
+		"esac\n"
+	Expect(os.WriteFile(filepath.Join(bin, "helmfile"), []byte(script), 0o755)).To(Succeed())
+	GinkgoT().Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+	return filepath.Join(bin, "helmfile.diff"), filepath.Join(bin, "helmfile.syncs")
+}
+
 var _ = Describe("PulseProDeployment Controller", func() {
 	Context("When reconciling a resource", func() {
 		const resourceName = "test-resource"
@@ -25,9 +87,12 @@ var _ = Describe("PulseProDeployment Controller", func() {
 			Namespace: "default", // TODO(user):Modify as needed
 		}
 		pulseprodeployment := &pulseprov1alpha1.PulseProDeployment{}
+		var repositories *gitcache.Cache
 
 		BeforeEach(func() {
 			By("creating the custom resource for the Kind PulseProDeployment")
+			var repoURL string
+			repositories, repoURL = newRepositories(map[string]string{})
 			err := k8sClient.Get(ctx, typeNamespacedName, pulseprodeployment)
 			if err != nil && errors.IsNotFound(err) {
 				resource := &pulseprov1alpha1.PulseProDeployment{
@@ -35,7 +100,10 @@ var _ = Describe("PulseProDeployment Controller", func() {
 						Name:      resourceName,
 						Namespace: "default",
 					},
-					// TODO(user): Specify other spec details if needed.
+					Spec: pulseprov1alpha1.PulseProDeploymentSpec{
+						GitRepoURL: repoURL,
+						GitBranch:  "main",
+					},
 				}
 				Expect(k8sClient.Create(ctx, resource)).To(Succeed())
 			}
@@ -53,10 +121,12 @@ var _ = Describe("PulseProDeployment Controller", func() {
 		It("should successfully reconcile the resource", func() {
 			By("Reconciling the created resource")
 			controllerReconciler := &PulseProDeploymentReconciler{
-				Client: k8sClient,
+				Client:       k8sClient,
+				Recorder:     record.NewFakeRecorder(100),
+				Repositories: repositories,
 			}
 
-			_, err := controllerReconciler.Reconcile(reconcile.Request{
+			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{
 				NamespacedName: typeNamespacedName,
 			})
 			Expect(err).NotTo(HaveOccurred())
@@ -64,4 +134,542 @@ var _ = Describe("PulseProDeployment Controller", func() {
 			// Example: If you expect a certain status condition after reconciliation, verify it here.
 		})
 	})
+
+	Context("When the deployment targets another cluster", func() {
+		const (
+			resourceName = "remote-deployment"
+			secretName   = "remote-kubeconfig"
+		)
+
+		ctx := context.Background()
+		key := types.NamespacedName{Name: resourceName, Namespace: "default"}
+		var repositories *gitcache.Cache
+
+		// kubeconfigFor returns a kubeconfig for the cluster of config
+		kubeconfigFor := func(config *rest.Config) []byte {
+			data, err := clientcmd.Write(clientcmdapi.Config{
+				Clusters:       map[string]*clientcmdapi.Cluster{"remote": {Server: config.Host, CertificateAuthorityData: config.CAData}},
+				AuthInfos:      map[string]*clientcmdapi.AuthInfo{"remote": {ClientCertificateData: config.CertData, ClientKeyData: config.KeyData, Token: config.BearerToken}},
+				Contexts:       map[string]*clientcmdapi.Context{"remote": {Cluster: "remote", AuthInfo: "remote"}},
+				CurrentContext: "remote",
+			})
+			Expect(err).NotTo(HaveOccurred())
+			return data
+		}
+
+		reconcileDeployment := func() *pulseprov1alpha1.PulseProDeployment {
+			controllerReconciler := &PulseProDeploymentReconciler{
+				Client:       k8sClient,
+				Recorder:     record.NewFakeRecorder(100),
+				Repositories: repositories,
+			}
+			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+			Expect(err).NotTo(HaveOccurred())
+
+			deployment := &pulseprov1alpha1.PulseProDeployment{}
+			Expect(k8sClient.Get(ctx, key, deployment)).To(Succeed())
+			return deployment
+		}
+
+		BeforeEach(func() {
+			var repoURL string
+			repositories, repoURL = newRepositories(map[string]string{})
+			Expect(k8sClient.Create(ctx, &pulseprov1alpha1.PulseProDeployment{
+				ObjectMeta: metav1.ObjectMeta{Name: resourceName, Namespace: "default"},
+				Spec: pulseprov1alpha1.PulseProDeploymentSpec{
+					GitRepoURL:          repoURL,
+					GitBranch:           "main",
+					Namespace:           "pulsepro",
+					HelmChart:           "oci://registry.example.com/charts/pulse-pro",
+					HelmChartVersion:    "1.0.0",
+					PulseProVersion:     "2.3.0",
+					HelmValuesConfigMap: pulseprov1alpha1.ConfigMapReference{Name: "remote-values", Key: "values.yaml"},
+					Secrets:             []pulseprov1alpha1.SecretReference{},
+					ProjectName:         "acme",
+					EnvironmentName:     "staging",
+					SyncInterval:        "10m",
+					KubeconfigSecretRef: &pulseprov1alpha1.KubeconfigSecretReference{Name: secretName},
+				},
+			})).To(Succeed())
+		})
+
+		AfterEach(func() {
+			Expect(k8sClient.Delete(ctx, &pulseprov1alpha1.PulseProDeployment{
+				ObjectMeta: metav1.ObjectMeta{Name: resourceName, Namespace: "default"},
+			})).To(Succeed())
+			_ = k8sClient.Delete(ctx, &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: secretName, Namespace: "default"}})
+		})
+
+		It("should report a missing kubeconfig Secret on the deployment", func() {
+			deployment := reconcileDeployment()
+
+			Expect(deployment.Status.LastFailure).NotTo(BeNil())
+			Expect(deployment.Status.LastFailure.Class).To(Equal("Configuration"))
+			Expect(deployment.Status.LastFailure.Reason).To(Equal("KubeconfigMissing"))
+			condition := meta.FindStatusCondition(deployment.Status.Conditions, pulseprov1alpha1.ConditionClusterConnected)
+			Expect(condition).NotTo(BeNil())
+			Expect(condition.Status).To(Equal(metav1.ConditionFalse))
+			Expect(condition.Reason).To(Equal(pulseprov1alpha1.ReasonKubeconfigInvalid))
+		})
+
+		It("should reject kubeconfigs that run commands or read files", func() {
+			config, err := clientcmd.Load(kubeconfigFor(cfg))
+			Expect(err).NotTo(HaveOccurred())
+			config.AuthInfos["remote"].Exec = &clientcmdapi.ExecConfig{Command: "/bin/sh", Args: []string{"-c", "env"}}
+			data, err := clientcmd.Write(*config)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(k8sClient.Create(ctx, &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Name: secretName, Namespace: "default"},
+				Data:       map[string][]byte{"kubeconfig": data},
+			})).To(Succeed())
+
+			deployment := reconcileDeployment()
+
+			Expect(deployment.Status.LastFailure.Reason).To(Equal("KubeconfigNotAllowed"))
+			condition := meta.FindStatusCondition(deployment.Status.Conditions, pulseprov1alpha1.ConditionClusterConnected)
+			Expect(condition.Reason).To(Equal(pulseprov1alpha1.ReasonKubeconfigInvalid))
+			Expect(condition.Message).To(ContainSubstring("exec credential plugin"))
+
+			Expect(checkKubeconfig(&clientcmdapi.Config{
+				AuthInfos: map[string]*clientcmdapi.AuthInfo{"remote": {TokenFile: "/var/run/secrets/kubernetes.io/serviceaccount/token"}},
+			})).To(MatchError(ContainSubstring("reads its token from a file")))
+			Expect(checkKubeconfig(&clientcmdapi.Config{
+				AuthInfos: map[string]*clientcmdapi.AuthInfo{"remote": {ClientKey: "/etc/ssl/key.pem"}},
+			})).To(MatchError(ContainSubstring("client certificate from a file")))
+			Expect(checkKubeconfig(&clientcmdapi.Config{
+				Clusters: map[string]*clientcmdapi.Cluster{"remote": {CertificateAuthority: "/etc/ssl/ca.pem"}},
+			})).To(MatchError(ContainSubstring("certificate authority from a file")))
+		})
+
+		It("should connect to the cluster of the kubeconfig Secret", func() {
+			Expect(k8sClient.Create(ctx, &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Name: secretName, Namespace: "default"},
+				Data:       map[string][]byte{"kubeconfig": kubeconfigFor(cfg)},
+			})).To(Succeed())
+
+			deployment := reconcileDeployment()
+
+			condition := meta.FindStatusCondition(deployment.Status.Conditions, pulseprov1alpha1.ConditionClusterConnected)
+			Expect(condition).NotTo(BeNil())
+			Expect(condition.Status).To(Equal(metav1.ConditionTrue))
+			Expect(condition.Reason).To(Equal(pulseprov1alpha1.ReasonConnected))
+			// The reconcile goes on to the values, whose ConfigMap this test does not provide
+			Expect(deployment.Status.LastFailure.Reason).To(Equal("ValuesMissing"))
+		})
+	})
+
+	Context("When the repository of a failed deployment moves", func() {
+		const resourceName = "retried-deployment"
+
+		ctx := context.Background()
+		key := types.NamespacedName{Name: resourceName, Namespace: "default"}
+
+		It("should retry right away instead of waiting for the next retry", func() {
+			repositories, repoURL := newRepositories(map[string]string{})
+			Expect(k8sClient.Create(ctx, &pulseprov1alpha1.PulseProDeployment{
+				ObjectMeta: metav1.ObjectMeta{Name: resourceName, Namespace: "default"},
+				Spec: pulseprov1alpha1.PulseProDeploymentSpec{
+					Namespace:           "pulsepro",
+					HelmChart:           "oci://registry.example.com/charts/pulse-pro",
+					PulseProVersion:     "2.3.0",
+					HelmValuesConfigMap: pulseprov1alpha1.ConfigMapReference{Name: "retried-values", Key: "values.yaml"},
+					Secrets:             []pulseprov1alpha1.SecretReference{},
+					GitRepoURL:          repoURL,
+					GitBranch:           "main",
+					ProjectName:         "acme",
+					EnvironmentName:     "staging",
+					SyncInterval:        "10m",
+				},
+			})).To(Succeed())
+			DeferCleanup(func() {
+				Expect(k8sClient.Delete(ctx, &pulseprov1alpha1.PulseProDeployment{ObjectMeta: metav1.ObjectMeta{Name: resourceName, Namespace: "default"}})).To(Succeed())
+			})
+			reconciler := &PulseProDeploymentReconciler{Client: k8sClient, Recorder: record.NewFakeRecorder(100), Repositories: repositories}
+			reconcileDeployment := func() *pulseprov1alpha1.PulseProDeployment {
+				_, err := reconciler.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+				Expect(err).NotTo(HaveOccurred())
+				deployment := &pulseprov1alpha1.PulseProDeployment{}
+				Expect(k8sClient.Get(ctx, key, deployment)).To(Succeed())
+				return deployment
+			}
+
+			failure := reconcileDeployment().Status.LastFailure
+			Expect(failure.Reason).To(Equal("ValuesMissing"))
+			Expect(failure.Revision).NotTo(BeEmpty())
+			Expect(failure.NextRetryTime.After(time.Now())).To(BeTrue())
+
+			// Until its retry time, the same revision is not reconciled again
+			Expect(reconcileDeployment().Status.LastFailure.RetryCount).To(Equal(int32(1)))
+
+			// A push makes the deployment check out the new revision right away
+			origin := strings.TrimPrefix(repoURL, "file://")
+			repo, err := git.PlainOpen(origin)
+			Expect(err).NotTo(HaveOccurred())
+			w, err := repo.Worktree()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(os.WriteFile(filepath.Join(origin, "README.md"), []byte("updated\n"), 0o644)).To(Succeed())
+			_, err = w.Add("README.md")
+			Expect(err).NotTo(HaveOccurred())
+			hash, err := w.Commit("update", &git.CommitOptions{
+				Author: &object.Signature{Name: "test", Email: "test@example.com", When: time.Now()},
+			})
+			Expect(err).NotTo(HaveOccurred())
+			repositories.Expire(repoURL, "main")
+
+			failure = reconcileDeployment().Status.LastFailure
+			Expect(failure.Revision).To(Equal(hash.String()))
+			Expect(failure.RetryCount).To(Equal(int32(1)))
+		})
+	})
+
+	Context("When the deployment publishes its effective values", func() {
+		const resourceName = "published-deployment"
+
+		ctx := context.Background()
+
+		It("should mask the secrets file and the environment in the published values", func() {
+			repositories, repoURL := newRepositories(map[string]string{
+				"environments/acme-prod/secrets/pulse-pro/secrets.yaml.dec":  "db:\n  dsn: hunter2-secret\n",
+				"environments/acme-prod/values/pulse-pro/values.yaml.gotmpl": "path: {{ requiredEnv \"PATH\" }}\ndsn: {{ .Values.db.dsn }}\n",
+			})
+			Expect(k8sClient.Create(ctx, &pulseprov1alpha1.PulseProDeployment{
+				ObjectMeta: metav1.ObjectMeta{Name: resourceName, Namespace: "default"},
+				Spec: pulseprov1alpha1.PulseProDeploymentSpec{
+					Namespace:       "pulsepro",
+					HelmChart:       "oci://registry.example.com/charts/pulse-pro",
+					PulseProVersion: "2.3.0",
+					Secrets:         []pulseprov1alpha1.SecretReference{},
+					ValuesFrom:      []pulseprov1alpha1.ValuesSource{{Inline: "db:\n  dsn: hunter2-secret\n"}},
+					GitRepoURL:      repoURL,
+					GitBranch:       "main",
+					ProjectName:     "acme",
+					EnvironmentName: "prod",
+					SyncInterval:    "10m",
+				},
+			})).To(Succeed())
+			DeferCleanup(func() {
+				Expect(k8sClient.Delete(ctx, &pulseprov1alpha1.PulseProDeployment{ObjectMeta: metav1.ObjectMeta{Name: resourceName, Namespace: "default"}})).To(Succeed())
+			})
+
+			reconciler := &PulseProDeploymentReconciler{Client: k8sClient, Scheme: k8sClient.Scheme(), Recorder: record.NewFakeRecorder(100), Repositories: repositories}
+			_, err := reconciler.Reconcile(ctx, reconcile.Request{NamespacedName: types.NamespacedName{Name: resourceName, Namespace: "default"}})
+			Expect(err).NotTo(HaveOccurred())
+
+			cm := &corev1.ConfigMap{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: resourceName + "-effective-values", Namespace: "default"}, cm)).To(Succeed())
+			Expect(cm.Data).To(HaveKey(renderedValuesKey))
+			for _, published := range cm.Data {
+				Expect(published).NotTo(ContainSubstring("hunter2-secret"))
+				Expect(published).NotTo(ContainSubstring(os.Getenv("PATH")))
+			}
+		})
+
+		It("should not take over a ConfigMap it does not own", func() {
+			// The ConfigMap of the spec above is never garbage collected, so another deployment is used
+			const unownedName = "unowned-deployment"
+			deployment := &pulseprov1alpha1.PulseProDeployment{
+				ObjectMeta: metav1.ObjectMeta{Name: unownedName, Namespace: "default", UID: "unowned-deployment-uid"},
+				Status:     pulseprov1alpha1.PulseProDeploymentStatus{EffectiveValuesConfigMap: unownedName + "-effective-values"},
+			}
+			cm := &corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{Name: unownedName + "-effective-values", Namespace: "default"},
+				Data:       map[string]string{"values.yaml": "user: data\n"},
+			}
+			Expect(k8sClient.Create(ctx, cm)).To(Succeed())
+			DeferCleanup(func() { Expect(k8sClient.Delete(ctx, cm)).To(Succeed()) })
+
+			reconciler := &PulseProDeploymentReconciler{Client: k8sClient, Scheme: k8sClient.Scheme()}
+			err := reconciler.publishEffectiveValues(ctx, deployment, &mergedValues{redacted: "replicas: 3\n", hash: "sha256:0"}, "")
+			Expect(err).To(MatchError(ContainSubstring("is not managed by the deployment")))
+			Expect(deployment.Status.EffectiveValuesConfigMap).To(BeEmpty())
+
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: cm.Name, Namespace: "default"}, cm)).To(Succeed())
+			Expect(cm.Data).To(Equal(map[string]string{"values.yaml": "user: data\n"}))
+			Expect(cm.OwnerReferences).To(BeEmpty())
+		})
+	})
+
+	Context("When the live objects of a deployment drift", func() {
+		const resourceName = "drifting-deployment"
+		const driftOutput = "default, pulse-pro, Deployment (apps) has changed:\n"
+
+		ctx := context.Background()
+		var (
+			diff, syncs string
+			reconciler  *PulseProDeploymentReconciler
+		)
+
+		// reconcileDrift reconciles the deployment with the drift policy and returns it
+		reconcileDrift := func() *pulseprov1alpha1.PulseProDeployment {
+			_, err := reconciler.Reconcile(ctx, reconcile.Request{NamespacedName: types.NamespacedName{Name: resourceName, Namespace: "default"}})
+			Expect(err).NotTo(HaveOccurred())
+			deployment := &pulseprov1alpha1.PulseProDeployment{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: resourceName, Namespace: "default"}, deployment)).To(Succeed())
+			return deployment
+		}
+
+		syncCount := func() int {
+			data, err := os.ReadFile(syncs)
+			if os.IsNotExist(err) {
+				return 0
+			}
+			Expect(err).NotTo(HaveOccurred())
+			return strings.Count(string(data), "sync")
+		}
+
+		createDeployment := func(policy string) {
+			repositories, repoURL := newRepositories(map[string]string{
+				"environments/acme-prod/secrets/pulse-pro/secrets.yaml.dec": "db:\n  password: secret\n",
+			})
+			Expect(k8sClient.Create(ctx, &pulseprov1alpha1.PulseProDeployment{
+				ObjectMeta: metav1.ObjectMeta{Name: resourceName, Namespace: "default"},
+				Spec: pulseprov1alpha1.PulseProDeploymentSpec{
+					Namespace:       "pulsepro",
+					HelmChart:       "oci://registry.example.com/charts/pulse-pro",
+					PulseProVersion: "2.3.0",
+					Secrets:         []pulseprov1alpha1.SecretReference{},
+					ValuesFrom:      []pulseprov1alpha1.ValuesSource{{Inline: "replicas: 1\n"}},
+					GitRepoURL:      repoURL,
+					GitBranch:       "main",
+					ProjectName:     "acme",
+					EnvironmentName: "prod",
+					SyncInterval:    "10m",
+					DriftPolicy:     policy,
+				},
+			})).To(Succeed())
+			DeferCleanup(func() {
+				Expect(k8sClient.Delete(ctx, &pulseprov1alpha1.PulseProDeployment{ObjectMeta: metav1.ObjectMeta{Name: resourceName, Namespace: "default"}})).To(Succeed())
+			})
+			reconciler = &PulseProDeploymentReconciler{Client: k8sClient, Scheme: k8sClient.Scheme(), Recorder: record.NewFakeRecorder(100), Repositories: repositories}
+
+			// The first reconcile releases the desired state
+			Expect(reconcileDrift().Status.Status).To(Equal("Synced"))
+			Expect(syncCount()).To(Equal(1))
+		}
+
+		BeforeEach(func() {
+			diff, syncs = fakeHelmfile()
+		})
+
+		It("should report drift without syncing with the Report policy", func() {
+			createDeployment(pulseprov1alpha1.DriftPolicyReport)
+
+			Expect(os.WriteFile(diff, []byte(driftOutput), 0o644)).To(Succeed())
+			deployment := reconcileDrift()
+			Expect(deployment.Status.Status).To(Equal("Drifted"))
+			Expect(deployment.Status.DriftedObjects).To(HaveLen(1))
+			Expect(deployment.Status.DriftedObjects[0].Name).To(Equal("pulse-pro"))
+			Expect(meta.IsStatusConditionTrue(deployment.Status.Conditions, pulseprov1alpha1.ConditionDrifted)).To(BeTrue())
+			Expect(syncCount()).To(Equal(1))
+
+			// Once the live objects match again, the deployment is reported as synced
+			Expect(os.Remove(diff)).To(Succeed())
+			deployment = reconcileDrift()
+			Expect(deployment.Status.Status).To(Equal("Synced"))
+			Expect(deployment.Status.DriftedObjects).To(BeEmpty())
+			Expect(meta.IsStatusConditionFalse(deployment.Status.Conditions, pulseprov1alpha1.ConditionDrifted)).To(BeTrue())
+			Expect(syncCount()).To(Equal(1))
+		})
+
+		It("should re-sync drifted deployments with the Correct policy", func() {
+			createDeployment(pulseprov1alpha1.DriftPolicyCorrect)
+
+			Expect(os.WriteFile(diff, []byte(driftOutput), 0o644)).To(Succeed())
+			deployment := reconcileDrift()
+			Expect(syncCount()).To(Equal(2))
+			Expect(deployment.Status.Status).To(Equal("Synced"))
+			Expect(deployment.Status.DriftedObjects).To(BeEmpty())
+			condition := meta.FindStatusCondition(deployment.Status.Conditions, pulseprov1alpha1.ConditionDrifted)
+			Expect(condition.Status).To(Equal(metav1.ConditionFalse))
+			Expect(condition.Reason).To(Equal(pulseprov1alpha1.ReasonDriftCorrected))
+
+			// Without drift nothing is synced
+			Expect(os.Remove(diff)).To(Succeed())
+			Expect(reconcileDrift().Status.Status).To(Equal("Synced"))
+			Expect(syncCount()).To(Equal(2))
+		})
+	})
+
+	Context("When the deployment mirrors the repository's values files", func() {
+		const resourceName = "mirrored-deployment"
+
+		ctx := context.Background()
+		var (
+			repoDir    string
+			deployment *pulseprov1alpha1.PulseProDeployment
+			reconciler *PulseProDeploymentReconciler
+		)
+
+		writeFile := func(path, content string) {
+			path = filepath.Join(repoDir, path)
+			Expect(os.MkdirAll(filepath.Dir(path), 0o755)).To(Succeed())
+			Expect(os.WriteFile(path, []byte(content), 0o644)).To(Succeed())
+		}
+
+		BeforeEach(func() {
+			repositories, repoURL := newRepositories(map[string]string{})
+			repoDir = GinkgoT().TempDir()
+			writeFile("environments/acme-prod/values/values.yaml", "replicas: 3\n")
+			writeFile("environments/acme-prod/values/pulse-pro/extra.yaml", "debug: true\n")
+
+			deployment = &pulseprov1alpha1.PulseProDeployment{
+				ObjectMeta: metav1.ObjectMeta{Name: resourceName, Namespace: "default"},
+				Spec: pulseprov1alpha1.PulseProDeploymentSpec{
+					Namespace:       "pulsepro",
+					HelmChart:       "oci://registry.example.com/charts/pulse-pro",
+					PulseProVersion: "2.3.0",
+					Secrets:         []pulseprov1alpha1.SecretReference{},
+					GitRepoURL:      repoURL,
+					ProjectName:     "acme",
+					EnvironmentName: "prod",
+					SyncInterval:    "10m",
+					ValuesMirror:    &pulseprov1alpha1.ValuesMirror{},
+				},
+			}
+			Expect(k8sClient.Create(ctx, deployment)).To(Succeed())
+			reconciler = &PulseProDeploymentReconciler{Client: k8sClient, Scheme: k8sClient.Scheme(), Repositories: repositories}
+		})
+
+		AfterEach(func() {
+			Expect(k8sClient.Delete(ctx, deployment)).To(Succeed())
+			_ = k8sClient.Delete(ctx, &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: resourceName + "-repo-values", Namespace: "default"}})
+		})
+
+		It("should create an owned ConfigMap and prune removed files", func() {
+			Expect(reconciler.mirrorRepoValues(ctx, deployment, repoDir, "1111111", redact.New())).To(Succeed())
+			Expect(deployment.Status.ValuesMirrorConfigMap).To(Equal(resourceName + "-repo-values"))
+
+			cm := &corev1.ConfigMap{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: resourceName + "-repo-values", Namespace: "default"}, cm)).To(Succeed())
+			Expect(cm.Data).To(HaveKeyWithValue("environments.acme-prod.values.values.yaml", "replicas: 3\n"))
+			Expect(cm.Data).To(HaveKey("environments.acme-prod.values.pulse-pro.extra.yaml"))
+			Expect(cm.Annotations).To(HaveKeyWithValue(pulseprov1alpha1.SourceRevisionAnnotation, "1111111"))
+			Expect(metav1.IsControlledBy(cm, deployment)).To(BeTrue())
+
+			Expect(os.Remove(filepath.Join(repoDir, "environments/acme-prod/values/pulse-pro/extra.yaml"))).To(Succeed())
+			Expect(reconciler.mirrorRepoValues(ctx, deployment, repoDir, "2222222", redact.New())).To(Succeed())
+
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: resourceName + "-repo-values", Namespace: "default"}, cm)).To(Succeed())
+			Expect(cm.Data).To(HaveLen(1))
+			Expect(cm.Annotations).To(HaveKeyWithValue(pulseprov1alpha1.SourceRevisionAnnotation, "2222222"))
+		})
+
+		It("should not take over a ConfigMap it does not own", func() {
+			Expect(k8sClient.Create(ctx, &corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{Name: resourceName + "-repo-values", Namespace: "default"},
+				Data:       map[string]string{"values.yaml": "user: data\n"},
+			})).To(Succeed())
+
+			err := reconciler.mirrorRepoValues(ctx, deployment, repoDir, "1111111", redact.New())
+			Expect(err).To(MatchError(ContainSubstring("is not managed by the deployment")))
+		})
+
+		It("should only mirror the values files of the environment", func() {
+			writeFile("environments/acme-prod/secrets/pulse-pro/secrets.yaml.dec", "db:\n  dsn: hunter2-secret\n")
+			Expect(os.Symlink(filepath.Join(repoDir, "environments/acme-prod/secrets"), filepath.Join(repoDir, "environments/acme-prod/values/secrets"))).To(Succeed())
+
+			for _, path := range []string{
+				"environments/acme-prod/secrets",
+				"environments/acme-prod/values/../secrets/pulse-pro/secrets.yaml.dec",
+				"environments/acme-prod/values/secrets",
+				"environments/acme-staging/values",
+			} {
+				deployment.Spec.ValuesMirror.Paths = []string{path}
+				err := reconciler.mirrorRepoValues(ctx, deployment, repoDir, "1111111", redact.New())
+				Expect(err).To(MatchError(ContainSubstring("is outside of the values files")), "path %s", path)
+			}
+		})
+
+		It("should redact the mirrored values files", func() {
+			writeFile("environments/acme-prod/values/pulse-pro/db.yaml", "dsn: hunter2-secret\n")
+
+			Expect(reconciler.mirrorRepoValues(ctx, deployment, repoDir, "1111111", redact.New("hunter2-secret"))).To(Succeed())
+
+			cm := &corev1.ConfigMap{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: resourceName + "-repo-values", Namespace: "default"}, cm)).To(Succeed())
+			Expect(cm.Data).To(HaveKey("environments.acme-prod.values.pulse-pro.db.yaml"))
+			Expect(cm.Data["environments.acme-prod.values.pulse-pro.db.yaml"]).NotTo(ContainSubstring("hunter2-secret"))
+		})
+	})
+
+	Context("When the deployment's versions are ranges", func() {
+		ctx := context.Background()
+		var (
+			tags       []string
+			host       string
+			deployment *pulseprov1alpha1.PulseProDeployment
+			reconciler *PulseProDeploymentReconciler
+			recorder   *record.FakeRecorder
+		)
+
+		BeforeEach(func() {
+			tags = []string{"2.3.9", "2.4.0", "2.4.3", "2.5.0"}
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				Expect(r.URL.Path).To(Equal("/v2/pulsepro/pulse-pro/tags/list"))
+				Expect(json.NewEncoder(w).Encode(map[string][]string{"tags": tags})).To(Succeed())
+			}))
+			DeferCleanup(server.Close)
+			host = strings.TrimPrefix(server.URL, "http://")
+
+			deployment = &pulseprov1alpha1.PulseProDeployment{
+				ObjectMeta: metav1.ObjectMeta{Name: "ranged-deployment", Namespace: "default", Generation: 1},
+				Spec: pulseprov1alpha1.PulseProDeploymentSpec{
+					HelmChart:        "oci://registry.example.com/charts/pulse-pro",
+					HelmChartVersion: "1.0.0",
+					PulseProVersion:  "~2.4",
+					ImageRepository:  host + "/pulsepro/pulse-pro",
+				},
+			}
+			recorder = record.NewFakeRecorder(10)
+			repositories, _ := newRepositories(map[string]string{})
+			reconciler = &PulseProDeploymentReconciler{Client: k8sClient, Scheme: k8sClient.Scheme(), Recorder: recorder, Repositories: repositories}
+		})
+
+		It("should pick up new matching versions with the Auto policy", func() {
+			Expect(reconciler.resolveVersions(ctx, deployment, time.Minute)).To(Succeed())
+			Expect(deployment.Status.ResolvedVersions.PulseProVersion).To(Equal("2.4.3"))
+			Expect(deployment.Status.ResolvedVersions.HelmChartVersion).To(Equal("1.0.0"))
+			Expect(desiredVersion(deployment)).To(Equal("2.4.3"))
+			Expect(versionStateValues(deployment)).To(Equal([]string{
+				"--state-values-set", "pulseProVersion=2.4.3", "--state-values-set", "helmChartVersion=1.0.0",
+			}))
+			Expect(recorder.Events).To(Receive(ContainSubstring("Resolved PulsePro ~2.4 to 2.4.3")))
+
+			tags = append(tags, "2.4.4")
+			Expect(reconciler.resolveVersions(ctx, deployment, time.Minute)).To(Succeed())
+			Expect(desiredVersion(deployment)).To(Equal("2.4.4"))
+		})
+
+		It("should keep the resolved versions and report newer ones with the Notify policy", func() {
+			deployment.Spec.UpdatePolicy = pulseprov1alpha1.UpdatePolicyNotify
+			Expect(reconciler.resolveVersions(ctx, deployment, time.Minute)).To(Succeed())
+			Expect(desiredVersion(deployment)).To(Equal("2.4.3"))
+
+			tags = append(tags, "2.4.4")
+			Expect(reconciler.resolveVersions(ctx, deployment, time.Minute)).To(Succeed())
+			Expect(desiredVersion(deployment)).To(Equal("2.4.3"))
+			Expect(deployment.Status.ResolvedVersions.AvailablePulseProVersion).To(Equal("2.4.4"))
+			Expect(meta.IsStatusConditionTrue(deployment.Status.Conditions, pulseprov1alpha1.ConditionUpdateAvailable)).To(BeTrue())
+
+			// A spec change resolves the range again
+			deployment.Generation = 2
+			Expect(reconciler.resolveVersions(ctx, deployment, time.Minute)).To(Succeed())
+			Expect(desiredVersion(deployment)).To(Equal("2.4.4"))
+			Expect(meta.IsStatusConditionTrue(deployment.Status.Conditions, pulseprov1alpha1.ConditionUpdateAvailable)).To(BeFalse())
+		})
+
+		It("should read the versions of the chart from helm search", func() {
+			found, err := parseChartSearch([]byte(`[{"name": "acme/pulse-pro", "version": "1.2.0"}, {"name": "acme/pulse-pro-crds", "version": "9.0.0"}, {"name": "acme/pulse-pro", "version": "1.3.0"}]`), "acme/pulse-pro")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(found).To(Equal([]string{"1.2.0", "1.3.0"}))
+		})
+
+		It("should fail when no version matches or the image repository is missing", func() {
+			deployment.Spec.PulseProVersion = "^3"
+			Expect(reconciler.resolveVersions(ctx, deployment, time.Minute)).To(MatchError(ContainSubstring(`no version matches "^3"`)))
+
+			deployment.Spec.ImageRepository = ""
+			Expect(reconciler.resolveVersions(ctx, deployment, time.Minute)).To(MatchError(ContainSubstring("needs an imageRepository")))
+		})
+	})
 })
@@ -0,0 +1,257 @@
+package controllers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+
+	pulseprov1alpha1 "github.com/smarter-contracts/pulsepro-operator/api/v1alpha1"
+	"github.com/smarter-contracts/pulsepro-operator/internal/registry"
+	"github.com/smarter-contracts/pulsepro-operator/internal/versions"
+)
+
+const (
+	defaultImageWatchInterval = 5 * time.Minute
+	defaultImageWatchTimeout  = 30 * time.Second
+)
+
+// PulseProImageWatchReconciler polls the registry repositories of PulseProImageWatches and creates a
+// PulseProRollout whenever a newer tag passes a watch's filter
+type PulseProImageWatchReconciler struct {
+	client.Client
+	Scheme   *runtime.Scheme
+	Recorder record.EventRecorder
+
+	// RegistryProviders provide registry credentials for the hosts the watches have no credentials for
+	RegistryProviders registry.Provider
+
+	// HTTPClient lists the tags of the registries; defaults to http.DefaultClient
+	HTTPClient *http.Client
+
+	// Timeout bounds each poll of a registry; defaults to 30 seconds
+	Timeout time.Duration
+}
+
+// +kubebuilder:rbac:groups=pulsepro.pulsepro.io,resources=pulseproimagewatches,verbs=get;list;watch
+// +kubebuilder:rbac:groups=pulsepro.pulsepro.io,resources=pulseproimagewatches/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=pulsepro.pulsepro.io,resources=pulseprorollouts,verbs=get;list;watch;create
+// +kubebuilder:rbac:groups=core,resources=secrets,verbs=get
+// +kubebuilder:rbac:groups=core,resources=events,verbs=create;patch
+
+// Reconcile polls the repository of a watch and creates a rollout for the newest matching tag once it changes
+func (r *PulseProImageWatchReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	l := log.FromContext(ctx)
+
+	watch := &pulseprov1alpha1.PulseProImageWatch{}
+	if err := r.Get(ctx, req.NamespacedName, watch); err != nil {
+		if errors.IsNotFound(err) {
+			return ctrl.Result{}, nil
+		}
+		return ctrl.Result{}, err
+	}
+
+	interval, err := time.ParseDuration(watch.Spec.Interval)
+	if err != nil || interval <= 0 {
+		interval = defaultImageWatchInterval
+	}
+
+	setCondition := func(status metav1.ConditionStatus, reason, message string) {
+		meta.SetStatusCondition(&watch.Status.Conditions, metav1.Condition{
+			Type:               pulseprov1alpha1.ConditionTagsListed,
+			Status:             status,
+			Reason:             reason,
+			Message:            message,
+			ObservedGeneration: watch.Generation,
+		})
+	}
+
+	if watch.Spec.Suspend {
+		setCondition(metav1.ConditionFalse, pulseprov1alpha1.ReasonSuspended, "Polling is suspended")
+		return ctrl.Result{}, r.Status().Update(ctx, watch)
+	}
+
+	// The generation is only observed once a poll of it succeeded, so that a failed poll after a spec change
+	// still treats the next one as a spec change
+	specChanged := watch.Status.ObservedGeneration != watch.Generation
+
+	// An invalid filter waits for a spec change
+	pattern, err := compileFilter(watch.Spec.Filter)
+	if err != nil {
+		setCondition(metav1.ConditionFalse, pulseprov1alpha1.ReasonInvalidFilter, err.Error())
+		return ctrl.Result{}, r.Status().Update(ctx, watch)
+	}
+
+	now := metav1.Now()
+	watch.Status.LastPollTime = &now
+	tag, err := r.newestTag(ctx, watch, pattern)
+	if err != nil {
+		l.Error(err, "Failed to poll repository", "repository", watch.Spec.Repository)
+		r.Recorder.Eventf(watch, corev1.EventTypeWarning, EventReasonPollFailed, "Failed to poll %s: %v", watch.Spec.Repository, err)
+		setCondition(metav1.ConditionFalse, pulseprov1alpha1.ReasonRegistryUnavailable, err.Error())
+		return ctrl.Result{RequeueAfter: interval}, r.Status().Update(ctx, watch)
+	}
+	if tag == "" {
+		watch.Status.ObservedGeneration = watch.Generation
+		setCondition(metav1.ConditionFalse, pulseprov1alpha1.ReasonNoMatchingTag, fmt.Sprintf("No tag of %s passes the filter", watch.Spec.Repository))
+		return ctrl.Result{RequeueAfter: interval}, r.Status().Update(ctx, watch)
+	}
+	setCondition(metav1.ConditionTrue, pulseprov1alpha1.ReasonTagsListed, fmt.Sprintf("The newest matching tag is %s", tag))
+
+	// After a spec change the filter may select older tags, so any other newest tag is rolled out
+	latest := watch.Status.LatestTag
+	if tag != latest && (latest == "" || specChanged || versions.Newer(tag, latest)) {
+		name, err := r.createRollout(ctx, watch, tag)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+		l.Info("Created rollout for new tag", "tag", tag, "rollout", name)
+		r.Recorder.Eventf(watch, corev1.EventTypeNormal, EventReasonRolloutCreated, "Created rollout %s for tag %s", name, tag)
+		watch.Status.LatestTag = tag
+		watch.Status.LastRollout = name
+	}
+	watch.Status.ObservedGeneration = watch.Generation
+	return ctrl.Result{RequeueAfter: interval}, r.Status().Update(ctx, watch)
+}
+
+// compileFilter checks the filter of a watch and compiles its regex, if any
+func compileFilter(filter pulseprov1alpha1.TagFilter) (*regexp.Regexp, error) {
+	switch {
+	case filter.Semver != "" && filter.Regex != "":
+		return nil, fmt.Errorf("filter must set either semver or regex, not both")
+	case filter.Semver != "":
+		return nil, versions.Validate(filter.Semver)
+	case filter.Regex != "":
+		pattern, err := regexp.Compile(filter.Regex)
+		if err != nil {
+			return nil, fmt.Errorf("invalid filter regex: %v", err)
+		}
+		return pattern, nil
+	}
+	return nil, nil
+}
+
+// newestTag lists the tags of the watch's repository and returns the newest one passing its filter, or ""
+func (r *PulseProImageWatchReconciler) newestTag(ctx context.Context, watch *pulseprov1alpha1.PulseProImageWatch, pattern *regexp.Regexp) (string, error) {
+	filter := watch.Spec.Filter
+	keychain, err := readRegistryKeychain(ctx, r.Client, watch.Namespace, watch.Spec.RegistryCredentials)
+	if err != nil {
+		return "", err
+	}
+	creds, err := registry.Chain{keychain, r.RegistryProviders}.Credentials(ctx, registry.Host(watch.Spec.Repository))
+	if err != nil {
+		return "", err
+	}
+
+	httpClient := r.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	timeout := r.Timeout
+	if timeout <= 0 {
+		timeout = defaultImageWatchTimeout
+	}
+	pollCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	tags, err := registry.Tags(pollCtx, httpClient, watch.Spec.Repository, creds)
+	if err != nil {
+		return "", err
+	}
+
+	switch {
+	case filter.Semver != "":
+		tag, err := versions.Latest(filter.Semver, tags)
+		if _, noMatch := err.(*versions.NoMatchError); noMatch {
+			return "", nil
+		}
+		return tag, err
+	case pattern != nil:
+		var matching []string
+		for _, tag := range tags {
+			if pattern.MatchString(tag) {
+				matching = append(matching, tag)
+			}
+		}
+		return versions.Newest(matching), nil
+	default:
+		return versions.Newest(tags), nil
+	}
+}
+
+// createRollout creates the rollout of tag from the watch's template. A rollout the watch already created
+// for the tag is reused, so that a lost status update does not roll the tag out twice.
+func (r *PulseProImageWatchReconciler) createRollout(ctx context.Context, watch *pulseprov1alpha1.PulseProImageWatch, tag string) (string, error) {
+	template := watch.Spec.Rollout
+	rollout := &pulseprov1alpha1.PulseProRollout{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      rolloutName(watch.Name, tag),
+			Namespace: watch.Namespace,
+			Labels:    map[string]string{pulseprov1alpha1.ImageWatchLabel: watch.Name},
+		},
+		Spec: pulseprov1alpha1.PulseProRolloutSpec{
+			Namespace:     template.Namespace,
+			Category:      template.Category,
+			Tags:          template.Tags,
+			Environments:  template.Environments,
+			ImageVersion:  tag,
+			ApprovalGates: template.ApprovalGates,
+			Analysis:      template.Analysis,
+		},
+	}
+	if err := controllerutil.SetControllerReference(watch, rollout, r.Scheme); err != nil {
+		return "", fmt.Errorf("failed to set owner of rollout %s: %v", rollout.Name, err)
+	}
+
+	err := r.Create(ctx, rollout)
+	if errors.IsAlreadyExists(err) {
+		existing := &pulseprov1alpha1.PulseProRollout{}
+		if err := r.Get(ctx, types.NamespacedName{Name: rollout.Name, Namespace: rollout.Namespace}, existing); err != nil {
+			return "", err
+		}
+		if !metav1.IsControlledBy(existing, watch) || existing.Spec.ImageVersion != tag {
+			return "", fmt.Errorf("rollout %s already exists and was not created by the watch for tag %s", rollout.Name, tag)
+		}
+		return existing.Name, nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to create rollout %s: %v", rollout.Name, err)
+	}
+	return rollout.Name, nil
+}
+
+// rolloutName names the rollout of a tag after the watch and the tag, e.g. "sandbox-2.4.3"
+func rolloutName(watch, tag string) string {
+	name := strings.Trim(strings.ToLower(watch+"-"+strings.ReplaceAll(tag, "_", "-")), "-.")
+	if len(name) > validation.DNS1123SubdomainMaxLength {
+		sum := sha256.Sum256([]byte(tag))
+		name = strings.Trim(name[:validation.DNS1123SubdomainMaxLength-9], "-.") + "-" + hex.EncodeToString(sum[:])[:8]
+	}
+	return name
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *PulseProImageWatchReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	// Polls are scheduled by RequeueAfter; the watch's own status updates must not trigger another poll
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&pulseprov1alpha1.PulseProImageWatch{}, builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		Complete(r)
+}
@@ -0,0 +1,156 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	pulseprov1alpha1 "github.com/smarter-contracts/pulsepro-operator/api/v1alpha1"
+)
+
+var _ = Describe("PulseProImageWatch Controller", func() {
+	Context("When polling a local registry", func() {
+		const watchName = "sandbox"
+
+		ctx := context.Background()
+		var (
+			tags       []string
+			failing    bool
+			watch      *pulseprov1alpha1.PulseProImageWatch
+			reconciler *PulseProImageWatchReconciler
+		)
+
+		// reconcileWatch polls the registry and returns the updated watch
+		reconcileWatch := func() *pulseprov1alpha1.PulseProImageWatch {
+			_, err := reconciler.Reconcile(ctx, reconcile.Request{NamespacedName: types.NamespacedName{Name: watchName, Namespace: "default"}})
+			Expect(err).NotTo(HaveOccurred())
+			updated := &pulseprov1alpha1.PulseProImageWatch{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: watchName, Namespace: "default"}, updated)).To(Succeed())
+			return updated
+		}
+
+		rolloutVersions := func() []string {
+			rollouts := &pulseprov1alpha1.PulseProRolloutList{}
+			Expect(k8sClient.List(ctx, rollouts, client.InNamespace("default"), client.MatchingLabels{pulseprov1alpha1.ImageWatchLabel: watchName})).To(Succeed())
+			var found []string
+			for _, rollout := range rollouts.Items {
+				found = append(found, rollout.Spec.ImageVersion)
+			}
+			return found
+		}
+
+		BeforeEach(func() {
+			tags = []string{"2.3.0", "2.4.0", "nightly"}
+			failing = false
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if failing {
+					w.WriteHeader(http.StatusInternalServerError)
+					return
+				}
+				if r.URL.Path != "/v2/pulsepro/pulse-pro/tags/list" {
+					w.WriteHeader(http.StatusNotFound)
+					return
+				}
+				Expect(json.NewEncoder(w).Encode(map[string][]string{"tags": tags})).To(Succeed())
+			}))
+			DeferCleanup(server.Close)
+
+			watch = &pulseprov1alpha1.PulseProImageWatch{
+				ObjectMeta: metav1.ObjectMeta{Name: watchName, Namespace: "default"},
+				Spec: pulseprov1alpha1.PulseProImageWatchSpec{
+					Repository: strings.TrimPrefix(server.URL, "http://") + "/pulsepro/pulse-pro",
+					Filter:     pulseprov1alpha1.TagFilter{Semver: ">=2.4"},
+					Rollout:    pulseprov1alpha1.ImageWatchRolloutTemplate{Namespace: "default", Category: "sandbox"},
+				},
+			}
+			Expect(k8sClient.Create(ctx, watch)).To(Succeed())
+			reconciler = &PulseProImageWatchReconciler{Client: k8sClient, Scheme: k8sClient.Scheme(), Recorder: record.NewFakeRecorder(10)}
+		})
+
+		AfterEach(func() {
+			Expect(k8sClient.Delete(ctx, watch)).To(Succeed())
+			Expect(k8sClient.DeleteAllOf(ctx, &pulseprov1alpha1.PulseProRollout{}, client.InNamespace("default"),
+				client.MatchingLabels{pulseprov1alpha1.ImageWatchLabel: watchName})).To(Succeed())
+		})
+
+		It("should create a rollout for each newer matching tag", func() {
+			updated := reconcileWatch()
+			Expect(updated.Status.LatestTag).To(Equal("2.4.0"))
+			Expect(updated.Status.LastRollout).To(Equal("sandbox-2.4.0"))
+			Expect(meta.IsStatusConditionTrue(updated.Status.Conditions, pulseprov1alpha1.ConditionTagsListed)).To(BeTrue())
+
+			rollout := &pulseprov1alpha1.PulseProRollout{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: "sandbox-2.4.0", Namespace: "default"}, rollout)).To(Succeed())
+			Expect(rollout.Spec.Category).To(Equal("sandbox"))
+			Expect(metav1.IsControlledBy(rollout, updated)).To(BeTrue())
+
+			// Polling again without a new tag creates nothing
+			reconcileWatch()
+			Expect(rolloutVersions()).To(ConsistOf("2.4.0"))
+
+			tags = append(tags, "2.4.1", "2.5.0-rc.1")
+			updated = reconcileWatch()
+			Expect(updated.Status.LatestTag).To(Equal("2.4.1"))
+			Expect(rolloutVersions()).To(ConsistOf("2.4.0", "2.4.1"))
+		})
+
+		It("should select tags by regex", func() {
+			watch.Spec.Filter = pulseprov1alpha1.TagFilter{Regex: "^2\\.3\\."}
+			Expect(k8sClient.Update(ctx, watch)).To(Succeed())
+
+			Expect(reconcileWatch().Status.LatestTag).To(Equal("2.3.0"))
+			Expect(rolloutVersions()).To(ConsistOf("2.3.0"))
+		})
+
+		It("should roll out the tag of a changed filter once a failed poll succeeds", func() {
+			Expect(reconcileWatch().Status.LatestTag).To(Equal("2.4.0"))
+
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: watchName, Namespace: "default"}, watch)).To(Succeed())
+			watch.Spec.Filter = pulseprov1alpha1.TagFilter{Regex: "^2\\.3\\."}
+			Expect(k8sClient.Update(ctx, watch)).To(Succeed())
+
+			failing = true
+			updated := reconcileWatch()
+			condition := meta.FindStatusCondition(updated.Status.Conditions, pulseprov1alpha1.ConditionTagsListed)
+			Expect(condition.Reason).To(Equal(pulseprov1alpha1.ReasonRegistryUnavailable))
+			Expect(updated.Status.ObservedGeneration).NotTo(Equal(updated.Generation))
+
+			// The older tag is selected by the new filter, not by a newer version
+			failing = false
+			updated = reconcileWatch()
+			Expect(updated.Status.LatestTag).To(Equal("2.3.0"))
+			Expect(updated.Status.ObservedGeneration).To(Equal(updated.Generation))
+			Expect(rolloutVersions()).To(ConsistOf("2.4.0", "2.3.0"))
+		})
+
+		It("should report invalid filters without polling", func() {
+			watch.Spec.Filter = pulseprov1alpha1.TagFilter{Semver: "~2.4", Regex: ".*"}
+			Expect(k8sClient.Update(ctx, watch)).To(Succeed())
+
+			updated := reconcileWatch()
+			condition := meta.FindStatusCondition(updated.Status.Conditions, pulseprov1alpha1.ConditionTagsListed)
+			Expect(condition.Reason).To(Equal(pulseprov1alpha1.ReasonInvalidFilter))
+			Expect(updated.Status.LastPollTime).To(BeNil())
+			Expect(rolloutVersions()).To(BeEmpty())
+		})
+	})
+})
+
+var _ = Describe("rolloutName", func() {
+	It("should name rollouts after the watch and a DNS-safe tag", func() {
+		Expect(rolloutName("sandbox", "2.4.1_build.7")).To(Equal("sandbox-2.4.1-build.7"))
+		Expect(rolloutName("sandbox", "Nightly")).To(Equal("sandbox-nightly"))
+		Expect(len(rolloutName(strings.Repeat("a", 250), "2.4.1"))).To(BeNumerically("<=", 253))
+	})
+})
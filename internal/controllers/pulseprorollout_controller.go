@@ -2,22 +2,58 @@ package controllers
 
 import (
 	"context"
+	"fmt"
+	"net/http"
+	"time"
 
 	pulseprov1alpha1 "github.com/smarter-contracts/pulsepro-operator/api/v1alpha1"
+	"github.com/smarter-contracts/pulsepro-operator/internal/analysis"
+	"github.com/smarter-contracts/pulsepro-operator/internal/metrics"
+	"github.com/smarter-contracts/pulsepro-operator/internal/schedule"
 	"github.com/smarter-contracts/pulsepro-operator/internal/utils"
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 )
 
 // PulseProRolloutReconciler reconciles a PulseProRollout object
 type PulseProRolloutReconciler struct {
 	client.Client
-	Scheme *runtime.Scheme
+	Scheme   *runtime.Scheme
+	Recorder record.EventRecorder
+
+	// PrometheusURL is the default Prometheus server queried by rollout analyses
+	PrometheusURL string
+
+	// ApprovalsVerified reports that the webhooks stamping and authorizing the approvers of gates are enabled.
+	// Without them anyone who may edit a rollout or create a PulseProApproval could approve a gate as anybody,
+	// so approvals are ignored.
+	ApprovalsVerified bool
+
+	// AnalysisTimeout bounds each Prometheus query and HTTP check of rollout analyses; defaults to 30 seconds
+	AnalysisTimeout time.Duration
+
+	// MaxConcurrentReconciles is the number of rollouts reconciled in parallel; defaults to 1
+	MaxConcurrentReconciles int
 }
 
+// +kubebuilder:rbac:groups=pulsepro.pulsepro.io,resources=pulseprorollouts,verbs=get;list;watch;update;patch
+// +kubebuilder:rbac:groups=pulsepro.pulsepro.io,resources=pulseprorollouts/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=pulsepro.pulsepro.io,resources=pulseproapprovals,verbs=get;list;watch
+// +kubebuilder:rbac:groups=pulsepro.pulsepro.io,resources=pulseproanalysistemplates,verbs=get;list;watch
+// +kubebuilder:rbac:groups=pulsepro.pulsepro.io,resources=pulseprodeployments,verbs=get;list;watch;update;patch
+// +kubebuilder:rbac:groups=pulsepro.pulsepro.io,resources=pulseprofreezecalendars,verbs=get;list;watch
+// +kubebuilder:rbac:groups=core,resources=events,verbs=create;patch
+
 // Reconcile is part of the main Kubernetes reconciliation loop which aims to
 // move the current state of the cluster closer to the desired state.
 func (r *PulseProRolloutReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
@@ -28,11 +64,93 @@ func (r *PulseProRolloutReconciler) Reconcile(ctx context.Context, req ctrl.Requ
 	if err := r.Get(ctx, req.NamespacedName, rollout); err != nil {
 		if errors.IsNotFound(err) {
 			// Object not found, return without requeue
+			metrics.ForgetRollout(req.Namespace, req.Name)
 			return ctrl.Result{}, nil
 		}
 		// Error reading the object, requeue the request
 		return ctrl.Result{}, err
 	}
+	previousPhase := rollout.Status.Phase
+	defer func() {
+		metrics.RecordRollout(rollout)
+		r.recordPhaseChange(rollout, previousPhase)
+	}()
+
+	// An aborted rollout is finished. A failed rollout has reverted its deployments after a failed analysis,
+	// but keeps them when it missed its deadline, until it is aborted.
+	if rollout.Status.Phase == pulseprov1alpha1.RolloutPhaseAborted ||
+		(rollout.Status.Phase == pulseprov1alpha1.RolloutPhaseFailed && !(rollout.Spec.Abort && hasUnrevertedTargets(rollout))) {
+		return ctrl.Result{}, nil
+	}
+
+	if rollout.Spec.Abort {
+		l.Info("Aborting rollout", "updatedTargets", len(rollout.Status.UpdatedTargets))
+		if err := r.abortRollout(ctx, rollout, pulseprov1alpha1.RolloutPhaseAborted, "Aborted by spec.abort"); err != nil {
+			l.Error(err, "Failed to abort rollout")
+			return ctrl.Result{}, err
+		}
+		return ctrl.Result{}, nil
+	}
+
+	// A dry run only reports which deployments the rollout would update
+	if rollout.Spec.DryRun {
+		return ctrl.Result{}, r.dryRun(ctx, rollout)
+	}
+
+	// A rollout that missed its deadline fails; the deployments it already updated are kept
+	now := time.Now()
+	if rollout.Spec.NotAfter != nil && !now.Before(rollout.Spec.NotAfter.Time) && rollout.Status.Phase != pulseprov1alpha1.RolloutPhaseCompleted {
+		l.Info("Rollout deadline passed", "notAfter", rollout.Spec.NotAfter)
+		rollout.Status.Phase = pulseprov1alpha1.RolloutPhaseFailed
+		rollout.Status.Message = fmt.Sprintf("Deadline %s passed before all deployments were updated", rollout.Spec.NotAfter.UTC().Format(time.RFC3339))
+		if err := r.Status().Update(ctx, rollout); err != nil {
+			l.Error(err, "Failed to update rollout status")
+			return ctrl.Result{}, err
+		}
+		return ctrl.Result{}, nil
+	}
+
+	// A scheduled rollout waits and is requeued for the moment it may start
+	if rollout.Spec.StartAt != nil && now.Before(rollout.Spec.StartAt.Time) {
+		l.Info("Rollout is scheduled", "startAt", rollout.Spec.StartAt)
+		rollout.Status.Phase = pulseprov1alpha1.RolloutPhaseScheduled
+		rollout.Status.Message = fmt.Sprintf("Scheduled to start at %s", rollout.Spec.StartAt.UTC().Format(time.RFC3339))
+		if err := r.Status().Update(ctx, rollout); err != nil {
+			l.Error(err, "Failed to update rollout status")
+			return ctrl.Result{}, err
+		}
+		return ctrl.Result{RequeueAfter: untilDeadline(rollout, now, rollout.Spec.StartAt.Sub(now))}, nil
+	}
+
+	// A paused rollout keeps the deployments it already updated but does not touch new ones.
+	// It still fails at its deadline.
+	if rollout.Spec.Paused {
+		l.Info("Rollout is paused")
+		rollout.Status.Phase = pulseprov1alpha1.RolloutPhasePaused
+		if err := r.Status().Update(ctx, rollout); err != nil {
+			l.Error(err, "Failed to update rollout status")
+			return ctrl.Result{}, err
+		}
+		return ctrl.Result{RequeueAfter: untilDeadline(rollout, now, 0)}, nil
+	}
+
+	// Record approvals given since the last reconcile before deciding which gates still block
+	if err := r.collectApprovals(ctx, rollout); err != nil {
+		l.Error(err, "Failed to collect approvals")
+		return ctrl.Result{}, err
+	}
+
+	// With analysis configured, the deployment updated last must pass its analysis before the next one is touched
+	if rollout.Spec.Analysis != nil {
+		result, done, err := r.analyzeInFlightTarget(ctx, rollout)
+		if err != nil {
+			l.Error(err, "Failed to analyse rollout")
+			return ctrl.Result{}, err
+		}
+		if done {
+			return result, nil
+		}
+	}
 
 	// List all PulseProDeployment resources in the target namespace
 	var pulseProDeployments pulseprov1alpha1.PulseProDeploymentList
@@ -42,38 +160,435 @@ func (r *PulseProRolloutReconciler) Reconcile(ctx context.Context, req ctrl.Requ
 		return ctrl.Result{}, err
 	}
 
+	// Maintenance windows and change freezes decide which deployments may be updated right now
+	freezes, err := listFreezePeriods(ctx, r.Client)
+	if err != nil {
+		l.Error(err, "Failed to list freeze calendars")
+		return ctrl.Result{}, err
+	}
+
 	// Loop through the deployments and apply updates
+	pendingGates := []string{}
+	deferredTargets := []pulseprov1alpha1.DeferredTarget{}
+	var nextWindow time.Time
+	invalidWindows := 0
+	analysing := false
 	for _, deployment := range pulseProDeployments.Items {
 		// Check if the deployment matches the rollout's tags and category using utility functions
-		if utils.MatchesTags(deployment.Spec.Tags, rollout.Spec.Tags) && utils.MatchesCategory(deployment.Spec.Category, rollout.Spec.Category) {
-			// Update the deployment with the new image version
-			if deployment.Spec.PulseProVersion != rollout.Spec.ImageVersion {
-				l.Info("Updating deployment", "deployment", deployment.Name, "namespace", deployment.Namespace, "newVersion", rollout.Spec.ImageVersion)
-				deployment.Spec.PulseProVersion = rollout.Spec.ImageVersion
-				if err := r.Update(ctx, &deployment); err != nil {
-					l.Error(err, "Failed to update PulseProDeployment", "deployment", deployment.Name, "namespace", deployment.Namespace)
-					continue
-				}
-				l.Info("Successfully updated deployment", "deployment", deployment.Name)
-			} else {
-				l.Info("Deployment already at target version", "deployment", deployment.Name, "version", rollout.Spec.ImageVersion)
+		if !matchesRollout(rollout, &deployment) {
+			continue
+		}
+
+		if deployment.Spec.PulseProVersion == rollout.Spec.ImageVersion {
+			l.Info("Deployment already at target version", "deployment", deployment.Name, "version", rollout.Spec.ImageVersion)
+			continue
+		}
+
+		// Hold back deployments whose category is guarded by a gate that has not been approved yet
+		if gate := rollout.Spec.GateFor(deployment.Spec.Category); gate != nil && rollout.Status.ApprovalFor(gate.Name) == nil {
+			l.Info("Deployment is waiting for approval", "deployment", deployment.Name, "gate", gate.Name)
+			pendingGates = appendUnique(pendingGates, gate.Name)
+			continue
+		}
+
+		// Queue deployments that are outside their maintenance windows or frozen
+		decision, err := schedule.ReleaseDecision(deployment.Spec.MaintenanceWindows, freezes, deployment.Spec.Category, now)
+		if err != nil {
+			// The deployment is held back until its windows are fixed, so the rollout must not complete without it
+			l.Error(err, "Failed to evaluate maintenance windows", "deployment", deployment.Name)
+			deferredTargets = append(deferredTargets, pulseprov1alpha1.DeferredTarget{
+				Name:    deployment.Name,
+				Reason:  pulseprov1alpha1.ReasonInvalidMaintenanceWindow,
+				Message: err.Error(),
+			})
+			invalidWindows++
+			continue
+		}
+		if !decision.Allowed {
+			l.Info("Deployment is not open for releases", "deployment", deployment.Name, "reason", decision.Reason)
+			deferredTargets = append(deferredTargets, pulseprov1alpha1.DeferredTarget{
+				Name:    deployment.Name,
+				Reason:  decision.Reason,
+				Message: decision.Message,
+			})
+			if nextWindow.IsZero() || decision.NextAttempt.Before(nextWindow) {
+				nextWindow = decision.NextAttempt
 			}
+			continue
 		}
+
+		// Only one deployment at a time is updated and analysed
+		if analysing {
+			continue
+		}
+
+		// Update the deployment with the new image version
+		l.Info("Updating deployment", "deployment", deployment.Name, "namespace", deployment.Namespace, "newVersion", rollout.Spec.ImageVersion)
+		if err := r.updateTarget(ctx, rollout, &deployment); err != nil {
+			l.Error(err, "Failed to update PulseProDeployment", "deployment", deployment.Name, "namespace", deployment.Namespace)
+			r.Recorder.Eventf(rollout, corev1.EventTypeWarning, EventReasonTargetUpdateFailed, "Failed to update %s to %s: %v", deployment.Name, rollout.Spec.ImageVersion, err)
+			continue
+		}
+		l.Info("Successfully updated deployment", "deployment", deployment.Name)
+		r.Recorder.Eventf(rollout, corev1.EventTypeNormal, EventReasonTargetUpdated, "Updated %s from %s to %s", deployment.Name, rollout.Status.TargetNamed(deployment.Name).PreviousVersion, rollout.Spec.ImageVersion)
+		analysing = rollout.Spec.Analysis != nil
 	}
 
-	// Update the status of the rollout (optional)
-	rollout.Status.Phase = "Completed"
+	// Update the status of the rollout
+	rollout.Status.PendingGates = pendingGates
+	rollout.Status.DeferredTargets = deferredTargets
+	rollout.Status.DryRunTargets = nil
+	result := ctrl.Result{}
+	if len(deferredTargets) > 0 {
+		result.RequeueAfter = requeueUntil(nextWindow, time.Hour)
+	}
+	switch {
+	case analysing:
+		rollout.Status.Phase = pulseprov1alpha1.RolloutPhaseProgressing
+		result.RequeueAfter = analysisInterval(rollout.Spec.Analysis)
+	case len(pendingGates) > 0:
+		rollout.Status.Phase = pulseprov1alpha1.RolloutPhaseAwaitingApproval
+		rollout.Status.Message = ""
+	case len(deferredTargets) > 0:
+		rollout.Status.Phase = pulseprov1alpha1.RolloutPhaseWaitingForWindow
+		rollout.Status.Message = fmt.Sprintf("%d deployment(s) are waiting for a maintenance window or the end of a change freeze", len(deferredTargets)-invalidWindows)
+		if invalidWindows > 0 {
+			rollout.Status.Message += fmt.Sprintf(", %d have invalid maintenance windows", invalidWindows)
+		}
+	default:
+		rollout.Status.Phase = pulseprov1alpha1.RolloutPhaseCompleted
+		rollout.Status.Message = ""
+	}
 	if err := r.Status().Update(ctx, rollout); err != nil {
 		l.Error(err, "Failed to update rollout status")
 		return ctrl.Result{}, err
 	}
 
-	return ctrl.Result{}, nil
+	// Come back at the deadline if the rollout is still unfinished by then
+	if rollout.Status.Phase != pulseprov1alpha1.RolloutPhaseCompleted {
+		result.RequeueAfter = untilDeadline(rollout, now, result.RequeueAfter)
+	}
+
+	return result, nil
+}
+
+// untilDeadline caps a requeue delay at the rollout's deadline; a zero delay means no requeue otherwise
+func untilDeadline(rollout *pulseprov1alpha1.PulseProRollout, now time.Time, requeueAfter time.Duration) time.Duration {
+	if rollout.Spec.NotAfter == nil {
+		return requeueAfter
+	}
+	if deadline := rollout.Spec.NotAfter.Sub(now); requeueAfter == 0 || deadline < requeueAfter {
+		return deadline
+	}
+	return requeueAfter
+}
+
+// hasUnrevertedTargets reports whether the rollout updated deployments that an abort has not reverted yet
+func hasUnrevertedTargets(rollout *pulseprov1alpha1.PulseProRollout) bool {
+	for _, target := range rollout.Status.UpdatedTargets {
+		if !target.Reverted {
+			return true
+		}
+	}
+	return false
+}
+
+// dryRun records the deployments matched by the rollout and their version changes in status without updating them
+func (r *PulseProRolloutReconciler) dryRun(ctx context.Context, rollout *pulseprov1alpha1.PulseProRollout) error {
+	var pulseProDeployments pulseprov1alpha1.PulseProDeploymentList
+	if err := r.List(ctx, &pulseProDeployments, client.InNamespace(rollout.Spec.Namespace)); err != nil {
+		return fmt.Errorf("failed to list PulseProDeployments: %v", err)
+	}
+
+	targets := []pulseprov1alpha1.DryRunTarget{}
+	changes := 0
+	for _, deployment := range pulseProDeployments.Items {
+		if !matchesRollout(rollout, &deployment) {
+			continue
+		}
+		target := pulseprov1alpha1.DryRunTarget{
+			Name:           deployment.Name,
+			Category:       deployment.Spec.Category,
+			CurrentVersion: deployment.Spec.PulseProVersion,
+			TargetVersion:  rollout.Spec.ImageVersion,
+		}
+		if gate := rollout.Spec.GateFor(deployment.Spec.Category); gate != nil {
+			target.Gate = gate.Name
+		}
+		if target.CurrentVersion != target.TargetVersion {
+			changes++
+		}
+		targets = append(targets, target)
+	}
+
+	log.FromContext(ctx).Info("Dry run", "matched", len(targets), "changes", changes)
+	rollout.Status.Phase = pulseprov1alpha1.RolloutPhaseDryRun
+	rollout.Status.Message = fmt.Sprintf("%d deployment(s) matched, %d would be updated to %s", len(targets), changes, rollout.Spec.ImageVersion)
+	rollout.Status.DryRunTargets = targets
+	return r.Status().Update(ctx, rollout)
+}
+
+// matchesRollout checks if the deployment is selected by the rollout's tags, category and environments
+func matchesRollout(rollout *pulseprov1alpha1.PulseProRollout, deployment *pulseprov1alpha1.PulseProDeployment) bool {
+	return utils.MatchesTags(deployment.Spec.Tags, rollout.Spec.Tags) &&
+		utils.MatchesCategory(deployment.Spec.Category, rollout.Spec.Category) &&
+		utils.MatchesEnvironment(deployment.Spec.EnvironmentName, rollout.Spec.Environments)
+}
+
+// analyzeInFlightTarget runs the analysis of the deployment currently being rolled out, once it has synced.
+// It reports done when the reconcile should stop here, either to wait for the next run or because the
+// rollout failed; otherwise the rollout may move on to the next deployment.
+func (r *PulseProRolloutReconciler) analyzeInFlightTarget(ctx context.Context, rollout *pulseprov1alpha1.PulseProRollout) (ctrl.Result, bool, error) {
+	l := log.FromContext(ctx)
+
+	var target *pulseprov1alpha1.RolloutTarget
+	for i := range rollout.Status.UpdatedTargets {
+		if rollout.Status.UpdatedTargets[i].Analysis == pulseprov1alpha1.AnalysisRunning {
+			target = &rollout.Status.UpdatedTargets[i]
+			break
+		}
+	}
+	if target == nil {
+		return ctrl.Result{}, false, nil
+	}
+
+	spec := rollout.Spec.Analysis
+	interval := analysisInterval(spec)
+	rollout.Status.Phase = pulseprov1alpha1.RolloutPhaseProgressing
+
+	deployment := &pulseprov1alpha1.PulseProDeployment{}
+	if err := r.Get(ctx, types.NamespacedName{Name: target.Name, Namespace: rollout.Spec.Namespace}, deployment); err != nil {
+		if !errors.IsNotFound(err) {
+			return ctrl.Result{}, false, err
+		}
+		target.Analysis = pulseprov1alpha1.AnalysisFailed
+		return ctrl.Result{}, true, r.abortRollout(ctx, rollout, pulseprov1alpha1.RolloutPhaseFailed,
+			fmt.Sprintf("Deployment %s was deleted during analysis", target.Name))
+	}
+
+	// Checks only make sense against the new version, so wait for the deployment to sync it
+	if deployment.Status.Status != "Synced" || deployment.Status.CurrentVersion != rollout.Spec.ImageVersion {
+		rollout.Status.Message = fmt.Sprintf("Waiting for %s to sync version %s", deployment.Name, rollout.Spec.ImageVersion)
+		return ctrl.Result{RequeueAfter: interval}, true, r.Status().Update(ctx, rollout)
+	}
+
+	// A missing template fails the run like a failing check, so that the rollout does not wait for it forever
+	metrics, err := r.analysisMetrics(ctx, rollout)
+	if err != nil && !errors.IsNotFound(err) {
+		return ctrl.Result{}, false, err
+	}
+	if err == nil {
+		runner := &analysis.Runner{PrometheusURL: r.PrometheusURL}
+		if r.AnalysisTimeout > 0 {
+			runner.HTTPClient = &http.Client{Timeout: r.AnalysisTimeout}
+		}
+		err = runner.Run(ctx, metrics, analysis.TargetFor(deployment))
+	}
+	if err != nil {
+		target.FailedRuns++
+		rollout.Status.Message = err.Error()
+		l.Info("Analysis run failed", "deployment", deployment.Name, "failedRuns", target.FailedRuns, "reason", err.Error())
+		r.Recorder.Eventf(rollout, corev1.EventTypeWarning, EventReasonAnalysisRunFailed, "Analysis of %s failed (%d/%d): %v", deployment.Name, target.FailedRuns, max(spec.FailureLimit, 1), err)
+
+		if target.FailedRuns >= max(spec.FailureLimit, 1) {
+			target.Analysis = pulseprov1alpha1.AnalysisFailed
+			return ctrl.Result{}, true, r.abortRollout(ctx, rollout, pulseprov1alpha1.RolloutPhaseFailed, err.Error())
+		}
+		return ctrl.Result{RequeueAfter: interval}, true, r.Status().Update(ctx, rollout)
+	}
+
+	target.SuccessfulRuns++
+	l.Info("Analysis run passed", "deployment", deployment.Name, "successfulRuns", target.SuccessfulRuns)
+	if target.SuccessfulRuns < max(spec.SuccessfulRuns, 1) {
+		rollout.Status.Message = fmt.Sprintf("Analysis of %s passed %d time(s)", deployment.Name, target.SuccessfulRuns)
+		return ctrl.Result{RequeueAfter: interval}, true, r.Status().Update(ctx, rollout)
+	}
+
+	target.Analysis = pulseprov1alpha1.AnalysisSuccessful
+	r.Recorder.Eventf(rollout, corev1.EventTypeNormal, EventReasonAnalysisPassed, "Analysis of %s passed %d time(s)", deployment.Name, target.SuccessfulRuns)
+	rollout.Status.Message = ""
+	return ctrl.Result{}, false, r.Status().Update(ctx, rollout)
+}
+
+// analysisMetrics returns the metrics of the rollout's analysis followed by those of its templates
+func (r *PulseProRolloutReconciler) analysisMetrics(ctx context.Context, rollout *pulseprov1alpha1.PulseProRollout) ([]pulseprov1alpha1.AnalysisMetric, error) {
+	metrics := append([]pulseprov1alpha1.AnalysisMetric{}, rollout.Spec.Analysis.Metrics...)
+	for _, ref := range rollout.Spec.Analysis.Templates {
+		template := &pulseprov1alpha1.PulseProAnalysisTemplate{}
+		if err := r.Get(ctx, types.NamespacedName{Name: ref.Name, Namespace: rollout.Namespace}, template); err != nil {
+			return nil, fmt.Errorf("failed to get analysis template %s: %w", ref.Name, err)
+		}
+		metrics = append(metrics, template.Spec.Metrics...)
+	}
+	return metrics, nil
+}
+
+// analysisInterval returns the time between analysis runs, one minute unless configured otherwise
+func analysisInterval(spec *pulseprov1alpha1.RolloutAnalysis) time.Duration {
+	interval, err := time.ParseDuration(spec.Interval)
+	if err != nil || interval <= 0 {
+		return time.Minute
+	}
+	return interval
+}
+
+// updateTarget moves a deployment to the rollout's version and records its previous version in status,
+// so that an abort can put it back.
+func (r *PulseProRolloutReconciler) updateTarget(ctx context.Context, rollout *pulseprov1alpha1.PulseProRollout, deployment *pulseprov1alpha1.PulseProDeployment) error {
+	previousVersion := deployment.Spec.PulseProVersion
+	deployment.Spec.PulseProVersion = rollout.Spec.ImageVersion
+	if err := r.Update(ctx, deployment); err != nil {
+		return err
+	}
+
+	target := rollout.Status.TargetNamed(deployment.Name)
+	if target == nil {
+		rollout.Status.UpdatedTargets = append(rollout.Status.UpdatedTargets, pulseprov1alpha1.RolloutTarget{
+			Name:            deployment.Name,
+			PreviousVersion: previousVersion,
+		})
+		target = &rollout.Status.UpdatedTargets[len(rollout.Status.UpdatedTargets)-1]
+	}
+	target.Reverted = false
+	if rollout.Spec.Analysis != nil {
+		target.Analysis = pulseprov1alpha1.AnalysisRunning
+		target.SuccessfulRuns = 0
+		target.FailedRuns = 0
+	}
+
+	// Persist the record right away so it survives a failure later in the reconcile
+	return r.Status().Update(ctx, rollout)
+}
+
+// abortRollout reverts every deployment this rollout updated back to its previous version and
+// moves the rollout to the given terminal phase. Deployments that have since been moved to another
+// version are left alone.
+func (r *PulseProRolloutReconciler) abortRollout(ctx context.Context, rollout *pulseprov1alpha1.PulseProRollout, phase, message string) error {
+	l := log.FromContext(ctx)
+
+	for i := range rollout.Status.UpdatedTargets {
+		target := &rollout.Status.UpdatedTargets[i]
+		if target.Reverted {
+			continue
+		}
+
+		deployment := &pulseprov1alpha1.PulseProDeployment{}
+		if err := r.Get(ctx, types.NamespacedName{Name: target.Name, Namespace: rollout.Spec.Namespace}, deployment); err != nil {
+			if errors.IsNotFound(err) {
+				l.Info("Deployment to revert no longer exists", "deployment", target.Name)
+				continue
+			}
+			return err
+		}
+
+		if deployment.Spec.PulseProVersion == rollout.Spec.ImageVersion {
+			l.Info("Reverting deployment", "deployment", deployment.Name, "version", target.PreviousVersion)
+			deployment.Spec.PulseProVersion = target.PreviousVersion
+			if err := r.Update(ctx, deployment); err != nil {
+				return err
+			}
+			r.Recorder.Eventf(rollout, corev1.EventTypeNormal, EventReasonTargetReverted, "Reverted %s from %s to %s", deployment.Name, rollout.Spec.ImageVersion, target.PreviousVersion)
+		}
+		target.Reverted = true
+	}
+
+	rollout.Status.Phase = phase
+	rollout.Status.Message = message
+	rollout.Status.PendingGates = nil
+	return r.Status().Update(ctx, rollout)
+}
+
+// collectApprovals records in status the gates approved through the approve annotation or PulseProApproval objects.
+// Approvers have already been checked against the gate's groups by the admission webhooks, so approvals are
+// ignored unless the webhooks are enabled. Approvals are only valid for the rollout and image version they were
+// given for, so recorded approvals of another version are dropped.
+func (r *PulseProRolloutReconciler) collectApprovals(ctx context.Context, rollout *pulseprov1alpha1.PulseProRollout) error {
+	approved := rollout.Status.Approvals[:0]
+	for _, approval := range rollout.Status.Approvals {
+		if approval.ImageVersion == rollout.Spec.ImageVersion {
+			approved = append(approved, approval)
+		}
+	}
+	rollout.Status.Approvals = approved
+
+	record := func(gateName, approver string) {
+		if rollout.Spec.GateNamed(gateName) == nil || rollout.Status.ApprovalFor(gateName) != nil {
+			return
+		}
+		if !r.ApprovalsVerified {
+			log.FromContext(ctx).Info("Ignoring approval of unverified approver", "gate", gateName, "approver", approver)
+			r.Recorder.Eventf(rollout, corev1.EventTypeWarning, EventReasonApprovalIgnored,
+				"Approval of gate %s is ignored because the operator runs without the webhooks that verify approvers", gateName)
+			return
+		}
+		if approver == "" {
+			return
+		}
+		rollout.Status.Approvals = append(rollout.Status.Approvals, pulseprov1alpha1.ApprovalRecord{
+			Gate:         gateName,
+			Approver:     approver,
+			ApprovedAt:   metav1.Now(),
+			ImageVersion: rollout.Spec.ImageVersion,
+		})
+		r.Recorder.Eventf(rollout, corev1.EventTypeNormal, EventReasonApproved, "Gate %s approved by %s", gateName, approver)
+	}
+
+	record(rollout.Annotations[pulseprov1alpha1.ApproveAnnotation], rollout.Annotations[pulseprov1alpha1.ApprovedByAnnotation])
+
+	var approvals pulseprov1alpha1.PulseProApprovalList
+	if err := r.List(ctx, &approvals, client.InNamespace(rollout.Namespace)); err != nil {
+		return err
+	}
+	for _, approval := range approvals.Items {
+		// Approvals left over from a deleted rollout of the same name, or given for another version, do not count
+		if approval.Spec.RolloutName == rollout.Name && approval.Spec.RolloutUID == rollout.UID &&
+			approval.Spec.ImageVersion == rollout.Spec.ImageVersion {
+			record(approval.Spec.Gate, approval.Spec.Approver)
+		}
+	}
+	return nil
+}
+
+// recordPhaseChange records an event when the reconcile moved the rollout to another phase
+func (r *PulseProRolloutReconciler) recordPhaseChange(rollout *pulseprov1alpha1.PulseProRollout, previousPhase string) {
+	phase := rollout.Status.Phase
+	if phase == "" || phase == previousPhase {
+		return
+	}
+
+	eventType := corev1.EventTypeNormal
+	if phase == pulseprov1alpha1.RolloutPhaseFailed || phase == pulseprov1alpha1.RolloutPhaseAborted {
+		eventType = corev1.EventTypeWarning
+	}
+	message := fmt.Sprintf("Rollout of %s is %s", rollout.Spec.ImageVersion, phase)
+	if rollout.Status.Message != "" {
+		message += ": " + rollout.Status.Message
+	}
+	r.Recorder.Event(rollout, eventType, "Rollout"+phase, message)
+}
+
+// appendUnique appends value to values unless it is already present
+func appendUnique(values []string, value string) []string {
+	for _, v := range values {
+		if v == value {
+			return values
+		}
+	}
+	return append(values, value)
 }
 
 // SetupWithManager sets up the controller with the Manager.
 func (r *PulseProRolloutReconciler) SetupWithManager(mgr ctrl.Manager) error {
 	return ctrl.NewControllerManagedBy(mgr).
 		For(&pulseprov1alpha1.PulseProRollout{}).
+		WithOptions(controller.Options{MaxConcurrentReconciles: max(r.MaxConcurrentReconciles, 1)}).
+		Watches(&pulseprov1alpha1.PulseProApproval{}, handler.EnqueueRequestsFromMapFunc(
+			func(ctx context.Context, obj client.Object) []reconcile.Request {
+				approval := obj.(*pulseprov1alpha1.PulseProApproval)
+				return []reconcile.Request{{NamespacedName: types.NamespacedName{
+					Name:      approval.Spec.RolloutName,
+					Namespace: approval.Namespace,
+				}}}
+			})).
 		Complete(r)
 }
@@ -0,0 +1,472 @@
+package controllers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	pulseprov1alpha1 "github.com/smarter-contracts/pulsepro-operator/api/v1alpha1"
+)
+
+var _ = Describe("PulseProRollout Controller", func() {
+	Context("When reconciling a rollout", func() {
+		const (
+			rolloutName    = "test-rollout"
+			deploymentName = "test-rollout-target"
+		)
+
+		ctx := context.Background()
+
+		rolloutKey := types.NamespacedName{Name: rolloutName, Namespace: "default"}
+		deploymentKey := types.NamespacedName{Name: deploymentName, Namespace: "default"}
+
+		var recorder *record.FakeRecorder
+
+		reconcileRollout := func() reconcile.Result {
+			controllerReconciler := &PulseProRolloutReconciler{
+				Client:   k8sClient,
+				Scheme:   k8sClient.Scheme(),
+				Recorder: recorder,
+			}
+			result, err := controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: rolloutKey})
+			Expect(err).NotTo(HaveOccurred())
+			return result
+		}
+
+		deploymentVersion := func() string {
+			deployment := &pulseprov1alpha1.PulseProDeployment{}
+			Expect(k8sClient.Get(ctx, deploymentKey, deployment)).To(Succeed())
+			return deployment.Spec.PulseProVersion
+		}
+
+		rolloutStatus := func() pulseprov1alpha1.PulseProRolloutStatus {
+			rollout := &pulseprov1alpha1.PulseProRollout{}
+			Expect(k8sClient.Get(ctx, rolloutKey, rollout)).To(Succeed())
+			return rollout.Status
+		}
+
+		updateRolloutSpec := func(mutate func(spec *pulseprov1alpha1.PulseProRolloutSpec)) {
+			rollout := &pulseprov1alpha1.PulseProRollout{}
+			Expect(k8sClient.Get(ctx, rolloutKey, rollout)).To(Succeed())
+			mutate(&rollout.Spec)
+			Expect(k8sClient.Update(ctx, rollout)).To(Succeed())
+		}
+
+		BeforeEach(func() {
+			recorder = record.NewFakeRecorder(100)
+
+			By("creating a target deployment and a rollout for it")
+			Expect(k8sClient.Create(ctx, &pulseprov1alpha1.PulseProDeployment{
+				ObjectMeta: metav1.ObjectMeta{Name: deploymentName, Namespace: "default"},
+				Spec: pulseprov1alpha1.PulseProDeploymentSpec{
+					Namespace:           "pulsepro",
+					HelmChart:           "oci://registry.example.com/charts/pulse-pro",
+					HelmChartVersion:    "1.0.0",
+					PulseProVersion:     "2.3.0",
+					HelmValuesConfigMap: pulseprov1alpha1.ConfigMapReference{Name: "values", Key: "values.yaml"},
+					Secrets:             []pulseprov1alpha1.SecretReference{},
+					ProjectName:         "acme",
+					EnvironmentName:     "staging",
+					SyncInterval:        "10m",
+					Category:            "staging",
+				},
+			})).To(Succeed())
+			Expect(k8sClient.Create(ctx, &pulseprov1alpha1.PulseProRollout{
+				ObjectMeta: metav1.ObjectMeta{Name: rolloutName, Namespace: "default"},
+				Spec: pulseprov1alpha1.PulseProRolloutSpec{
+					Namespace:    "default",
+					Category:     "staging",
+					ImageVersion: "2.4.0",
+				},
+			})).To(Succeed())
+		})
+
+		AfterEach(func() {
+			By("cleaning up the rollout and its target")
+			Expect(k8sClient.Delete(ctx, &pulseprov1alpha1.PulseProRollout{
+				ObjectMeta: metav1.ObjectMeta{Name: rolloutName, Namespace: "default"},
+			})).To(Succeed())
+			Expect(k8sClient.Delete(ctx, &pulseprov1alpha1.PulseProDeployment{
+				ObjectMeta: metav1.ObjectMeta{Name: deploymentName, Namespace: "default"},
+			})).To(Succeed())
+		})
+
+		It("should not update deployments while paused", func() {
+			updateRolloutSpec(func(spec *pulseprov1alpha1.PulseProRolloutSpec) { spec.Paused = true })
+
+			reconcileRollout()
+
+			Expect(deploymentVersion()).To(Equal("2.3.0"))
+			Expect(rolloutStatus().Phase).To(Equal(pulseprov1alpha1.RolloutPhasePaused))
+		})
+
+		It("should requeue a paused rollout at its deadline", func() {
+			notAfter := metav1.NewTime(time.Now().Add(time.Hour))
+			updateRolloutSpec(func(spec *pulseprov1alpha1.PulseProRolloutSpec) {
+				spec.Paused = true
+				spec.NotAfter = &notAfter
+			})
+
+			Expect(reconcileRollout().RequeueAfter).To(BeNumerically("~", time.Hour, time.Minute))
+			Expect(rolloutStatus().Phase).To(Equal(pulseprov1alpha1.RolloutPhasePaused))
+		})
+
+		It("should wait for a scheduled start and requeue at it", func() {
+			startAt := metav1.NewTime(time.Now().Add(2 * time.Hour))
+			updateRolloutSpec(func(spec *pulseprov1alpha1.PulseProRolloutSpec) { spec.StartAt = &startAt })
+
+			Expect(reconcileRollout().RequeueAfter).To(BeNumerically("~", 2*time.Hour, time.Minute))
+			Expect(deploymentVersion()).To(Equal("2.3.0"))
+			status := rolloutStatus()
+			Expect(status.Phase).To(Equal(pulseprov1alpha1.RolloutPhaseScheduled))
+			Expect(status.Message).To(HavePrefix("Scheduled to start at "))
+
+			// A deadline before the start cuts the wait short
+			notAfter := metav1.NewTime(time.Now().Add(time.Hour))
+			updateRolloutSpec(func(spec *pulseprov1alpha1.PulseProRolloutSpec) { spec.NotAfter = &notAfter })
+			Expect(reconcileRollout().RequeueAfter).To(BeNumerically("~", time.Hour, time.Minute))
+		})
+
+		It("should fail once its deadline passed and keep the deployments until aborted", func() {
+			notAfter := metav1.NewTime(time.Now().Add(-time.Minute))
+			updateRolloutSpec(func(spec *pulseprov1alpha1.PulseProRolloutSpec) { spec.NotAfter = &notAfter })
+
+			reconcileRollout()
+
+			Expect(deploymentVersion()).To(Equal("2.3.0"))
+			status := rolloutStatus()
+			Expect(status.Phase).To(Equal(pulseprov1alpha1.RolloutPhaseFailed))
+			Expect(status.Message).To(HavePrefix("Deadline "))
+		})
+
+		It("should revert the deployments of a failed rollout on abort", func() {
+			reconcileRollout()
+			Expect(deploymentVersion()).To(Equal("2.4.0"))
+
+			// The rollout missed its deadline after updating the deployment
+			rollout := &pulseprov1alpha1.PulseProRollout{}
+			Expect(k8sClient.Get(ctx, rolloutKey, rollout)).To(Succeed())
+			rollout.Status.Phase = pulseprov1alpha1.RolloutPhaseFailed
+			Expect(k8sClient.Status().Update(ctx, rollout)).To(Succeed())
+			reconcileRollout()
+			Expect(deploymentVersion()).To(Equal("2.4.0"))
+
+			updateRolloutSpec(func(spec *pulseprov1alpha1.PulseProRolloutSpec) { spec.Abort = true })
+			reconcileRollout()
+
+			Expect(deploymentVersion()).To(Equal("2.3.0"))
+			Expect(rolloutStatus().Phase).To(Equal(pulseprov1alpha1.RolloutPhaseAborted))
+		})
+
+		It("should only list matched deployments in a dry run", func() {
+			updateRolloutSpec(func(spec *pulseprov1alpha1.PulseProRolloutSpec) { spec.DryRun = true })
+
+			reconcileRollout()
+
+			Expect(deploymentVersion()).To(Equal("2.3.0"))
+			status := rolloutStatus()
+			Expect(status.Phase).To(Equal(pulseprov1alpha1.RolloutPhaseDryRun))
+			Expect(status.UpdatedTargets).To(BeEmpty())
+			Expect(status.DryRunTargets).To(ConsistOf(pulseprov1alpha1.DryRunTarget{
+				Name:           deploymentName,
+				Category:       "staging",
+				CurrentVersion: "2.3.0",
+				TargetVersion:  "2.4.0",
+			}))
+		})
+
+		It("should skip deployments outside the rollout's environments", func() {
+			updateRolloutSpec(func(spec *pulseprov1alpha1.PulseProRolloutSpec) { spec.Environments = []string{"production"} })
+
+			reconcileRollout()
+
+			Expect(deploymentVersion()).To(Equal("2.3.0"))
+			Expect(rolloutStatus().Phase).To(Equal(pulseprov1alpha1.RolloutPhaseCompleted))
+		})
+
+		It("should hold back deployments whose maintenance windows are invalid", func() {
+			deployment := &pulseprov1alpha1.PulseProDeployment{}
+			Expect(k8sClient.Get(ctx, deploymentKey, deployment)).To(Succeed())
+			deployment.Spec.MaintenanceWindows = []pulseprov1alpha1.MaintenanceWindow{{Schedule: "0 1 * * *", Duration: "3h", TimeZone: "Europe/Atlantis"}}
+			Expect(k8sClient.Update(ctx, deployment)).To(Succeed())
+
+			Expect(reconcileRollout().RequeueAfter).To(Equal(time.Hour))
+
+			Expect(deploymentVersion()).To(Equal("2.3.0"))
+			status := rolloutStatus()
+			Expect(status.Phase).To(Equal(pulseprov1alpha1.RolloutPhaseWaitingForWindow))
+			Expect(status.DeferredTargets).To(HaveLen(1))
+			Expect(status.DeferredTargets[0].Reason).To(Equal(pulseprov1alpha1.ReasonInvalidMaintenanceWindow))
+			Expect(status.DeferredTargets[0].Message).To(ContainSubstring("Europe/Atlantis"))
+		})
+
+		It("should record events for updated deployments and phase changes", func() {
+			reconcileRollout()
+
+			Expect(recorder.Events).To(Receive(Equal("Normal TargetUpdated Updated " + deploymentName + " from 2.3.0 to 2.4.0")))
+			Expect(recorder.Events).To(Receive(HavePrefix("Normal RolloutCompleted ")))
+		})
+
+		It("should only honour approvals when the webhooks verify approvers", func() {
+			rollout := &pulseprov1alpha1.PulseProRollout{}
+			Expect(k8sClient.Get(ctx, rolloutKey, rollout)).To(Succeed())
+			rollout.Spec.ApprovalGates = []pulseprov1alpha1.ApprovalGate{{Name: "staging", Category: "staging", ApproverGroups: []string{"release-managers"}}}
+			rollout.Annotations = map[string]string{
+				pulseprov1alpha1.ApproveAnnotation:    "staging",
+				pulseprov1alpha1.ApprovedByAnnotation: "alice",
+			}
+			Expect(k8sClient.Update(ctx, rollout)).To(Succeed())
+
+			reconcileRollout()
+
+			Expect(deploymentVersion()).To(Equal("2.3.0"))
+			Expect(rolloutStatus().Approvals).To(BeEmpty())
+			Expect(rolloutStatus().Phase).To(Equal(pulseprov1alpha1.RolloutPhaseAwaitingApproval))
+			Expect(recorder.Events).To(Receive(HavePrefix("Warning ApprovalIgnored ")))
+
+			verified := &PulseProRolloutReconciler{Client: k8sClient, Scheme: k8sClient.Scheme(), Recorder: recorder, ApprovalsVerified: true}
+			_, err := verified.Reconcile(ctx, reconcile.Request{NamespacedName: rolloutKey})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(deploymentVersion()).To(Equal("2.4.0"))
+			status := rolloutStatus()
+			Expect(status.ApprovalFor("staging")).NotTo(BeNil())
+			Expect(status.ApprovalFor("staging").Approver).To(Equal("alice"))
+		})
+
+		It("should only count approvals given for this rollout and its image version", func() {
+			rollout := &pulseprov1alpha1.PulseProRollout{}
+			Expect(k8sClient.Get(ctx, rolloutKey, rollout)).To(Succeed())
+			rollout.Spec.ApprovalGates = []pulseprov1alpha1.ApprovalGate{{Name: "staging", Category: "staging", ApproverGroups: []string{"release-managers"}}}
+			Expect(k8sClient.Update(ctx, rollout)).To(Succeed())
+			DeferCleanup(func() {
+				Expect(k8sClient.DeleteAllOf(ctx, &pulseprov1alpha1.PulseProApproval{}, client.InNamespace("default"))).To(Succeed())
+			})
+
+			approve := func(name string, uid types.UID, version string) {
+				Expect(k8sClient.Create(ctx, &pulseprov1alpha1.PulseProApproval{
+					ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+					Spec: pulseprov1alpha1.PulseProApprovalSpec{
+						RolloutName:  rolloutName,
+						Gate:         "staging",
+						Approver:     "alice",
+						RolloutUID:   uid,
+						ImageVersion: version,
+					},
+				})).To(Succeed())
+			}
+			verified := &PulseProRolloutReconciler{Client: k8sClient, Scheme: k8sClient.Scheme(), Recorder: recorder, ApprovalsVerified: true}
+			reconcileVerified := func() {
+				_, err := verified.Reconcile(ctx, reconcile.Request{NamespacedName: rolloutKey})
+				Expect(err).NotTo(HaveOccurred())
+			}
+
+			// Approvals of a deleted rollout of the same name, or of another version, do not count
+			approve("deleted-rollout", "deleted-uid", "2.4.0")
+			approve("other-version", rollout.UID, "2.3.5")
+			reconcileVerified()
+			Expect(deploymentVersion()).To(Equal("2.3.0"))
+			Expect(rolloutStatus().Approvals).To(BeEmpty())
+			Expect(rolloutStatus().Phase).To(Equal(pulseprov1alpha1.RolloutPhaseAwaitingApproval))
+
+			approve("current", rollout.UID, "2.4.0")
+			reconcileVerified()
+			Expect(deploymentVersion()).To(Equal("2.4.0"))
+			status := rolloutStatus()
+			Expect(status.ApprovalFor("staging").ImageVersion).To(Equal("2.4.0"))
+
+			// An approval does not release another version
+			updateRolloutSpec(func(spec *pulseprov1alpha1.PulseProRolloutSpec) { spec.ImageVersion = "2.5.0" })
+			reconcileVerified()
+			Expect(deploymentVersion()).To(Equal("2.4.0"))
+			Expect(rolloutStatus().Approvals).To(BeEmpty())
+			Expect(rolloutStatus().Phase).To(Equal(pulseprov1alpha1.RolloutPhaseAwaitingApproval))
+		})
+
+		It("should revert updated deployments on abort", func() {
+			reconcileRollout()
+			Expect(deploymentVersion()).To(Equal("2.4.0"))
+			Expect(rolloutStatus().UpdatedTargets).To(ConsistOf(pulseprov1alpha1.RolloutTarget{
+				Name:            deploymentName,
+				PreviousVersion: "2.3.0",
+			}))
+
+			updateRolloutSpec(func(spec *pulseprov1alpha1.PulseProRolloutSpec) { spec.Abort = true })
+			reconcileRollout()
+
+			Expect(deploymentVersion()).To(Equal("2.3.0"))
+			Expect(rolloutStatus().Phase).To(Equal(pulseprov1alpha1.RolloutPhaseAborted))
+		})
+	})
+
+	Context("When a rollout analyses its deployments", func() {
+		const rolloutName = "analysed-rollout"
+
+		ctx := context.Background()
+		rolloutKey := types.NamespacedName{Name: rolloutName, Namespace: "default"}
+		deployments := []string{"analysed-a", "analysed-b"}
+
+		var (
+			healthy  bool
+			health   *httptest.Server
+			recorder *record.FakeRecorder
+		)
+
+		reconcileRollout := func() pulseprov1alpha1.PulseProRolloutStatus {
+			reconciler := &PulseProRolloutReconciler{Client: k8sClient, Scheme: k8sClient.Scheme(), Recorder: recorder, AnalysisTimeout: time.Second}
+			_, err := reconciler.Reconcile(ctx, reconcile.Request{NamespacedName: rolloutKey})
+			Expect(err).NotTo(HaveOccurred())
+			rollout := &pulseprov1alpha1.PulseProRollout{}
+			Expect(k8sClient.Get(ctx, rolloutKey, rollout)).To(Succeed())
+			return rollout.Status
+		}
+
+		deploymentVersion := func(name string) string {
+			deployment := &pulseprov1alpha1.PulseProDeployment{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: name, Namespace: "default"}, deployment)).To(Succeed())
+			return deployment.Spec.PulseProVersion
+		}
+
+		// markSynced reports the deployment as running the version of its spec, as its controller would
+		markSynced := func(name string) {
+			deployment := &pulseprov1alpha1.PulseProDeployment{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: name, Namespace: "default"}, deployment)).To(Succeed())
+			deployment.Status.Status = "Synced"
+			deployment.Status.CurrentVersion = deployment.Spec.PulseProVersion
+			Expect(k8sClient.Status().Update(ctx, deployment)).To(Succeed())
+		}
+
+		createRollout := func(analysis *pulseprov1alpha1.RolloutAnalysis) {
+			Expect(k8sClient.Create(ctx, &pulseprov1alpha1.PulseProRollout{
+				ObjectMeta: metav1.ObjectMeta{Name: rolloutName, Namespace: "default"},
+				Spec: pulseprov1alpha1.PulseProRolloutSpec{
+					Namespace:    "default",
+					Category:     "canary",
+					ImageVersion: "2.4.0",
+					Analysis:     analysis,
+				},
+			})).To(Succeed())
+			DeferCleanup(func() {
+				Expect(k8sClient.Delete(ctx, &pulseprov1alpha1.PulseProRollout{ObjectMeta: metav1.ObjectMeta{Name: rolloutName, Namespace: "default"}})).To(Succeed())
+			})
+		}
+
+		BeforeEach(func() {
+			recorder = record.NewFakeRecorder(100)
+			healthy = true
+			health = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if !healthy {
+					w.WriteHeader(http.StatusServiceUnavailable)
+				}
+			}))
+			DeferCleanup(health.Close)
+
+			for _, name := range deployments {
+				Expect(k8sClient.Create(ctx, &pulseprov1alpha1.PulseProDeployment{
+					ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+					Spec: pulseprov1alpha1.PulseProDeploymentSpec{
+						Namespace:           "pulsepro",
+						HelmChart:           "oci://registry.example.com/charts/pulse-pro",
+						HelmChartVersion:    "1.0.0",
+						PulseProVersion:     "2.3.0",
+						HelmValuesConfigMap: pulseprov1alpha1.ConfigMapReference{Name: "values", Key: "values.yaml"},
+						Secrets:             []pulseprov1alpha1.SecretReference{},
+						ProjectName:         "acme",
+						EnvironmentName:     name,
+						SyncInterval:        "10m",
+						Category:            "canary",
+					},
+				})).To(Succeed())
+				DeferCleanup(func() {
+					Expect(k8sClient.Delete(ctx, &pulseprov1alpha1.PulseProDeployment{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"}})).To(Succeed())
+				})
+			}
+		})
+
+		It("should promote each deployment once its analysis passes", func() {
+			createRollout(&pulseprov1alpha1.RolloutAnalysis{
+				Interval: "10s",
+				Metrics:  []pulseprov1alpha1.AnalysisMetric{{Name: "health", HTTP: &pulseprov1alpha1.HTTPCheck{URL: health.URL + "/{{ .Name }}"}}},
+			})
+
+			By("updating one deployment at a time")
+			status := reconcileRollout()
+			Expect(status.Phase).To(Equal(pulseprov1alpha1.RolloutPhaseProgressing))
+			Expect(deploymentVersion("analysed-a")).To(Equal("2.4.0"))
+			Expect(deploymentVersion("analysed-b")).To(Equal("2.3.0"))
+
+			By("waiting for the updated deployment to sync before analysing it")
+			status = reconcileRollout()
+			Expect(status.Message).To(ContainSubstring("Waiting for analysed-a"))
+			Expect(status.TargetNamed("analysed-a").Analysis).To(Equal(pulseprov1alpha1.AnalysisRunning))
+
+			By("moving on to the next deployment once the analysis passed")
+			markSynced("analysed-a")
+			status = reconcileRollout()
+			Expect(status.TargetNamed("analysed-a").Analysis).To(Equal(pulseprov1alpha1.AnalysisSuccessful))
+			Expect(deploymentVersion("analysed-b")).To(Equal("2.4.0"))
+
+			markSynced("analysed-b")
+			status = reconcileRollout()
+			Expect(status.TargetNamed("analysed-b").Analysis).To(Equal(pulseprov1alpha1.AnalysisSuccessful))
+			Expect(status.Phase).To(Equal(pulseprov1alpha1.RolloutPhaseCompleted))
+		})
+
+		It("should revert the rollout when the checks of its template fail", func() {
+			Expect(k8sClient.Create(ctx, &pulseprov1alpha1.PulseProAnalysisTemplate{
+				ObjectMeta: metav1.ObjectMeta{Name: "health", Namespace: "default"},
+				Spec: pulseprov1alpha1.PulseProAnalysisTemplateSpec{
+					Metrics: []pulseprov1alpha1.AnalysisMetric{{Name: "health", HTTP: &pulseprov1alpha1.HTTPCheck{URL: health.URL + "/{{ .Name }}"}}},
+				},
+			})).To(Succeed())
+			DeferCleanup(func() {
+				Expect(k8sClient.Delete(ctx, &pulseprov1alpha1.PulseProAnalysisTemplate{ObjectMeta: metav1.ObjectMeta{Name: "health", Namespace: "default"}})).To(Succeed())
+			})
+			createRollout(&pulseprov1alpha1.RolloutAnalysis{
+				FailureLimit: 2,
+				Templates:    []pulseprov1alpha1.AnalysisTemplateReference{{Name: "health"}},
+			})
+
+			reconcileRollout()
+			markSynced("analysed-a")
+			healthy = false
+
+			By("retrying failed runs up to the failure limit")
+			status := reconcileRollout()
+			Expect(status.Phase).To(Equal(pulseprov1alpha1.RolloutPhaseProgressing))
+			Expect(status.TargetNamed("analysed-a").FailedRuns).To(Equal(1))
+			Expect(status.Message).To(ContainSubstring("received HTTP status 503"))
+
+			By("reverting the updated deployment once the limit is reached")
+			status = reconcileRollout()
+			Expect(status.Phase).To(Equal(pulseprov1alpha1.RolloutPhaseFailed))
+			Expect(status.TargetNamed("analysed-a").Analysis).To(Equal(pulseprov1alpha1.AnalysisFailed))
+			Expect(deploymentVersion("analysed-a")).To(Equal("2.3.0"))
+			Expect(deploymentVersion("analysed-b")).To(Equal("2.3.0"))
+		})
+
+		It("should fail analysis runs whose template is missing", func() {
+			createRollout(&pulseprov1alpha1.RolloutAnalysis{
+				Templates: []pulseprov1alpha1.AnalysisTemplateReference{{Name: "missing"}},
+			})
+
+			reconcileRollout()
+			markSynced("analysed-a")
+
+			status := reconcileRollout()
+			Expect(status.Phase).To(Equal(pulseprov1alpha1.RolloutPhaseFailed))
+			Expect(status.Message).To(ContainSubstring("analysis template missing"))
+			Expect(deploymentVersion("analysed-a")).To(Equal("2.3.0"))
+		})
+	})
+})
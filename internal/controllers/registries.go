@@ -0,0 +1,130 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	pulseprov1alpha1 "github.com/smarter-contracts/pulsepro-operator/api/v1alpha1"
+	"github.com/smarter-contracts/pulsepro-operator/internal/redact"
+	"github.com/smarter-contracts/pulsepro-operator/internal/registry"
+	"github.com/smarter-contracts/pulsepro-operator/internal/retry"
+)
+
+// registryConfigFor collects the credentials of the deployment's registry Secrets and of the operator's
+// token providers into a temporary registry config for helm, and checks them against the chart's registry.
+// It returns an empty path when no registry needs credentials; the returned cleanup removes the config.
+func (r *PulseProDeploymentReconciler) registryConfigFor(ctx context.Context, instance *pulseprov1alpha1.PulseProDeployment, redactor *redact.Redactor, timeout time.Duration) (string, func(), error) {
+	keychain, err := r.registryKeychain(ctx, instance)
+	if err != nil {
+		return "", nil, err
+	}
+
+	hosts := keychain.Hosts()
+	chartHost := registry.ChartHost(instance.Spec.HelmChart)
+	if chartHost != "" && !slices.Contains(hosts, chartHost) {
+		hosts = append(hosts, chartHost)
+	}
+
+	providers := registry.Chain{keychain, r.RegistryProviders}
+	credentials := map[string]registry.Credentials{}
+	for _, host := range hosts {
+		creds, err := providers.Credentials(ctx, host)
+		if err != nil {
+			return "", nil, retry.Transient("RegistryTokenFailed", err)
+		}
+		if creds == nil {
+			// Public and local registries are pulled from anonymously
+			continue
+		}
+		redactor.Add(creds.Password)
+		credentials[host] = *creds
+	}
+	if len(credentials) == 0 {
+		return "", func() {}, nil
+	}
+
+	// Check the chart's registry before any Helm operation, so that rejected credentials are reported per deployment
+	if creds, ok := credentials[chartHost]; ok {
+		pingCtx, cancel := context.WithTimeout(ctx, timeout)
+		err := registry.Ping(pingCtx, http.DefaultClient, chartHost, creds)
+		cancel()
+		if _, unauthorized := err.(*registry.UnauthorizedError); unauthorized {
+			return "", nil, retry.Configuration("RegistryCredentialsRejected", err)
+		}
+		if err != nil {
+			return "", nil, retry.Transient("RegistryUnreachable", err)
+		}
+	}
+
+	// The config is written as config.json of its own directory, which cosign reads as DOCKER_CONFIG
+	dir, err := os.MkdirTemp("", "registry-config-")
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to write registry config: %v", err)
+	}
+	cleanup := func() { _ = os.RemoveAll(dir) }
+	path := filepath.Join(dir, "config.json")
+	if err := registry.WriteConfig(path, credentials); err != nil {
+		cleanup()
+		return "", nil, fmt.Errorf("failed to write registry config: %v", err)
+	}
+	return path, cleanup, nil
+}
+
+// registryKeychain reads the credentials of the deployment's registry Secrets
+func (r *PulseProDeploymentReconciler) registryKeychain(ctx context.Context, instance *pulseprov1alpha1.PulseProDeployment) (registry.Keychain, error) {
+	return readRegistryKeychain(ctx, r.Client, instance.Namespace, instance.Spec.RegistryCredentials)
+}
+
+// readRegistryKeychain reads the credentials of registry Secrets in namespace; the first Secret with
+// credentials for a host wins
+func readRegistryKeychain(ctx context.Context, c client.Reader, namespace string, refs []pulseprov1alpha1.RegistryCredentialsReference) (registry.Keychain, error) {
+	keychain := registry.Keychain{}
+	for _, ref := range refs {
+		secret := &corev1.Secret{}
+		if err := c.Get(ctx, types.NamespacedName{Name: ref.SecretName, Namespace: namespace}, secret); err != nil {
+			notFound := errors.IsNotFound(err)
+			err = fmt.Errorf("unable to read registry credentials Secret %s: %v", ref.SecretName, err)
+			if notFound {
+				return nil, retry.Configuration("RegistryCredentialsMissing", err)
+			}
+			return nil, retry.Transient("RegistryCredentialsUnavailable", err)
+		}
+
+		switch secret.Type {
+		case corev1.SecretTypeDockerConfigJson:
+			secretKeychain, err := registry.ParseDockerConfig(secret.Data[corev1.DockerConfigJsonKey])
+			if err != nil {
+				return nil, retry.Configuration("InvalidRegistryCredentials", fmt.Errorf("invalid registry credentials Secret %s: %v", ref.SecretName, err))
+			}
+			for host, creds := range secretKeychain {
+				if _, ok := keychain[host]; !ok {
+					keychain[host] = creds
+				}
+			}
+		case corev1.SecretTypeBasicAuth:
+			if ref.Host == "" {
+				return nil, retry.Configuration("InvalidRegistryCredentials", fmt.Errorf("registry credentials Secret %s of type %s needs a host", ref.SecretName, secret.Type))
+			}
+			if _, ok := keychain[ref.Host]; !ok {
+				keychain[ref.Host] = registry.Credentials{
+					Username: string(secret.Data[corev1.BasicAuthUsernameKey]),
+					Password: string(secret.Data[corev1.BasicAuthPasswordKey]),
+				}
+			}
+		default:
+			return nil, retry.Configuration("InvalidRegistryCredentials", fmt.Errorf("registry credentials Secret %s must be of type %s or %s, not %s",
+				ref.SecretName, corev1.SecretTypeDockerConfigJson, corev1.SecretTypeBasicAuth, secret.Type))
+		}
+	}
+	return keychain, nil
+}
@@ -0,0 +1,339 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	pulseprov1alpha1 "github.com/smarter-contracts/pulsepro-operator/api/v1alpha1"
+	"github.com/smarter-contracts/pulsepro-operator/internal/redact"
+	"github.com/smarter-contracts/pulsepro-operator/internal/retry"
+	"github.com/smarter-contracts/pulsepro-operator/internal/tmpl"
+	"github.com/smarter-contracts/pulsepro-operator/internal/values"
+)
+
+const (
+	// effectiveValuesKey is the key of the merged values in the effective values ConfigMap
+	effectiveValuesKey = "values.yaml"
+
+	// renderedValuesKey is the key of the rendered values template in the effective values ConfigMap
+	renderedValuesKey = "rendered-values.yaml"
+)
+
+// mergedValues are the Helm values of a deployment, merged from all of its values sources
+type mergedValues struct {
+	// values are the merged values
+	values map[string]interface{}
+	// document is the merged values document, including the values of Secrets
+	document []byte
+	// redacted is the merged values document with the values of Secrets masked
+	redacted string
+	// hash is the digest of document
+	hash string
+}
+
+// mergeValues reads the deployment's values sources in order and deep-merges them. The values of Secret
+// sources are added to the redactor.
+func (r *PulseProDeploymentReconciler) mergeValues(ctx context.Context, instance *pulseprov1alpha1.PulseProDeployment, repoDir string, redactor *redact.Redactor) (*mergedValues, error) {
+	sources := instance.Spec.ValuesFrom
+	if ref := instance.Spec.HelmValuesConfigMap; ref.Name != "" {
+		sources = append([]pulseprov1alpha1.ValuesSource{{ConfigMapKeyRef: &ref}}, sources...)
+	}
+
+	var layers []values.Layer
+	for i, source := range sources {
+		layer, found, err := r.readValuesSource(ctx, instance.Namespace, repoDir, source)
+		if err != nil {
+			return nil, err
+		}
+		if !found {
+			if !source.Optional {
+				return nil, retry.Configuration("ValuesMissing", fmt.Errorf("values source %d: %s does not exist", i, layer.Source))
+			}
+			continue
+		}
+		if source.SecretKeyRef != nil {
+			if err := redactor.AddYAML(layer.Data); err != nil {
+				return nil, retry.Configuration("InvalidValues", fmt.Errorf("invalid values in %s: %v", layer.Source, err))
+			}
+		}
+		layers = append(layers, layer)
+	}
+
+	merged, err := values.Merge(layers)
+	if err != nil {
+		return nil, retry.Configuration("InvalidValues", err)
+	}
+	document, err := values.Marshal(merged)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal values: %v", err)
+	}
+	return &mergedValues{values: merged, document: document, redacted: redactor.String(string(document)), hash: values.Hash(document)}, nil
+}
+
+// readValuesSource reads one values source. It reports false with a layer naming the source when
+// the source does not exist.
+func (r *PulseProDeploymentReconciler) readValuesSource(ctx context.Context, namespace, repoDir string, source pulseprov1alpha1.ValuesSource) (values.Layer, bool, error) {
+	set := 0
+	for _, isSet := range []bool{source.ConfigMapKeyRef != nil, source.SecretKeyRef != nil, source.GitFile != "", source.Inline != ""} {
+		if isSet {
+			set++
+		}
+	}
+	if set != 1 {
+		return values.Layer{}, false, retry.Configuration("InvalidValuesSource",
+			fmt.Errorf("a values source must set exactly one of configMapKeyRef, secretKeyRef, gitFile and inline"))
+	}
+
+	switch {
+	case source.ConfigMapKeyRef != nil:
+		ref := source.ConfigMapKeyRef
+		layer := values.Layer{Source: fmt.Sprintf("ConfigMap %s key %s", ref.Name, ref.Key)}
+		cm := &corev1.ConfigMap{}
+		if err := r.Get(ctx, types.NamespacedName{Name: ref.Name, Namespace: namespace}, cm); err != nil {
+			if errors.IsNotFound(err) {
+				return layer, false, nil
+			}
+			return layer, false, retry.Transient("ValuesUnavailable", fmt.Errorf("unable to read ConfigMap %s: %v", ref.Name, err))
+		}
+		data, ok := cm.Data[ref.Key]
+		layer.Data = []byte(data)
+		return layer, ok, nil
+
+	case source.SecretKeyRef != nil:
+		ref := source.SecretKeyRef
+		layer := values.Layer{Source: fmt.Sprintf("Secret %s key %s", ref.Name, ref.Key)}
+		secret := &corev1.Secret{}
+		if err := r.Get(ctx, types.NamespacedName{Name: ref.Name, Namespace: namespace}, secret); err != nil {
+			if errors.IsNotFound(err) {
+				return layer, false, nil
+			}
+			return layer, false, retry.Transient("ValuesUnavailable", fmt.Errorf("unable to read Secret %s: %v", ref.Name, err))
+		}
+		data, ok := secret.Data[ref.Key]
+		layer.Data = data
+		return layer, ok, nil
+
+	case source.GitFile != "":
+		layer := values.Layer{Source: "Git file " + source.GitFile}
+		if !filepath.IsLocal(source.GitFile) {
+			return layer, false, retry.Configuration("InvalidValuesSource", fmt.Errorf("Git file %s is outside of the repository", source.GitFile))
+		}
+		data, err := os.ReadFile(filepath.Join(repoDir, source.GitFile))
+		if os.IsNotExist(err) {
+			return layer, false, nil
+		}
+		if err != nil {
+			return layer, false, fmt.Errorf("failed to read Git file %s: %v", source.GitFile, err)
+		}
+		layer.Data = data
+		return layer, true, nil
+
+	default:
+		return values.Layer{Source: "inline values", Data: []byte(source.Inline)}, true, nil
+	}
+}
+
+// valuesTemplateData is the data a values template is rendered with. It offers the fields helmfile renders
+// release values templates with, and the deployment.
+type valuesTemplateData struct {
+	Values      map[string]interface{}
+	StateValues map[string]interface{}
+	Environment valuesTemplateEnvironment
+	Release     valuesTemplateRelease
+	Namespace   string
+	Deployment  valuesTemplateDeployment
+}
+
+type valuesTemplateEnvironment struct {
+	Name   string
+	Values map[string]interface{}
+}
+
+type valuesTemplateRelease struct {
+	Name      string
+	Namespace string
+	Chart     string
+}
+
+type valuesTemplateDeployment struct {
+	Name      string
+	Namespace string
+	Spec      pulseprov1alpha1.PulseProDeploymentSpec
+}
+
+// renderValuesTemplate renders the values template of the environment at templatePath, resolving the files
+// it reads against the directory of the helmfile like helmfile does. It returns the rendered values, or
+// nothing when the repository has no template. The environment variables the template reads are added to
+// the redactor, since they are the operator's and not the deployment's to publish.
+func renderValuesTemplate(instance *pulseprov1alpha1.PulseProDeployment, repoDir, helmfilePath, templatePath string, merged *mergedValues, redactor *redact.Redactor) ([]byte, error) {
+	text, err := os.ReadFile(templatePath)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read values template: %v", err)
+	}
+
+	name := instance.Spec.ProjectName + "-" + instance.Spec.EnvironmentName
+	data := valuesTemplateData{
+		Values:      merged.values,
+		StateValues: merged.values,
+		Environment: valuesTemplateEnvironment{Name: name, Values: merged.values},
+		Release:     valuesTemplateRelease{Name: name, Namespace: instance.Namespace, Chart: instance.Spec.HelmChart},
+		Namespace:   instance.Namespace,
+		Deployment:  valuesTemplateDeployment{Name: instance.Name, Namespace: instance.Namespace, Spec: instance.Spec},
+	}
+	relPath, _ := filepath.Rel(repoDir, templatePath)
+	renderer := &tmpl.Renderer{
+		BaseDir: filepath.Dir(helmfilePath),
+		Root:    repoDir,
+		OnEnv:   func(_, value string) { redactor.Add(value) },
+	}
+	rendered, err := renderer.Render(relPath, text, data)
+	if err != nil {
+		return nil, retry.Configuration("InvalidValuesTemplate", fmt.Errorf("failed to render %s: %v", relPath, err))
+	}
+	if _, err := values.Merge([]values.Layer{{Source: "rendered " + relPath, Data: rendered}}); err != nil {
+		return nil, retry.Configuration("InvalidValuesTemplate", err)
+	}
+	return rendered, nil
+}
+
+// writeValuesFile writes the merged values to a temporary file for helmfile; the returned cleanup removes it.
+// It returns an empty path when there are no values.
+func writeValuesFile(merged *mergedValues) (string, func(), error) {
+	if len(merged.document) == 0 {
+		return "", func() {}, nil
+	}
+	file, err := os.CreateTemp("", "values-*.yaml")
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to write values file: %v", err)
+	}
+	cleanup := func() { _ = os.Remove(file.Name()) }
+	_, err = file.Write(merged.document)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		cleanup()
+		return "", nil, fmt.Errorf("failed to write values file: %v", err)
+	}
+	return file.Name(), cleanup, nil
+}
+
+// publishEffectiveValues keeps the redacted merged values and rendered values template in a ConfigMap owned
+// by the deployment, so that they can be inspected with kubectl
+func (r *PulseProDeploymentReconciler) publishEffectiveValues(ctx context.Context, instance *pulseprov1alpha1.PulseProDeployment, merged *mergedValues, rendered string) error {
+	cm := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: instance.Name + "-effective-values", Namespace: instance.Namespace}}
+	_, err := controllerutil.CreateOrUpdate(ctx, r.Client, cm, func() error {
+		// Never take over a ConfigMap that another owner or a user created
+		if cm.ResourceVersion != "" && !metav1.IsControlledBy(cm, instance) {
+			return retry.Configuration("EffectiveValuesConflict", fmt.Errorf("ConfigMap %s exists and is not managed by the deployment", cm.Name))
+		}
+		cm.Data = map[string]string{effectiveValuesKey: merged.redacted}
+		if rendered != "" {
+			cm.Data[renderedValuesKey] = rendered
+		}
+		if cm.Annotations == nil {
+			cm.Annotations = map[string]string{}
+		}
+		cm.Annotations[pulseprov1alpha1.ValuesHashAnnotation] = merged.hash
+		return controllerutil.SetControllerReference(instance, cm, r.Scheme)
+	})
+	if _, isClassified := err.(*retry.Error); isClassified {
+		instance.Status.EffectiveValuesConfigMap = ""
+		return err
+	}
+	if err != nil {
+		return fmt.Errorf("failed to update ConfigMap %s: %v", cm.Name, err)
+	}
+	instance.Status.EffectiveValuesConfigMap = cm.Name
+	return nil
+}
+
+// mirrorRepoValues materializes the values files of the repository into the deployment's mirror ConfigMap.
+// Only files of the environment's values tree are mirrored, and their content is redacted, since the
+// ConfigMap can be read by anyone who may read ConfigMaps in the namespace. Keys of files that were removed
+// from the repository are pruned, and the source commit is recorded in annotations.
+func (r *PulseProDeploymentReconciler) mirrorRepoValues(ctx context.Context, instance *pulseprov1alpha1.PulseProDeployment, repoDir, revision string, redactor *redact.Redactor) error {
+	mirror := instance.Spec.ValuesMirror
+	if mirror == nil {
+		instance.Status.ValuesMirrorConfigMap = ""
+		return nil
+	}
+
+	valuesDir := fmt.Sprintf("environments/%s-%s/values", instance.Spec.ProjectName, instance.Spec.EnvironmentName)
+	paths := mirror.Paths
+	if len(paths) == 0 {
+		paths = []string{valuesDir}
+	}
+	for _, path := range paths {
+		if !withinDir(repoDir, valuesDir, path) {
+			return retry.Configuration("InvalidValuesMirror", fmt.Errorf("%s is outside of the values files of the environment in %s", path, valuesDir))
+		}
+	}
+	data, err := values.ReadFiles(repoDir, paths)
+	if err != nil {
+		return retry.Configuration("InvalidValuesMirror", fmt.Errorf("failed to mirror values files: %v", err))
+	}
+	for key, content := range data {
+		data[key] = redactor.String(content)
+	}
+
+	name := mirror.ConfigMapName
+	if name == "" {
+		name = instance.Name + "-repo-values"
+	}
+	cm := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: instance.Namespace}}
+	_, err = controllerutil.CreateOrUpdate(ctx, r.Client, cm, func() error {
+		// Never take over a ConfigMap that another owner or a user created
+		if cm.ResourceVersion != "" && !metav1.IsControlledBy(cm, instance) {
+			return retry.Configuration("ValuesMirrorConflict", fmt.Errorf("ConfigMap %s exists and is not managed by the deployment", name))
+		}
+		cm.Data = data
+		if cm.Labels == nil {
+			cm.Labels = map[string]string{}
+		}
+		cm.Labels[pulseprov1alpha1.PlanDeploymentLabel] = instance.Name
+		if cm.Annotations == nil {
+			cm.Annotations = map[string]string{}
+		}
+		cm.Annotations[pulseprov1alpha1.SourceRepositoryAnnotation] = instance.Spec.GitRepoURL
+		cm.Annotations[pulseprov1alpha1.SourceRevisionAnnotation] = revision
+		return controllerutil.SetControllerReference(instance, cm, r.Scheme)
+	})
+	if _, isClassified := err.(*retry.Error); isClassified {
+		return err
+	}
+	if err != nil {
+		return retry.Transient("ValuesMirrorFailed", fmt.Errorf("failed to update ConfigMap %s: %v", name, err))
+	}
+	instance.Status.ValuesMirrorConfigMap = name
+	return nil
+}
+
+// withinDir reports whether path, relative to root, is dir or inside it, also after following symbolic links
+func withinDir(root, dir, path string) bool {
+	inside := func(path, dir string) bool {
+		return path == dir || strings.HasPrefix(path, dir+string(filepath.Separator))
+	}
+	if !inside(filepath.Clean(filepath.FromSlash(path)), filepath.Clean(filepath.FromSlash(dir))) {
+		return false
+	}
+	resolvedDir, err := filepath.EvalSymlinks(filepath.Join(root, dir))
+	if err != nil {
+		// A missing directory is reported when its files are read
+		return true
+	}
+	resolved, err := filepath.EvalSymlinks(filepath.Join(root, path))
+	return err != nil || inside(resolved, resolvedDir)
+}
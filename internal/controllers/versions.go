@@ -0,0 +1,253 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	pulseprov1alpha1 "github.com/smarter-contracts/pulsepro-operator/api/v1alpha1"
+	"github.com/smarter-contracts/pulsepro-operator/internal/command"
+	"github.com/smarter-contracts/pulsepro-operator/internal/registry"
+	"github.com/smarter-contracts/pulsepro-operator/internal/retry"
+	"github.com/smarter-contracts/pulsepro-operator/internal/versions"
+)
+
+// desiredVersion returns the PulsePro version the deployment releases: the version its range resolved to,
+// or the exact version of the spec
+func desiredVersion(instance *pulseprov1alpha1.PulseProDeployment) string {
+	if resolved := instance.Status.ResolvedVersions; resolved != nil && versions.IsConstraint(instance.Spec.PulseProVersion) {
+		return resolved.PulseProVersion
+	}
+	return instance.Spec.PulseProVersion
+}
+
+// desiredChartVersion returns the chart version the deployment releases: the version its range resolved to,
+// or the exact version of the spec
+func desiredChartVersion(instance *pulseprov1alpha1.PulseProDeployment) string {
+	if resolved := instance.Status.ResolvedVersions; resolved != nil && versions.IsConstraint(instance.Spec.HelmChartVersion) {
+		return resolved.HelmChartVersion
+	}
+	return instance.Spec.HelmChartVersion
+}
+
+// versionStateValues passes the released versions to helmfile as the state values pulseProVersion and
+// helmChartVersion, which the helmfile of the environment uses for the image tag and chart version
+func versionStateValues(instance *pulseprov1alpha1.PulseProDeployment) []string {
+	var args []string
+	if version := desiredVersion(instance); version != "" {
+		args = append(args, "--state-values-set", "pulseProVersion="+version)
+	}
+	if version := desiredChartVersion(instance); version != "" {
+		args = append(args, "--state-values-set", "helmChartVersion="+version)
+	}
+	return args
+}
+
+// resolveVersions resolves the version ranges of the deployment to exact versions in status. With the Auto
+// update policy the ranges are resolved on every reconcile; Notify and Manual keep the versions resolved for
+// the current spec generation, and Notify reports newer matching versions in the UpdateAvailable condition.
+func (r *PulseProDeploymentReconciler) resolveVersions(ctx context.Context, instance *pulseprov1alpha1.PulseProDeployment, timeout time.Duration) error {
+	pulseProRange := versions.IsConstraint(instance.Spec.PulseProVersion)
+	chartRange := versions.IsConstraint(instance.Spec.HelmChartVersion)
+	if !pulseProRange && !chartRange {
+		instance.Status.ResolvedVersions = nil
+		meta.RemoveStatusCondition(&instance.Status.Conditions, pulseprov1alpha1.ConditionUpdateAvailable)
+		return nil
+	}
+
+	policy := instance.Spec.UpdatePolicy
+	if policy == "" {
+		policy = pulseprov1alpha1.UpdatePolicyAuto
+	}
+	previous := instance.Status.ResolvedVersions
+	held := policy != pulseprov1alpha1.UpdatePolicyAuto && previous != nil && previous.Generation == instance.Generation
+	if policy != pulseprov1alpha1.UpdatePolicyNotify {
+		meta.RemoveStatusCondition(&instance.Status.Conditions, pulseprov1alpha1.ConditionUpdateAvailable)
+	}
+	if held && policy == pulseprov1alpha1.UpdatePolicyManual {
+		return nil
+	}
+
+	latest := pulseprov1alpha1.ResolvedVersions{
+		PulseProVersion:  instance.Spec.PulseProVersion,
+		HelmChartVersion: instance.Spec.HelmChartVersion,
+	}
+	var err error
+	if pulseProRange {
+		if instance.Spec.ImageRepository == "" {
+			return retry.Permanent("ImageRepositoryMissing", fmt.Errorf("pulseProVersion %q is a range, which needs an imageRepository to resolve it against", instance.Spec.PulseProVersion))
+		}
+		latest.PulseProVersion, err = r.latestTag(ctx, instance, instance.Spec.ImageRepository, instance.Spec.PulseProVersion, timeout)
+		if err != nil {
+			return err
+		}
+	}
+	if chartRange {
+		latest.HelmChartVersion, err = r.latestChartVersion(ctx, instance, timeout)
+		if err != nil {
+			return err
+		}
+	}
+
+	now := metav1.Now()
+	if held {
+		// Notify: keep the resolved versions and report the newer ones
+		resolved := previous.DeepCopy()
+		resolved.AvailablePulseProVersion, resolved.AvailableHelmChartVersion = "", ""
+		var available []string
+		if pulseProRange && latest.PulseProVersion != previous.PulseProVersion {
+			resolved.AvailablePulseProVersion = latest.PulseProVersion
+			available = append(available, fmt.Sprintf("PulsePro %s", latest.PulseProVersion))
+		}
+		if chartRange && latest.HelmChartVersion != previous.HelmChartVersion {
+			resolved.AvailableHelmChartVersion = latest.HelmChartVersion
+			available = append(available, fmt.Sprintf("chart %s", latest.HelmChartVersion))
+		}
+		resolved.ResolvedAt = &now
+		instance.Status.ResolvedVersions = resolved
+
+		condition := metav1.Condition{
+			Type:               pulseprov1alpha1.ConditionUpdateAvailable,
+			Status:             metav1.ConditionFalse,
+			Reason:             pulseprov1alpha1.ReasonUpToDate,
+			Message:            "The resolved versions are the newest matching their ranges",
+			ObservedGeneration: instance.Generation,
+		}
+		if len(available) > 0 {
+			condition.Status = metav1.ConditionTrue
+			condition.Reason = pulseprov1alpha1.ReasonNewerVersionAvailable
+			condition.Message = fmt.Sprintf("%s matching the version ranges; update the spec to release it", strings.Join(available, " and "))
+			if resolved.AvailablePulseProVersion != previous.AvailablePulseProVersion || resolved.AvailableHelmChartVersion != previous.AvailableHelmChartVersion {
+				r.Recorder.Eventf(instance, corev1.EventTypeNormal, EventReasonVersionAvailable, "%s available", strings.Join(available, " and "))
+			}
+		}
+		meta.SetStatusCondition(&instance.Status.Conditions, condition)
+		return nil
+	}
+
+	if previous == nil || latest.PulseProVersion != previous.PulseProVersion || latest.HelmChartVersion != previous.HelmChartVersion {
+		var resolved []string
+		if pulseProRange {
+			resolved = append(resolved, fmt.Sprintf("PulsePro %s to %s", instance.Spec.PulseProVersion, latest.PulseProVersion))
+		}
+		if chartRange {
+			resolved = append(resolved, fmt.Sprintf("chart %s to %s", instance.Spec.HelmChartVersion, latest.HelmChartVersion))
+		}
+		r.Recorder.Eventf(instance, corev1.EventTypeNormal, EventReasonVersionResolved, "Resolved %s", strings.Join(resolved, " and "))
+	}
+	latest.Generation = instance.Generation
+	latest.ResolvedAt = &now
+	instance.Status.ResolvedVersions = &latest
+	if policy == pulseprov1alpha1.UpdatePolicyNotify {
+		meta.SetStatusCondition(&instance.Status.Conditions, metav1.Condition{
+			Type:               pulseprov1alpha1.ConditionUpdateAvailable,
+			Status:             metav1.ConditionFalse,
+			Reason:             pulseprov1alpha1.ReasonUpToDate,
+			Message:            "The resolved versions are the newest matching their ranges",
+			ObservedGeneration: instance.Generation,
+		})
+	}
+	return nil
+}
+
+// latestChartVersion resolves the chart version range against the tags of an OCI chart, or against the
+// versions helm finds in the chart repository
+func (r *PulseProDeploymentReconciler) latestChartVersion(ctx context.Context, instance *pulseprov1alpha1.PulseProDeployment, timeout time.Duration) (string, error) {
+	chart, constraint := instance.Spec.HelmChart, instance.Spec.HelmChartVersion
+	if registry.ChartHost(chart) != "" {
+		tag, err := r.latestTag(ctx, instance, chart, constraint, timeout)
+		// Helm stores the "+" of chart versions as "_" in OCI tags
+		return strings.ReplaceAll(tag, "_", "+"), err
+	}
+
+	if err := versions.Validate(constraint); err != nil {
+		return "", retry.Permanent("InvalidVersionRange", err)
+	}
+	available, err := searchChartVersions(ctx, timeout, chart)
+	if err != nil {
+		return "", err
+	}
+	return latestMatch(constraint, available)
+}
+
+// latestTag resolves a version range against the tags of an OCI repository, with the deployment's registry credentials
+func (r *PulseProDeploymentReconciler) latestTag(ctx context.Context, instance *pulseprov1alpha1.PulseProDeployment, repository, constraint string, timeout time.Duration) (string, error) {
+	if err := versions.Validate(constraint); err != nil {
+		return "", retry.Permanent("InvalidVersionRange", err)
+	}
+	keychain, err := r.registryKeychain(ctx, instance)
+	if err != nil {
+		return "", err
+	}
+	creds, err := registry.Chain{keychain, r.RegistryProviders}.Credentials(ctx, registry.Host(repository))
+	if err != nil {
+		return "", retry.Transient("RegistryTokenFailed", err)
+	}
+
+	listCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	tags, err := registry.Tags(listCtx, http.DefaultClient, repository, creds)
+	if _, unauthorized := err.(*registry.UnauthorizedError); unauthorized {
+		return "", retry.Configuration("RegistryCredentialsRejected", err)
+	}
+	if err != nil {
+		return "", retry.Transient("RegistryUnreachable", fmt.Errorf("failed to list the tags of %s: %v", repository, err))
+	}
+	return latestMatch(constraint, tags)
+}
+
+// latestMatch returns the highest version matching the range, classifying a range nothing matches as a
+// configuration failure that is retried until a matching version is published
+func latestMatch(constraint string, available []string) (string, error) {
+	version, err := versions.Latest(constraint, available)
+	if _, noMatch := err.(*versions.NoMatchError); noMatch {
+		return "", retry.Configuration("NoMatchingVersion", err)
+	}
+	if err != nil {
+		return "", retry.Permanent("InvalidVersionRange", err)
+	}
+	return version, nil
+}
+
+// searchChartVersions updates the index of the chart's repository and lists the versions of a chart such as
+// "acme/pulse-pro" from it
+func searchChartVersions(ctx context.Context, timeout time.Duration, chart string) ([]string, error) {
+	repo, _, ok := strings.Cut(chart, "/")
+	if !ok {
+		return nil, retry.Permanent("InvalidVersionRange", fmt.Errorf("chart %s is not from a chart repository or OCI registry, so its version must be exact", chart))
+	}
+	if output, err := command.New(ctx, timeout, "helm", "repo", "update", repo).CombinedOutput(); err != nil {
+		return nil, retry.Transient("ChartRepositoryUnavailable", fmt.Errorf("failed to update chart repository %s: %v\nOutput: %s", repo, err, strings.TrimSpace(string(output))))
+	}
+	output, err := command.New(ctx, timeout, "helm", "search", "repo", chart, "--versions", "--devel", "--output", "json").Output()
+	if err != nil {
+		return nil, retry.Transient("ChartRepositoryUnavailable", fmt.Errorf("failed to search the versions of chart %s: %v", chart, err))
+	}
+	return parseChartSearch(output, chart)
+}
+
+// parseChartSearch reads the versions of chart from the JSON output of helm search repo
+func parseChartSearch(output []byte, chart string) ([]string, error) {
+	var results []struct {
+		Name    string `json:"name"`
+		Version string `json:"version"`
+	}
+	if err := json.Unmarshal(output, &results); err != nil {
+		return nil, fmt.Errorf("failed to parse the versions of chart %s: %v", chart, err)
+	}
+	var found []string
+	for _, result := range results {
+		// helm search matches substrings, so other charts of the repository may be listed too
+		if result.Name == chart {
+			found = append(found, result.Version)
+		}
+	}
+	return found, nil
+}
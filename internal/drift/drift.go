@@ -0,0 +1,98 @@
+package drift
+
+import (
+	"bufio"
+	"fmt"
+	"regexp"
+	"strings"
+
+	pulseprov1alpha1 "github.com/smarter-contracts/pulsepro-operator/api/v1alpha1"
+)
+
+// headerPattern matches the per-object headers printed by helm-diff, e.g.
+// "pulsepro, pulsepro-api, Deployment (apps) has changed:"
+var headerPattern = regexp.MustCompile(`^(\S*), (\S+), (\S+) \(([^)]*)\) has (changed|been added|been removed):$`)
+
+// fieldPattern matches a YAML key at the start of a diff line, optionally as the first key of a list item
+var fieldPattern = regexp.MustCompile(`^(?:- )?([A-Za-z0-9_.\-/"']+):`)
+
+// changes maps the helm-diff wording to the Change of a DriftedObject
+var changes = map[string]string{
+	"changed":      "Change",
+	"been added":   "Add",
+	"been removed": "Remove",
+}
+
+// Parse extracts the drifted objects from the output of `helmfile diff`
+func Parse(output string) []pulseprov1alpha1.DriftedObject {
+	var objects []pulseprov1alpha1.DriftedObject
+	var current *pulseprov1alpha1.DriftedObject
+
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), " \t")
+
+		if match := headerPattern.FindStringSubmatch(strings.TrimSpace(line)); match != nil {
+			kind := match[3]
+			if group := match[4]; group != "" && group != "v1" {
+				kind += "." + group
+			}
+			objects = append(objects, pulseprov1alpha1.DriftedObject{
+				Kind:      kind,
+				Namespace: match[1],
+				Name:      match[2],
+				Change:    changes[match[5]],
+			})
+			current = &objects[len(objects)-1]
+			continue
+		}
+
+		// Only the changed lines of modified objects name drifted fields
+		if current == nil || current.Change != "Change" {
+			continue
+		}
+		trimmed := strings.TrimSpace(line)
+		if !strings.HasPrefix(trimmed, "+") && !strings.HasPrefix(trimmed, "-") {
+			continue
+		}
+		if match := fieldPattern.FindStringSubmatch(strings.TrimSpace(trimmed[1:])); match != nil {
+			current.Fields = appendUnique(current.Fields, strings.Trim(match[1], `"'`))
+		}
+	}
+	return objects
+}
+
+// Summary describes the drifted objects in one line, e.g.
+// "Deployment.apps pulsepro/pulsepro-api changed (replicas, image); ConfigMap pulsepro/extra would be removed"
+func Summary(objects []pulseprov1alpha1.DriftedObject) string {
+	parts := make([]string, 0, len(objects))
+	for _, object := range objects {
+		name := object.Name
+		if object.Namespace != "" {
+			name = object.Namespace + "/" + object.Name
+		}
+		switch object.Change {
+		case "Add":
+			parts = append(parts, fmt.Sprintf("%s %s is missing", object.Kind, name))
+		case "Remove":
+			parts = append(parts, fmt.Sprintf("%s %s would be removed", object.Kind, name))
+		default:
+			if len(object.Fields) == 0 {
+				parts = append(parts, fmt.Sprintf("%s %s changed", object.Kind, name))
+			} else {
+				parts = append(parts, fmt.Sprintf("%s %s changed (%s)", object.Kind, name, strings.Join(object.Fields, ", ")))
+			}
+		}
+	}
+	return strings.Join(parts, "; ")
+}
+
+func appendUnique(values []string, value string) []string {
+	for _, v := range values {
+		if v == value {
+			return values
+		}
+	}
+	return append(values, value)
+}
@@ -0,0 +1,55 @@
+package drift
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	pulseprov1alpha1 "github.com/smarter-contracts/pulsepro-operator/api/v1alpha1"
+)
+
+const helmfileDiffOutput = `Comparing release=acme-prod, chart=oci://europe-docker.pkg.dev/pulsepro/charts/pulse-pro
+pulsepro, pulsepro-api, Deployment (apps) has changed:
+  # Source: pulse-pro/templates/api.yaml
+  apiVersion: apps/v1
+  kind: Deployment
+  metadata:
+    name: pulsepro-api
+  spec:
+-   replicas: 5
++   replicas: 3
+    template:
+      spec:
+        containers:
+-         - image: pulsepro/api:2.3.1-hotfix
++         - image: pulsepro/api:2.3.0
+pulsepro, pulsepro-settings, ConfigMap (v1) has been added:
++ # Source: pulse-pro/templates/settings.yaml
++ apiVersion: v1
++ kind: ConfigMap
+pulsepro, debug, Service (v1) has been removed:
+- apiVersion: v1
+- kind: Service
+`
+
+var _ = Describe("Drift", func() {
+	It("should parse drifted objects and fields from helmfile diff output", func() {
+		objects := Parse(helmfileDiffOutput)
+
+		Expect(objects).To(Equal([]pulseprov1alpha1.DriftedObject{
+			{Kind: "Deployment.apps", Namespace: "pulsepro", Name: "pulsepro-api", Change: "Change", Fields: []string{"replicas", "image"}},
+			{Kind: "ConfigMap", Namespace: "pulsepro", Name: "pulsepro-settings", Change: "Add"},
+			{Kind: "Service", Namespace: "pulsepro", Name: "debug", Change: "Remove"},
+		}))
+	})
+
+	It("should find no drift in output without object headers", func() {
+		Expect(Parse("Comparing release=acme-prod, chart=pulse-pro\n")).To(BeEmpty())
+	})
+
+	It("should summarise drifted objects", func() {
+		Expect(Summary(Parse(helmfileDiffOutput))).To(Equal(
+			"Deployment.apps pulsepro/pulsepro-api changed (replicas, image); " +
+				"ConfigMap pulsepro/pulsepro-settings is missing; " +
+				"Service pulsepro/debug would be removed"))
+	})
+})
@@ -0,0 +1,374 @@
+package gitcache
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/storage/memory"
+	"github.com/go-logr/logr"
+
+	"github.com/smarter-contracts/pulsepro-operator/internal/command"
+	"github.com/smarter-contracts/pulsepro-operator/internal/locks"
+)
+
+// Change reports that the branch of a repository moved to a new revision
+type Change struct {
+	URL      string
+	Branch   string
+	Revision string
+}
+
+// Cache keeps one checkout per repository and branch, shared by all deployments that use it.
+// A checkout is checked against the remote at most once per interval, and only fetched when the
+// remote branch moved.
+//
+// Each checkout is a mirror clone that only the cache reads, plus a worktree per fetched revision that the
+// workspaces are read from. A new revision gets a new worktree, so fetching never waits for the readers of
+// the previous one; a replaced worktree is removed once its last reader released it.
+type Cache struct {
+	root    string
+	timeout time.Duration
+	log     logr.Logger
+
+	workspaces *locks.KeyedRWMutex
+
+	mu          sync.Mutex
+	checkouts   map[string]*checkout
+	subscribers []func(Change)
+}
+
+// checkout is the state of one cached repository and branch
+type checkout struct {
+	// mu serializes the remote checks and fetches of the checkout
+	mu       sync.Mutex
+	revision string
+	checked  time.Time
+
+	// worktree is the directory of the worktree of revision, or "" before the first checkout
+	worktree string
+}
+
+// Workspace is a read-locked worktree of a checkout. It is not changed or removed until Release is called.
+type Workspace struct {
+	Dir      string
+	Revision string
+
+	release func()
+}
+
+// Release unlocks the workspace
+func (w *Workspace) Release() {
+	w.release()
+}
+
+// New returns a Cache keeping its checkouts under root, bounding each remote check and fetch by timeout
+func New(root string, timeout time.Duration, log logr.Logger) *Cache {
+	return &Cache{
+		root:       root,
+		timeout:    timeout,
+		log:        log,
+		workspaces: locks.NewKeyedRWMutex(),
+		checkouts:  map[string]*checkout{},
+	}
+}
+
+// Subscribe registers fn to be called whenever a cached branch moves to a new revision
+func (c *Cache) Subscribe(fn func(Change)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.subscribers = append(c.subscribers, fn)
+}
+
+// Dir returns the directory of the checkout of branch of repoURL, which holds its mirror and worktrees
+func (c *Cache) Dir(repoURL, branch string) string {
+	sum := sha256.Sum256([]byte(repoURL + "#" + branch))
+	return filepath.Join(c.root, hex.EncodeToString(sum[:8]))
+}
+
+// Expire makes the next Checkout of branch of repoURL check the remote again, e.g. after a push
+func (c *Cache) Expire(repoURL, branch string) {
+	c.mu.Lock()
+	co, ok := c.checkouts[c.Dir(repoURL, branch)]
+	c.mu.Unlock()
+	if !ok {
+		return
+	}
+	co.mu.Lock()
+	defer co.mu.Unlock()
+	co.checked = time.Time{}
+}
+
+// SameRepository reports whether two URLs refer to the same repository, regardless of the scheme,
+// credentials and .git suffix. SCP-like SSH URLs such as git@host:org/repo are supported.
+func SameRepository(a, b string) bool {
+	return normalizeURL(a) == normalizeURL(b)
+}
+
+// normalizeURL reduces a repository URL to its lowercased host and path
+func normalizeURL(repoURL string) string {
+	u := strings.TrimSpace(repoURL)
+	if i := strings.Index(u, "://"); i >= 0 {
+		u = u[i+3:]
+	} else if i := strings.Index(u, ":"); i >= 0 && !strings.Contains(u[:i], "/") {
+		// SCP-like syntax: [user@]host:path
+		u = u[:i] + "/" + u[i+1:]
+	}
+	if at := strings.Index(u, "@"); at >= 0 && at < strings.Index(u+"/", "/") {
+		u = u[at+1:]
+	}
+	host, path, _ := strings.Cut(u, "/")
+	// Drop the port, e.g. of ssh://git@host:22/org/repo
+	if h, _, ok := strings.Cut(host, ":"); ok {
+		host = h
+	}
+	path = strings.TrimSuffix(strings.TrimSuffix(path, "/"), ".git")
+	return strings.ToLower(host) + "/" + path
+}
+
+// Checkout brings the checkout of branch of repoURL up to date, unless the remote was already checked
+// within maxAge, and returns it read-locked. An empty branch is the remote's default branch.
+func (c *Cache) Checkout(ctx context.Context, repoURL, branch string, maxAge time.Duration) (*Workspace, error) {
+	dir := c.Dir(repoURL, branch)
+	co := c.checkout(dir)
+
+	co.mu.Lock()
+	change, retired, err := c.refresh(ctx, co, repoURL, branch, dir, maxAge)
+	if err != nil {
+		co.mu.Unlock()
+		return nil, err
+	}
+	// Lock the worktree before another caller can replace it. Only replaced worktrees are ever locked for
+	// writing, so this does not wait.
+	unlock := c.workspaces.RLock(co.worktree)
+	workspace := &Workspace{Dir: co.worktree, Revision: co.revision, release: unlock}
+	co.mu.Unlock()
+
+	if retired != "" {
+		go c.remove(retired)
+	}
+	if change != nil {
+		c.notify(*change)
+	}
+	return workspace, nil
+}
+
+func (c *Cache) checkout(dir string) *checkout {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	co, ok := c.checkouts[dir]
+	if !ok {
+		co = &checkout{}
+		// A mirror left by a previous run of the operator is reused
+		co.revision, _ = headRevision(mirrorDir(dir))
+		c.checkouts[dir] = co
+	}
+	return co
+}
+
+func (c *Cache) notify(change Change) {
+	c.mu.Lock()
+	subscribers := c.subscribers
+	c.mu.Unlock()
+	for _, fn := range subscribers {
+		fn(change)
+	}
+}
+
+// refresh fetches the mirror when it is older than maxAge and the remote branch moved, and checks out a new
+// worktree for a new revision. It returns the change when a known revision was replaced by a new one, and
+// the replaced worktree, if any.
+func (c *Cache) refresh(ctx context.Context, co *checkout, repoURL, branch, dir string, maxAge time.Duration) (*Change, string, error) {
+	if co.worktree != "" && time.Since(co.checked) < maxAge {
+		return nil, "", nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	remote, err := c.remoteRevision(ctx, repoURL, branch)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if remote == co.revision && co.worktree != "" {
+		c.log.V(1).Info("Repository is already up to date", "repoURL", repoURL, "branch", branch, "revision", remote)
+		co.checked = time.Now()
+		return nil, "", nil
+	}
+
+	var change *Change
+	if remote != co.revision {
+		revision, err := c.fetch(ctx, repoURL, branch, mirrorDir(dir))
+		if err != nil {
+			return nil, "", err
+		}
+		if co.revision != "" && co.revision != revision {
+			change = &Change{URL: repoURL, Branch: branch, Revision: revision}
+		}
+		co.revision = revision
+	}
+	if co.worktree == "" {
+		// Nothing reads the worktrees of a previous run of the operator
+		removeWorktrees(dir)
+	}
+	worktree, err := c.checkoutWorktree(ctx, dir, co.revision)
+	if err != nil {
+		return nil, "", err
+	}
+	retired := co.worktree
+	co.worktree, co.checked = worktree, time.Now()
+	return change, retired, nil
+}
+
+// mirrorDir returns the directory of the mirror clone of the checkout in dir
+func mirrorDir(dir string) string {
+	return filepath.Join(dir, "mirror")
+}
+
+// checkoutWorktree clones the mirror of the checkout in dir into a new worktree of revision
+func (c *Cache) checkoutWorktree(ctx context.Context, dir, revision string) (string, error) {
+	// A revision may be checked out again, e.g. after a revert, while its old worktree is still read
+	worktree, err := os.MkdirTemp(dir, "worktree-"+revision+"-")
+	if err != nil {
+		return "", fmt.Errorf("failed to create worktree: %v", err)
+	}
+	repo, err := git.PlainCloneContext(ctx, worktree, false, &git.CloneOptions{URL: mirrorDir(dir), NoCheckout: true})
+	if err == nil {
+		var w *git.Worktree
+		if w, err = repo.Worktree(); err == nil {
+			err = w.Reset(&git.ResetOptions{Commit: plumbing.NewHash(revision), Mode: git.HardReset})
+		}
+	}
+	if err != nil {
+		_ = os.RemoveAll(worktree)
+		return "", fmt.Errorf("failed to check out %s: %v", revision, err)
+	}
+	return worktree, nil
+}
+
+// remove deletes a replaced worktree once its readers released it
+func (c *Cache) remove(worktree string) {
+	unlock := c.workspaces.Lock(worktree)
+	defer unlock()
+	if err := os.RemoveAll(worktree); err != nil {
+		c.log.Error(err, "Failed to remove worktree", "dir", worktree)
+	}
+}
+
+// removeWorktrees deletes everything in the directory of a checkout but its mirror
+func removeWorktrees(dir string) {
+	entries, _ := os.ReadDir(dir)
+	for _, entry := range entries {
+		if entry.Name() != filepath.Base(mirrorDir(dir)) {
+			_ = os.RemoveAll(filepath.Join(dir, entry.Name()))
+		}
+	}
+}
+
+// remoteRevision returns the commit the branch of repoURL points to, without fetching it
+func (c *Cache) remoteRevision(ctx context.Context, repoURL, branch string) (string, error) {
+	remote := git.NewRemote(memory.NewStorage(), &config.RemoteConfig{Name: "origin", URLs: []string{repoURL}})
+	refs, err := remote.ListContext(ctx, &git.ListOptions{})
+	if err != nil {
+		return "", gitError(ctx, "list", c.timeout, err)
+	}
+
+	byName := map[plumbing.ReferenceName]*plumbing.Reference{}
+	for _, ref := range refs {
+		byName[ref.Name()] = ref
+	}
+	name := plumbing.HEAD
+	if branch != "" {
+		name = plumbing.NewBranchReferenceName(branch)
+	}
+	ref := byName[name]
+	if ref != nil && ref.Type() == plumbing.SymbolicReference {
+		ref = byName[ref.Target()]
+	}
+	if ref == nil {
+		return "", fmt.Errorf("reference %s not found in repository", name)
+	}
+	return ref.Hash().String(), nil
+}
+
+// fetch clones the repository into the mirror dir, or fetches it and resets it to the remote branch
+func (c *Cache) fetch(ctx context.Context, repoURL, branch, dir string) (string, error) {
+	if _, err := os.Stat(filepath.Join(dir, ".git")); os.IsNotExist(err) {
+		c.log.Info("Cloning repository", "repoURL", repoURL, "branch", branch)
+		// Remove the leftovers of an interrupted clone
+		if err := os.RemoveAll(dir); err != nil {
+			return "", fmt.Errorf("failed to clean workspace: %v", err)
+		}
+		options := &git.CloneOptions{URL: repoURL, SingleBranch: true}
+		if branch != "" {
+			options.ReferenceName = plumbing.NewBranchReferenceName(branch)
+		}
+		if _, err := git.PlainCloneContext(ctx, dir, false, options); err != nil {
+			_ = os.RemoveAll(dir)
+			return "", gitError(ctx, "clone", c.timeout, err)
+		}
+		return headRevision(dir)
+	}
+
+	c.log.Info("Fetching repository", "repoURL", repoURL, "branch", branch)
+	repo, err := git.PlainOpen(dir)
+	if err != nil {
+		return "", fmt.Errorf("failed to open repository: %v", err)
+	}
+	head, err := repo.Head()
+	if err != nil {
+		return "", fmt.Errorf("failed to get repository head: %v", err)
+	}
+
+	// Fetch the branch that was cloned into its remote-tracking reference
+	localBranch := head.Name().Short()
+	tracking := plumbing.NewRemoteReferenceName("origin", localBranch)
+	refSpec := config.RefSpec(fmt.Sprintf("+%s:%s", head.Name(), tracking))
+	err = repo.FetchContext(ctx, &git.FetchOptions{RemoteName: "origin", RefSpecs: []config.RefSpec{refSpec}, Force: true})
+	if err != nil && err != git.NoErrAlreadyUpToDate {
+		return "", gitError(ctx, "fetch", c.timeout, err)
+	}
+	remoteRef, err := repo.Reference(tracking, true)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve remote branch %s: %v", localBranch, err)
+	}
+	w, err := repo.Worktree()
+	if err != nil {
+		return "", fmt.Errorf("failed to get worktree: %v", err)
+	}
+	if err := w.Reset(&git.ResetOptions{Commit: remoteRef.Hash(), Mode: git.HardReset}); err != nil {
+		return "", fmt.Errorf("failed to check out %s: %v", remoteRef.Hash(), err)
+	}
+	return remoteRef.Hash().String(), nil
+}
+
+// headRevision returns the commit checked out in dir
+func headRevision(dir string) (string, error) {
+	repo, err := git.PlainOpen(dir)
+	if err != nil {
+		return "", fmt.Errorf("failed to open repository: %v", err)
+	}
+	head, err := repo.Head()
+	if err != nil {
+		return "", fmt.Errorf("failed to get repository head: %v", err)
+	}
+	return head.Hash().String(), nil
+}
+
+// gitError describes a failed git operation, reporting a TimeoutError when the timeout expired
+func gitError(ctx context.Context, operation string, timeout time.Duration, err error) error {
+	if ctx.Err() == context.DeadlineExceeded {
+		return fmt.Errorf("failed to %s repository: %w", operation, &command.TimeoutError{Command: "git " + operation, Timeout: timeout, Err: err})
+	}
+	return fmt.Errorf("failed to %s repository: %v", operation, err)
+}
@@ -0,0 +1,188 @@
+package gitcache
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-logr/logr"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Cache", func() {
+	var (
+		ctx     context.Context
+		origin  string
+		repoURL string
+		cache   *Cache
+	)
+
+	// commit writes a file to the origin repository and commits it, returning the new revision
+	commit := func(name, content string) string {
+		repo, err := git.PlainOpen(origin)
+		Expect(err).NotTo(HaveOccurred())
+		w, err := repo.Worktree()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(os.WriteFile(filepath.Join(origin, name), []byte(content), 0o644)).To(Succeed())
+		_, err = w.Add(name)
+		Expect(err).NotTo(HaveOccurred())
+		hash, err := w.Commit("update "+name, &git.CommitOptions{
+			Author: &object.Signature{Name: "test", Email: "test@example.com", When: time.Now()},
+		})
+		Expect(err).NotTo(HaveOccurred())
+		return hash.String()
+	}
+
+	checkout := func(branch string, maxAge time.Duration) *Workspace {
+		workspace, err := cache.Checkout(ctx, repoURL, branch, maxAge)
+		Expect(err).NotTo(HaveOccurred())
+		workspace.Release()
+		return workspace
+	}
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		origin = GinkgoT().TempDir()
+		_, err := git.PlainInitWithOptions(origin, &git.PlainInitOptions{
+			InitOptions: git.InitOptions{DefaultBranch: plumbing.NewBranchReferenceName("main")},
+		})
+		Expect(err).NotTo(HaveOccurred())
+		repoURL = "file://" + origin
+		cache = New(GinkgoT().TempDir(), time.Minute, logr.Discard())
+	})
+
+	It("should clone the default branch on first use", func() {
+		revision := commit("values.yaml", "replicas: 1\n")
+
+		workspace := checkout("", time.Minute)
+
+		Expect(workspace.Revision).To(Equal(revision))
+		Expect(filepath.Join(workspace.Dir, "values.yaml")).To(BeAnExistingFile())
+	})
+
+	It("should not check the remote again within the interval", func() {
+		first := commit("values.yaml", "replicas: 1\n")
+		checkout("", time.Hour)
+
+		commit("values.yaml", "replicas: 2\n")
+
+		Expect(checkout("", time.Hour).Revision).To(Equal(first))
+	})
+
+	It("should fetch the new revision once the interval passed and notify subscribers", func() {
+		commit("values.yaml", "replicas: 1\n")
+		var changes []Change
+		cache.Subscribe(func(change Change) { changes = append(changes, change) })
+		checkout("", 0)
+		Expect(changes).To(BeEmpty())
+
+		second := commit("values.yaml", "replicas: 2\n")
+		workspace := checkout("", 0)
+
+		Expect(workspace.Revision).To(Equal(second))
+		content, err := os.ReadFile(filepath.Join(workspace.Dir, "values.yaml"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(content)).To(Equal("replicas: 2\n"))
+		Expect(changes).To(ConsistOf(Change{URL: repoURL, Revision: second}))
+
+		By("not notifying again while the remote did not move")
+		checkout("", 0)
+		Expect(changes).To(HaveLen(1))
+	})
+
+	It("should keep separate checkouts per branch", func() {
+		main := commit("values.yaml", "replicas: 1\n")
+		repo, err := git.PlainOpen(origin)
+		Expect(err).NotTo(HaveOccurred())
+		w, err := repo.Worktree()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(w.Checkout(&git.CheckoutOptions{Branch: plumbing.NewBranchReferenceName("release"), Create: true})).To(Succeed())
+		release := commit("values.yaml", "replicas: 3\n")
+
+		Expect(checkout("main", time.Minute).Revision).To(Equal(main))
+		Expect(checkout("release", time.Minute).Revision).To(Equal(release))
+		Expect(cache.Dir(repoURL, "main")).NotTo(Equal(cache.Dir(repoURL, "release")))
+	})
+
+	It("should fetch new revisions while workspaces of the previous one are read", func() {
+		first := commit("values.yaml", "replicas: 1\n")
+		held, err := cache.Checkout(ctx, repoURL, "", 0)
+		Expect(err).NotTo(HaveOccurred())
+
+		second := commit("values.yaml", "replicas: 2\n")
+		workspace := checkout("", 0)
+
+		Expect(workspace.Revision).To(Equal(second))
+		Expect(workspace.Dir).NotTo(Equal(held.Dir))
+		content, err := os.ReadFile(filepath.Join(held.Dir, "values.yaml"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(content)).To(Equal("replicas: 1\n"))
+		Expect(held.Revision).To(Equal(first))
+
+		By("removing the replaced worktree once it is released")
+		held.Release()
+		Eventually(func() string { return held.Dir }).ShouldNot(BeADirectory())
+		Expect(filepath.Join(workspace.Dir, "values.yaml")).To(BeAnExistingFile())
+	})
+
+	It("should check the remote again once expired", func() {
+		commit("values.yaml", "replicas: 1\n")
+		checkout("", time.Hour)
+		second := commit("values.yaml", "replicas: 2\n")
+
+		cache.Expire(repoURL, "")
+
+		Expect(checkout("", time.Hour).Revision).To(Equal(second))
+	})
+
+	It("should report a missing branch", func() {
+		commit("values.yaml", "replicas: 1\n")
+
+		_, err := cache.Checkout(ctx, repoURL, "missing", time.Minute)
+
+		Expect(err).To(MatchError(ContainSubstring("refs/heads/missing not found")))
+	})
+
+	It("should fetch each repository once for concurrent callers", func() {
+		revision := commit("values.yaml", "replicas: 1\n")
+
+		var wg sync.WaitGroup
+		revisions := make([]string, 10)
+		for i := range revisions {
+			wg.Add(1)
+			go func(i int) {
+				defer GinkgoRecover()
+				defer wg.Done()
+				workspace, err := cache.Checkout(ctx, repoURL, "", time.Minute)
+				Expect(err).NotTo(HaveOccurred())
+				revisions[i] = workspace.Revision
+				workspace.Release()
+			}(i)
+		}
+		wg.Wait()
+
+		for _, r := range revisions {
+			Expect(r).To(Equal(revision))
+		}
+	})
+})
+
+var _ = DescribeTable("SameRepository",
+	func(a, b string, same bool) {
+		Expect(SameRepository(a, b)).To(Equal(same))
+	},
+	Entry("identical URLs", "https://github.com/acme/gitops.git", "https://github.com/acme/gitops.git", true),
+	Entry("with and without .git", "https://github.com/acme/gitops.git", "https://github.com/acme/gitops", true),
+	Entry("HTTPS and SCP-like SSH", "https://github.com/acme/gitops", "git@github.com:acme/gitops.git", true),
+	Entry("HTTPS and SSH with port", "https://github.com/acme/gitops", "ssh://git@github.com:22/acme/gitops.git", true),
+	Entry("credentials and host case", "https://token@GitHub.com/acme/gitops/", "https://github.com/acme/gitops", true),
+	Entry("different repositories", "https://github.com/acme/gitops", "https://github.com/acme/other", false),
+	Entry("different hosts", "https://github.com/acme/gitops", "https://gitlab.com/acme/gitops", false),
+)
@@ -0,0 +1,14 @@
+package gitcache
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func TestGitCache(t *testing.T) {
+	RegisterFailHandler(Fail)
+
+	RunSpecs(t, "Git Cache Suite")
+}
@@ -0,0 +1,107 @@
+package locks
+
+import (
+	"context"
+	"sync"
+)
+
+// KeyedRWMutex is a set of read-write locks identified by key, e.g. one per Git workspace.
+// Locks are created on first use and dropped once nobody holds or waits for them.
+// A nil KeyedRWMutex does not lock at all.
+type KeyedRWMutex struct {
+	mu    sync.Mutex
+	locks map[string]*keyedLock
+}
+
+type keyedLock struct {
+	sync.RWMutex
+	refs int
+}
+
+// NewKeyedRWMutex returns an empty KeyedRWMutex
+func NewKeyedRWMutex() *KeyedRWMutex {
+	return &KeyedRWMutex{locks: map[string]*keyedLock{}}
+}
+
+// Lock locks key for writing and returns the function that unlocks it
+func (k *KeyedRWMutex) Lock(key string) (unlock func()) {
+	if k == nil {
+		return func() {}
+	}
+	l := k.acquire(key)
+	l.Lock()
+	return func() {
+		l.Unlock()
+		k.release(key)
+	}
+}
+
+// RLock locks key for reading and returns the function that unlocks it
+func (k *KeyedRWMutex) RLock(key string) (unlock func()) {
+	if k == nil {
+		return func() {}
+	}
+	l := k.acquire(key)
+	l.RLock()
+	return func() {
+		l.RUnlock()
+		k.release(key)
+	}
+}
+
+func (k *KeyedRWMutex) acquire(key string) *keyedLock {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	l, ok := k.locks[key]
+	if !ok {
+		l = &keyedLock{}
+		k.locks[key] = l
+	}
+	l.refs++
+	return l
+}
+
+func (k *KeyedRWMutex) release(key string) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	l := k.locks[key]
+	l.refs--
+	if l.refs == 0 {
+		delete(k.locks, key)
+	}
+}
+
+// Len returns the number of keys currently held or waited for
+func (k *KeyedRWMutex) Len() int {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	return len(k.locks)
+}
+
+// Semaphore limits how many callers run an operation at the same time.
+// A nil Semaphore does not limit at all.
+type Semaphore struct {
+	slots chan struct{}
+}
+
+// NewSemaphore returns a Semaphore with n slots, or nil for no limit when n is not positive
+func NewSemaphore(n int) *Semaphore {
+	if n <= 0 {
+		return nil
+	}
+	return &Semaphore{slots: make(chan struct{}, n)}
+}
+
+// Acquire waits for a free slot or for ctx to be done, and returns the function that frees the slot
+func (s *Semaphore) Acquire(ctx context.Context) (release func(), err error) {
+	if s == nil {
+		return func() {}, nil
+	}
+	select {
+	case s.slots <- struct{}{}:
+		var once sync.Once
+		return func() { once.Do(func() { <-s.slots }) }, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
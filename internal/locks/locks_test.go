@@ -0,0 +1,107 @@
+package locks
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("KeyedRWMutex", func() {
+	It("should serialize writers of the same key only", func() {
+		k := NewKeyedRWMutex()
+		unlockA := k.Lock("a")
+
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			k.Lock("b")()
+		}()
+		Eventually(done).Should(BeClosed())
+
+		blocked := make(chan struct{})
+		go func() {
+			defer close(blocked)
+			k.Lock("a")()
+		}()
+		Consistently(blocked, 100*time.Millisecond).ShouldNot(BeClosed())
+
+		unlockA()
+		Eventually(blocked).Should(BeClosed())
+		Expect(k.Len()).To(Equal(0))
+	})
+
+	It("should let readers of a key share it", func() {
+		k := NewKeyedRWMutex()
+		unlock1 := k.RLock("repo")
+		unlock2 := k.RLock("repo")
+
+		writer := make(chan struct{})
+		go func() {
+			defer close(writer)
+			k.Lock("repo")()
+		}()
+		Consistently(writer, 100*time.Millisecond).ShouldNot(BeClosed())
+
+		unlock1()
+		unlock2()
+		Eventually(writer).Should(BeClosed())
+	})
+
+	It("should not lock when nil", func() {
+		var k *KeyedRWMutex
+		k.Lock("a")()
+		k.RLock("a")()
+	})
+})
+
+var _ = Describe("Semaphore", func() {
+	It("should limit concurrent holders", func() {
+		s := NewSemaphore(2)
+		var running, peak int32
+		var wg sync.WaitGroup
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				release, err := s.Acquire(context.Background())
+				Expect(err).NotTo(HaveOccurred())
+				defer release()
+
+				n := atomic.AddInt32(&running, 1)
+				for {
+					p := atomic.LoadInt32(&peak)
+					if n <= p || atomic.CompareAndSwapInt32(&peak, p, n) {
+						break
+					}
+				}
+				time.Sleep(10 * time.Millisecond)
+				atomic.AddInt32(&running, -1)
+			}()
+		}
+		wg.Wait()
+		Expect(peak).To(BeNumerically("<=", 2))
+	})
+
+	It("should stop waiting when the context is done", func() {
+		s := NewSemaphore(1)
+		release, err := s.Acquire(context.Background())
+		Expect(err).NotTo(HaveOccurred())
+		defer release()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		_, err = s.Acquire(ctx)
+		Expect(err).To(MatchError(context.DeadlineExceeded))
+	})
+
+	It("should not limit when created without slots", func() {
+		Expect(NewSemaphore(0)).To(BeNil())
+		release, err := NewSemaphore(0).Acquire(context.Background())
+		Expect(err).NotTo(HaveOccurred())
+		release()
+	})
+})
@@ -0,0 +1,14 @@
+package locks
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func TestLocks(t *testing.T) {
+	RegisterFailHandler(Fail)
+
+	RunSpecs(t, "Locks Suite")
+}
@@ -0,0 +1,163 @@
+package metrics
+
+import (
+	"net/url"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	ctrlmetrics "sigs.k8s.io/controller-runtime/pkg/metrics"
+
+	pulseprov1alpha1 "github.com/smarter-contracts/pulsepro-operator/api/v1alpha1"
+)
+
+const (
+	// ResultSuccess labels an operation that succeeded
+	ResultSuccess = "success"
+
+	// ResultFailure labels an operation that failed
+	ResultFailure = "failure"
+)
+
+var (
+	// GitSyncDuration observes how long cloning or pulling a GitOps repository takes
+	GitSyncDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "pulsepro_git_sync_duration_seconds",
+		Help:    "Duration of GitOps repository syncs.",
+		Buckets: prometheus.ExponentialBuckets(0.25, 2, 10),
+	}, []string{"repo"})
+
+	// GitSyncFailures counts failed GitOps repository syncs
+	GitSyncFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "pulsepro_git_sync_failures_total",
+		Help: "Number of failed GitOps repository syncs.",
+	}, []string{"repo"})
+
+	// HelmfileSyncDuration observes how long helmfile syncs take and whether they succeeded
+	HelmfileSyncDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "pulsepro_helmfile_sync_duration_seconds",
+		Help:    "Duration of helmfile syncs per deployment.",
+		Buckets: prometheus.ExponentialBuckets(1, 2, 10),
+	}, []string{"namespace", "deployment", "result"})
+
+	// DependencyCheckDuration observes the latency and result of the connectivity checks of external services
+	DependencyCheckDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "pulsepro_dependency_check_duration_seconds",
+		Help:    "Duration of connectivity checks of the external services a deployment depends on.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"service", "result"})
+
+	// DeploymentInfo is 1 for every PulseProDeployment, labelled with its version, category and environment
+	DeploymentInfo = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "pulsepro_deployment_info",
+		Help: "Information about each PulseProDeployment; the value is always 1.",
+	}, []string{"namespace", "deployment", "version", "category", "environment", "status"})
+
+	// RolloutPhase is 1 for the current phase of every PulseProRollout
+	RolloutPhase = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "pulsepro_rollout_phase",
+		Help: "Current phase of each PulseProRollout; the value is 1 for the current phase.",
+	}, []string{"namespace", "rollout", "phase"})
+
+	// RolloutTargets counts the deployments of every PulseProRollout by state
+	RolloutTargets = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "pulsepro_rollout_targets",
+		Help: "Number of deployments of each PulseProRollout that are updated, reverted, deferred or waiting for approval.",
+	}, []string{"namespace", "rollout", "state"})
+)
+
+func init() {
+	ctrlmetrics.Registry.MustRegister(
+		GitSyncDuration,
+		GitSyncFailures,
+		HelmfileSyncDuration,
+		DependencyCheckDuration,
+		DeploymentInfo,
+		RolloutPhase,
+		RolloutTargets,
+	)
+}
+
+// Result returns the result label for an error
+func Result(err error) string {
+	if err != nil {
+		return ResultFailure
+	}
+	return ResultSuccess
+}
+
+// ObserveGitSync records the duration of a GitOps repository sync and counts it if it failed
+func ObserveGitSync(repoURL string, start time.Time, err error) {
+	repo := RepoLabel(repoURL)
+	GitSyncDuration.WithLabelValues(repo).Observe(time.Since(start).Seconds())
+	if err != nil {
+		GitSyncFailures.WithLabelValues(repo).Inc()
+	}
+}
+
+// ObserveHelmfileSync records the duration and result of a helmfile sync of a deployment
+func ObserveHelmfileSync(namespace, deployment string, start time.Time, err error) {
+	HelmfileSyncDuration.WithLabelValues(namespace, deployment, Result(err)).Observe(time.Since(start).Seconds())
+}
+
+// ObserveDependencyCheck records the latency and result of a connectivity check of an external service
+func ObserveDependencyCheck(service string, start time.Time, err error) {
+	DependencyCheckDuration.WithLabelValues(service, Result(err)).Observe(time.Since(start).Seconds())
+}
+
+// RecordDeployment replaces the info series of a deployment with its current version, category, environment and status
+func RecordDeployment(deployment *pulseprov1alpha1.PulseProDeployment) {
+	ForgetDeployment(deployment.Namespace, deployment.Name)
+	DeploymentInfo.WithLabelValues(
+		deployment.Namespace,
+		deployment.Name,
+		deployment.Status.CurrentVersion,
+		deployment.Spec.Category,
+		deployment.Spec.EnvironmentName,
+		deployment.Status.Status,
+	).Set(1)
+}
+
+// ForgetDeployment removes the series of a deleted deployment
+func ForgetDeployment(namespace, name string) {
+	DeploymentInfo.DeletePartialMatch(prometheus.Labels{"namespace": namespace, "deployment": name})
+}
+
+// RecordRollout replaces the progress series of a rollout with its current status
+func RecordRollout(rollout *pulseprov1alpha1.PulseProRollout) {
+	ForgetRollout(rollout.Namespace, rollout.Name)
+	if rollout.Status.Phase != "" {
+		RolloutPhase.WithLabelValues(rollout.Namespace, rollout.Name, rollout.Status.Phase).Set(1)
+	}
+
+	updated, reverted := 0, 0
+	for _, target := range rollout.Status.UpdatedTargets {
+		if target.Reverted {
+			reverted++
+		} else {
+			updated++
+		}
+	}
+	RolloutTargets.WithLabelValues(rollout.Namespace, rollout.Name, "updated").Set(float64(updated))
+	RolloutTargets.WithLabelValues(rollout.Namespace, rollout.Name, "reverted").Set(float64(reverted))
+	RolloutTargets.WithLabelValues(rollout.Namespace, rollout.Name, "deferred").Set(float64(len(rollout.Status.DeferredTargets)))
+	RolloutTargets.WithLabelValues(rollout.Namespace, rollout.Name, "awaitingApproval").Set(float64(len(rollout.Status.PendingGates)))
+}
+
+// ForgetRollout removes the series of a deleted rollout
+func ForgetRollout(namespace, name string) {
+	labels := prometheus.Labels{"namespace": namespace, "rollout": name}
+	RolloutPhase.DeletePartialMatch(labels)
+	RolloutTargets.DeletePartialMatch(labels)
+}
+
+// RepoLabel strips credentials, query and fragment from a repository URL so it can be used as a label
+func RepoLabel(repoURL string) string {
+	u, err := url.Parse(repoURL)
+	if err != nil || u.Host == "" {
+		return repoURL
+	}
+	u.User = nil
+	u.RawQuery = ""
+	u.Fragment = ""
+	return u.String()
+}
@@ -0,0 +1,91 @@
+package plan
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+
+	pulseprov1alpha1 "github.com/smarter-contracts/pulsepro-operator/api/v1alpha1"
+)
+
+// Name returns the name of the plan for a deployment spec generation, Git revision and the rest of the desired
+// state, such as the hash of the merged values and the resolved versions, e.g. "acme-prod-4-1a2b3c4-9f86d081"
+func Name(deployment string, generation int64, revision string, state ...string) string {
+	if len(revision) > 7 {
+		revision = revision[:7]
+	}
+	sum := sha256.Sum256([]byte(strings.Join(state, "\x00")))
+	return fmt.Sprintf("%s-%d-%s-%s", deployment, generation, revision, hex.EncodeToString(sum[:4]))
+}
+
+// ValuesDelta compares two Helm values documents and lists the values that were added, changed or removed
+func ValuesDelta(previous, current string) ([]pulseprov1alpha1.ValueChange, error) {
+	before, err := flatten(previous)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse previous values: %v", err)
+	}
+	after, err := flatten(current)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse current values: %v", err)
+	}
+
+	var delta []pulseprov1alpha1.ValueChange
+	for key, value := range after {
+		old, ok := before[key]
+		switch {
+		case !ok:
+			delta = append(delta, pulseprov1alpha1.ValueChange{Key: key, Change: "Add", Current: value})
+		case old != value:
+			delta = append(delta, pulseprov1alpha1.ValueChange{Key: key, Change: "Change", Previous: old, Current: value})
+		}
+	}
+	for key, value := range before {
+		if _, ok := after[key]; !ok {
+			delta = append(delta, pulseprov1alpha1.ValueChange{Key: key, Change: "Remove", Previous: value})
+		}
+	}
+
+	sort.Slice(delta, func(i, j int) bool { return delta[i].Key < delta[j].Key })
+	return delta, nil
+}
+
+// flatten parses a YAML document into a map of dotted keys to scalar values; lists are kept as one value
+func flatten(document string) (map[string]string, error) {
+	var root map[interface{}]interface{}
+	if err := yaml.Unmarshal([]byte(document), &root); err != nil {
+		return nil, err
+	}
+
+	values := map[string]string{}
+	var walk func(prefix string, node interface{})
+	walk = func(prefix string, node interface{}) {
+		mapping, ok := node.(map[interface{}]interface{})
+		if !ok {
+			if node == nil {
+				values[prefix] = ""
+				return
+			}
+			if _, isList := node.([]interface{}); isList {
+				out, _ := yaml.Marshal(node)
+				values[prefix] = string(out)
+				return
+			}
+			values[prefix] = fmt.Sprint(node)
+			return
+		}
+		for key, child := range mapping {
+			name := fmt.Sprint(key)
+			if prefix != "" {
+				name = prefix + "." + name
+			}
+			walk(name, child)
+		}
+	}
+	walk("", root)
+	delete(values, "")
+	return values, nil
+}
@@ -0,0 +1,56 @@
+package plan
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	pulseprov1alpha1 "github.com/smarter-contracts/pulsepro-operator/api/v1alpha1"
+)
+
+var _ = Describe("Plan", func() {
+	It("should name plans after the deployment, generation, short revision and desired state", func() {
+		Expect(Name("acme-prod", 4, "1a2b3c4d5e6f", "values", "2.3.0")).To(HavePrefix("acme-prod-4-1a2b3c4-"))
+		Expect(Name("acme-prod", 1, "abc")).To(MatchRegexp(`^acme-prod-1-abc-[0-9a-f]{8}$`))
+
+		Expect(Name("acme-prod", 4, "1a2b3c4", "values", "2.3.0")).To(Equal(Name("acme-prod", 4, "1a2b3c4", "values", "2.3.0")))
+		Expect(Name("acme-prod", 4, "1a2b3c4", "values", "2.3.0")).NotTo(Equal(Name("acme-prod", 4, "1a2b3c4", "values", "2.3.1")))
+		Expect(Name("acme-prod", 4, "1a2b3c4", "a", "bc")).NotTo(Equal(Name("acme-prod", 4, "1a2b3c4", "ab", "c")))
+	})
+
+	It("should list added, changed and removed values by dotted key", func() {
+		previous := `
+vault:
+  address: https://vault.old
+midtier:
+  host: midtier.local
+rabbitmq:
+  host: rabbit
+`
+		current := `
+vault:
+  address: https://vault.new
+midtier:
+  host: midtier.local
+postgres:
+  postgres: db.local
+`
+		delta, err := ValuesDelta(previous, current)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(delta).To(Equal([]pulseprov1alpha1.ValueChange{
+			{Key: "postgres.postgres", Change: "Add", Current: "db.local"},
+			{Key: "rabbitmq.host", Change: "Remove", Previous: "rabbit"},
+			{Key: "vault.address", Change: "Change", Previous: "https://vault.old", Current: "https://vault.new"},
+		}))
+	})
+
+	It("should treat every value as added when there is no previous plan", func() {
+		delta, err := ValuesDelta("", "replicas: 3\n")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(delta).To(Equal([]pulseprov1alpha1.ValueChange{{Key: "replicas", Change: "Add", Current: "3"}}))
+	})
+
+	It("should fail on invalid YAML", func() {
+		_, err := ValuesDelta("", "a: [")
+		Expect(err).To(HaveOccurred())
+	})
+})
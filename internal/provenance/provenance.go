@@ -0,0 +1,145 @@
+package provenance
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+)
+
+// Result describes a verified chart
+type Result struct {
+	// Digest is the digest of the verified chart archive or OCI manifest, e.g. "sha256:..."
+	Digest string
+	// Signer describes the key that signed the chart
+	Signer string
+}
+
+// sortedKeys returns the keys of the values of a Secret in order, so that errors are reproducible
+func sortedKeys(data map[string][]byte) []string {
+	names := make([]string, 0, len(data))
+	for name := range data {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// WriteKeyring writes the GPG public keys of the values of a Secret as a binary keyring, the format
+// `helm --keyring` reads. A value is either armored or binary public keys.
+func WriteKeyring(path string, data map[string][]byte) error {
+	var entities openpgp.EntityList
+	for _, name := range sortedKeys(data) {
+		value := data[name]
+		var keys openpgp.EntityList
+		var err error
+		if bytes.Contains(value, []byte("-----BEGIN PGP PUBLIC KEY BLOCK-----")) {
+			keys, err = openpgp.ReadArmoredKeyRing(bytes.NewReader(value))
+		} else {
+			keys, err = openpgp.ReadKeyRing(bytes.NewReader(value))
+		}
+		if err != nil {
+			return fmt.Errorf("invalid GPG keys in %s: %v", name, err)
+		}
+		entities = append(entities, keys...)
+	}
+	if len(entities) == 0 {
+		return fmt.Errorf("no GPG keys found")
+	}
+
+	var keyring bytes.Buffer
+	for _, entity := range entities {
+		if err := entity.Serialize(&keyring); err != nil {
+			return fmt.Errorf("failed to serialize GPG key %X: %v", entity.PrimaryKey.Fingerprint, err)
+		}
+	}
+	return os.WriteFile(path, keyring.Bytes(), 0o600)
+}
+
+// CosignKeys returns the PEM public keys of the values of a Secret in order
+func CosignKeys(data map[string][]byte) ([][]byte, error) {
+	var keys [][]byte
+	for _, name := range sortedKeys(data) {
+		if !bytes.Contains(data[name], []byte("-----BEGIN PUBLIC KEY-----")) {
+			continue
+		}
+		keys = append(keys, data[name])
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("no cosign public keys found")
+	}
+	return keys, nil
+}
+
+// ParseHelmVerification reads the output of `helm pull --verify`
+func ParseHelmVerification(output string) (Result, error) {
+	var result Result
+	var fingerprint string
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if value, ok := strings.CutPrefix(line, "Signed by:"); ok {
+			result.Signer = strings.TrimSpace(value)
+		} else if value, ok := strings.CutPrefix(line, "Using Key With Fingerprint:"); ok {
+			fingerprint = strings.TrimSpace(value)
+		} else if value, ok := strings.CutPrefix(line, "Chart Hash Verified:"); ok {
+			result.Digest = strings.TrimSpace(value)
+		}
+	}
+	if result.Digest == "" {
+		return Result{}, fmt.Errorf("helm did not report a verified chart hash")
+	}
+	if fingerprint != "" {
+		result.Signer = strings.TrimSpace(fmt.Sprintf("GPG key %s %s", fingerprint, result.Signer))
+	}
+	return result, nil
+}
+
+// ParsePullDigest reads the manifest digest of an OCI chart from the output of `helm pull`
+func ParsePullDigest(output string) (string, error) {
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	for scanner.Scan() {
+		if value, ok := strings.CutPrefix(strings.TrimSpace(scanner.Text()), "Digest:"); ok {
+			return strings.TrimSpace(value), nil
+		}
+	}
+	return "", fmt.Errorf("helm did not report the digest of the pulled chart")
+}
+
+// CosignReference returns the image reference of an OCI chart such as "oci://registry.example.com/charts/app"
+// at version, which cosign verifies. Like helm, it replaces the "+" of a version with "_" in the tag.
+func CosignReference(chart, version string) (string, error) {
+	repository, ok := strings.CutPrefix(chart, "oci://")
+	if !ok {
+		return "", fmt.Errorf("cosign signatures are only supported for OCI charts, not %s", chart)
+	}
+	if version == "" {
+		return "", fmt.Errorf("cosign verification of %s needs a chart version", chart)
+	}
+	return strings.TrimSuffix(repository, "/") + ":" + strings.ReplaceAll(version, "+", "_"), nil
+}
+
+// ParseCosignVerification reads the JSON output of `cosign verify`, which lists the verified signatures
+func ParseCosignVerification(output []byte) (Result, error) {
+	var signatures []struct {
+		Critical struct {
+			Image struct {
+				DockerManifestDigest string `json:"docker-manifest-digest"`
+			} `json:"image"`
+		} `json:"critical"`
+	}
+	if err := json.Unmarshal(output, &signatures); err != nil {
+		return Result{}, fmt.Errorf("invalid cosign output: %v", err)
+	}
+	for _, signature := range signatures {
+		if digest := signature.Critical.Image.DockerManifestDigest; digest != "" {
+			return Result{Digest: digest}, nil
+		}
+	}
+	return Result{}, fmt.Errorf("cosign did not report a verified digest")
+}
@@ -0,0 +1,143 @@
+package provenance
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/armor"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func newGPGEntity(name string) *openpgp.Entity {
+	entity, err := openpgp.NewEntity(name, "", name+"@example.com", nil)
+	Expect(err).NotTo(HaveOccurred())
+	return entity
+}
+
+func publicKey(entity *openpgp.Entity, armored bool) []byte {
+	buf := &bytes.Buffer{}
+	if !armored {
+		Expect(entity.Serialize(buf)).To(Succeed())
+		return buf.Bytes()
+	}
+	w, err := armor.Encode(buf, openpgp.PublicKeyType, nil)
+	Expect(err).NotTo(HaveOccurred())
+	Expect(entity.Serialize(w)).To(Succeed())
+	Expect(w.Close()).To(Succeed())
+	return buf.Bytes()
+}
+
+var _ = Describe("WriteKeyring", func() {
+	It("writes armored and binary keys as one binary keyring", func() {
+		alice, bob := newGPGEntity("alice"), newGPGEntity("bob")
+		path := filepath.Join(GinkgoT().TempDir(), "keyring.gpg")
+
+		Expect(WriteKeyring(path, map[string][]byte{
+			"alice.asc": publicKey(alice, true),
+			"bob.gpg":   publicKey(bob, false),
+		})).To(Succeed())
+
+		data, err := os.ReadFile(path)
+		Expect(err).NotTo(HaveOccurred())
+		keyring, err := openpgp.ReadKeyRing(bytes.NewReader(data))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(keyring).To(HaveLen(2))
+		Expect(keyring[0].PrimaryKey.Fingerprint).To(Equal(alice.PrimaryKey.Fingerprint))
+		Expect(keyring[1].PrimaryKey.Fingerprint).To(Equal(bob.PrimaryKey.Fingerprint))
+		Expect(keyring[0].PrivateKey).To(BeNil())
+	})
+
+	It("rejects invalid keys and empty Secrets", func() {
+		path := filepath.Join(GinkgoT().TempDir(), "keyring.gpg")
+		Expect(WriteKeyring(path, map[string][]byte{"key": []byte("not a key")})).To(MatchError(ContainSubstring("invalid GPG keys in key")))
+		Expect(WriteKeyring(path, map[string][]byte{})).To(MatchError("no GPG keys found"))
+	})
+})
+
+var _ = Describe("CosignKeys", func() {
+	It("returns the PEM public keys in order", func() {
+		keys, err := CosignKeys(map[string][]byte{
+			"b.pub":     []byte("-----BEGIN PUBLIC KEY-----\nb\n-----END PUBLIC KEY-----\n"),
+			"a.pub":     []byte("-----BEGIN PUBLIC KEY-----\na\n-----END PUBLIC KEY-----\n"),
+			"README.md": []byte("not a key"),
+		})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(keys).To(HaveLen(2))
+		Expect(string(keys[0])).To(ContainSubstring("\na\n"))
+	})
+
+	It("fails without public keys", func() {
+		_, err := CosignKeys(map[string][]byte{"key": []byte("not a key")})
+		Expect(err).To(MatchError("no cosign public keys found"))
+	})
+})
+
+var _ = Describe("ParseHelmVerification", func() {
+	It("reads the signer and the chart hash", func() {
+		result, err := ParseHelmVerification(`Pulled: registry.example.com/charts/app:1.2.3
+Digest: sha256:1111
+Signed by: Release Bot <release@example.com>
+Using Key With Fingerprint: 5E615389B53CA37F0EE60BD3843BBF981FC18762
+Chart Hash Verified: sha256:e7c0f6a1
+`)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result.Digest).To(Equal("sha256:e7c0f6a1"))
+		Expect(result.Signer).To(Equal("GPG key 5E615389B53CA37F0EE60BD3843BBF981FC18762 Release Bot <release@example.com>"))
+	})
+
+	It("fails when the chart hash was not verified", func() {
+		_, err := ParseHelmVerification("Pulled: registry.example.com/charts/app:1.2.3\n")
+		Expect(err).To(HaveOccurred())
+	})
+})
+
+var _ = Describe("ParsePullDigest", func() {
+	It("reads the digest of the pulled OCI chart", func() {
+		digest, err := ParsePullDigest("Pulled: registry.example.com/charts/pulse-pro:1.0.0\nDigest: sha256:abcd\n")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(digest).To(Equal("sha256:abcd"))
+	})
+
+	It("fails on output without a digest", func() {
+		_, err := ParsePullDigest("Pulled: registry.example.com/charts/pulse-pro:1.0.0\n")
+		Expect(err).To(HaveOccurred())
+	})
+})
+
+var _ = Describe("CosignReference", func() {
+	It("maps an OCI chart and version to an image reference", func() {
+		ref, err := CosignReference("oci://localhost:5000/charts/app", "1.2.3+build.4")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ref).To(Equal("localhost:5000/charts/app:1.2.3_build.4"))
+	})
+
+	It("rejects charts that are not in an OCI registry", func() {
+		_, err := CosignReference("pulsepro/app", "1.2.3")
+		Expect(err).To(MatchError(ContainSubstring("only supported for OCI charts")))
+	})
+
+	It("needs a version", func() {
+		_, err := CosignReference("oci://localhost:5000/charts/app", "")
+		Expect(err).To(HaveOccurred())
+	})
+})
+
+var _ = Describe("ParseCosignVerification", func() {
+	It("reads the verified manifest digest", func() {
+		result, err := ParseCosignVerification([]byte(`[{"critical":{"identity":{"docker-reference":"localhost:5000/charts/app"},` +
+			`"image":{"docker-manifest-digest":"sha256:abcd"},"type":"cosign container image signature"},"optional":null}]`))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result.Digest).To(Equal("sha256:abcd"))
+	})
+
+	It("fails on output without a digest", func() {
+		_, err := ParseCosignVerification([]byte(`[]`))
+		Expect(err).To(MatchError("cosign did not report a verified digest"))
+		_, err = ParseCosignVerification([]byte(`Error: no signatures found`))
+		Expect(err).To(HaveOccurred())
+	})
+})
@@ -0,0 +1,217 @@
+package receiver
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+)
+
+// maxPayloadSize is the largest push payload accepted, matching GitHub's limit
+const maxPayloadSize = 25 << 20
+
+// Push is a push to a branch of a Git repository
+type Push struct {
+	// URLs are the URLs the repository is known by, e.g. its HTTPS and SSH clone URLs
+	URLs []string
+	// Branch is the branch that was pushed to
+	Branch string
+	// DefaultBranch is the default branch of the repository, when the payload reports it
+	DefaultBranch string
+	// Revision is the commit the branch points to after the push
+	Revision string
+}
+
+// Receiver accepts GitHub, GitLab and Gitea push webhooks and passes the verified pushes to OnPush.
+// GitHub and Gitea payloads must carry an HMAC-SHA256 signature made with Secret; GitLab, which does
+// not sign its payloads, must send Secret as its token.
+type Receiver struct {
+	Secret []byte
+	Log    logr.Logger
+	// OnPush handles a push and returns the number of deployments it triggered
+	OnPush func(ctx context.Context, push Push) (int, error)
+}
+
+// errUnauthorized is returned for payloads whose signature or token does not match the secret
+var errUnauthorized = errors.New("invalid signature")
+
+// ServeHTTP implements http.Handler
+func (r *Receiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	body, err := io.ReadAll(io.LimitReader(req.Body, maxPayloadSize+1))
+	if err != nil {
+		http.Error(w, "failed to read payload", http.StatusBadRequest)
+		return
+	}
+	if len(body) > maxPayloadSize {
+		http.Error(w, "payload too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	push, isPush, err := r.parse(req.Header, body)
+	if err == errUnauthorized {
+		r.Log.Info("Rejected webhook with an invalid signature", "remoteAddr", req.RemoteAddr)
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if !isPush {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	count, err := r.OnPush(req.Context(), push)
+	if err != nil {
+		r.Log.Error(err, "Failed to handle push", "branch", push.Branch, "revision", push.Revision)
+		http.Error(w, "failed to handle push", http.StatusInternalServerError)
+		return
+	}
+	r.Log.Info("Received push", "urls", push.URLs, "branch", push.Branch, "revision", push.Revision, "deployments", count)
+	w.WriteHeader(http.StatusAccepted)
+	fmt.Fprintf(w, "triggered %d deployments\n", count)
+}
+
+// parse verifies the payload and decodes it. It reports false for verified events that are not pushes.
+func (r *Receiver) parse(header http.Header, body []byte) (Push, bool, error) {
+	switch {
+	case header.Get("X-GitHub-Event") != "":
+		if !validSignature(r.Secret, body, strings.TrimPrefix(header.Get("X-Hub-Signature-256"), "sha256=")) {
+			return Push{}, false, errUnauthorized
+		}
+		if header.Get("X-GitHub-Event") != "push" {
+			return Push{}, false, nil
+		}
+		return parseGitHubPush(body)
+	case header.Get("X-Gitea-Event") != "":
+		if !validSignature(r.Secret, body, header.Get("X-Gitea-Signature")) {
+			return Push{}, false, errUnauthorized
+		}
+		if header.Get("X-Gitea-Event") != "push" {
+			return Push{}, false, nil
+		}
+		return parseGitHubPush(body)
+	case header.Get("X-Gitlab-Event") != "":
+		if len(r.Secret) == 0 || subtle.ConstantTimeCompare([]byte(header.Get("X-Gitlab-Token")), r.Secret) != 1 {
+			return Push{}, false, errUnauthorized
+		}
+		if header.Get("X-Gitlab-Event") != "Push Hook" {
+			return Push{}, false, nil
+		}
+		return parseGitLabPush(body)
+	default:
+		return Push{}, false, fmt.Errorf("unsupported webhook: no GitHub, GitLab or Gitea event header")
+	}
+}
+
+// validSignature reports whether signature is the hex HMAC-SHA256 of body with secret
+func validSignature(secret, body []byte, signature string) bool {
+	if len(secret) == 0 {
+		return false
+	}
+	expected, err := hex.DecodeString(signature)
+	if err != nil {
+		return false
+	}
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(body)
+	return hmac.Equal(mac.Sum(nil), expected)
+}
+
+// parseGitHubPush decodes a GitHub push payload, which Gitea also sends
+func parseGitHubPush(body []byte) (Push, bool, error) {
+	var payload struct {
+		Ref        string `json:"ref"`
+		After      string `json:"after"`
+		Repository struct {
+			CloneURL      string `json:"clone_url"`
+			SSHURL        string `json:"ssh_url"`
+			HTMLURL       string `json:"html_url"`
+			DefaultBranch string `json:"default_branch"`
+		} `json:"repository"`
+	}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return Push{}, false, fmt.Errorf("invalid push payload: %v", err)
+	}
+	return newPush(payload.Ref, payload.After, payload.Repository.DefaultBranch,
+		payload.Repository.CloneURL, payload.Repository.SSHURL, payload.Repository.HTMLURL)
+}
+
+// parseGitLabPush decodes a GitLab push payload
+func parseGitLabPush(body []byte) (Push, bool, error) {
+	var payload struct {
+		Ref     string `json:"ref"`
+		After   string `json:"after"`
+		Project struct {
+			GitHTTPURL    string `json:"git_http_url"`
+			GitSSHURL     string `json:"git_ssh_url"`
+			WebURL        string `json:"web_url"`
+			DefaultBranch string `json:"default_branch"`
+		} `json:"project"`
+	}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return Push{}, false, fmt.Errorf("invalid push payload: %v", err)
+	}
+	return newPush(payload.Ref, payload.After, payload.Project.DefaultBranch,
+		payload.Project.GitHTTPURL, payload.Project.GitSSHURL, payload.Project.WebURL)
+}
+
+// newPush builds a Push to a branch; pushes of tags are reported as other events
+func newPush(ref, revision, defaultBranch string, urls ...string) (Push, bool, error) {
+	branch, ok := strings.CutPrefix(ref, "refs/heads/")
+	if !ok {
+		return Push{}, false, nil
+	}
+	push := Push{Branch: branch, DefaultBranch: defaultBranch, Revision: revision}
+	for _, u := range urls {
+		if u != "" {
+			push.URLs = append(push.URLs, u)
+		}
+	}
+	if len(push.URLs) == 0 {
+		return Push{}, false, fmt.Errorf("push payload has no repository URL")
+	}
+	return push, true, nil
+}
+
+// Server serves a Receiver as a manager runnable
+type Server struct {
+	Addr    string
+	Handler http.Handler
+}
+
+// Start serves until ctx is cancelled
+func (s *Server) Start(ctx context.Context) error {
+	mux := http.NewServeMux()
+	mux.Handle("/hooks/git", s.Handler)
+	server := &http.Server{Addr: s.Addr, Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+
+	errs := make(chan error, 1)
+	go func() {
+		errs <- server.ListenAndServe()
+	}()
+
+	select {
+	case err := <-errs:
+		return err
+	case <-ctx.Done():
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		return server.Shutdown(shutdownCtx)
+	}
+}
@@ -0,0 +1,163 @@
+package receiver
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	"github.com/go-logr/logr"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+const secret = "s3cr3t"
+
+const githubPush = `{
+  "ref": "refs/heads/main",
+  "after": "1a2b3c4d",
+  "repository": {
+    "clone_url": "https://github.com/acme/gitops.git",
+    "ssh_url": "git@github.com:acme/gitops.git",
+    "html_url": "https://github.com/acme/gitops",
+    "default_branch": "main"
+  }
+}`
+
+const gitlabPush = `{
+  "ref": "refs/heads/release",
+  "after": "5e6f7a8b",
+  "project": {
+    "git_http_url": "https://gitlab.com/acme/gitops.git",
+    "git_ssh_url": "git@gitlab.com:acme/gitops.git",
+    "web_url": "https://gitlab.com/acme/gitops",
+    "default_branch": "main"
+  }
+}`
+
+func sign(body string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(body))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+var _ = Describe("Receiver", func() {
+	var (
+		pushes   []Push
+		receiver *Receiver
+	)
+
+	send := func(body string, header map[string]string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/hooks/git", strings.NewReader(body))
+		for k, v := range header {
+			req.Header.Set(k, v)
+		}
+		rec := httptest.NewRecorder()
+		receiver.ServeHTTP(rec, req)
+		return rec
+	}
+
+	BeforeEach(func() {
+		pushes = nil
+		receiver = &Receiver{
+			Secret: []byte(secret),
+			Log:    logr.Discard(),
+			OnPush: func(_ context.Context, push Push) (int, error) {
+				pushes = append(pushes, push)
+				return 2, nil
+			},
+		}
+	})
+
+	It("should accept a signed GitHub push", func() {
+		rec := send(githubPush, map[string]string{
+			"X-GitHub-Event":      "push",
+			"X-Hub-Signature-256": "sha256=" + sign(githubPush),
+		})
+
+		Expect(rec.Code).To(Equal(http.StatusAccepted))
+		Expect(rec.Body.String()).To(Equal("triggered 2 deployments\n"))
+		Expect(pushes).To(ConsistOf(Push{
+			URLs:          []string{"https://github.com/acme/gitops.git", "git@github.com:acme/gitops.git", "https://github.com/acme/gitops"},
+			Branch:        "main",
+			DefaultBranch: "main",
+			Revision:      "1a2b3c4d",
+		}))
+	})
+
+	It("should reject a GitHub push with an invalid signature", func() {
+		rec := send(githubPush, map[string]string{
+			"X-GitHub-Event":      "push",
+			"X-Hub-Signature-256": "sha256=" + sign(githubPush+" "),
+		})
+
+		Expect(rec.Code).To(Equal(http.StatusUnauthorized))
+		Expect(pushes).To(BeEmpty())
+	})
+
+	It("should reject unsigned payloads", func() {
+		rec := send(githubPush, map[string]string{"X-GitHub-Event": "push"})
+
+		Expect(rec.Code).To(Equal(http.StatusUnauthorized))
+		Expect(pushes).To(BeEmpty())
+	})
+
+	It("should accept a signed Gitea push", func() {
+		rec := send(githubPush, map[string]string{
+			"X-Gitea-Event":     "push",
+			"X-Gitea-Signature": sign(githubPush),
+		})
+
+		Expect(rec.Code).To(Equal(http.StatusAccepted))
+		Expect(pushes).To(HaveLen(1))
+	})
+
+	It("should accept a GitLab push with the secret token", func() {
+		rec := send(gitlabPush, map[string]string{
+			"X-Gitlab-Event": "Push Hook",
+			"X-Gitlab-Token": secret,
+		})
+
+		Expect(rec.Code).To(Equal(http.StatusAccepted))
+		Expect(pushes).To(ConsistOf(Push{
+			URLs:          []string{"https://gitlab.com/acme/gitops.git", "git@gitlab.com:acme/gitops.git", "https://gitlab.com/acme/gitops"},
+			Branch:        "release",
+			DefaultBranch: "main",
+			Revision:      "5e6f7a8b",
+		}))
+	})
+
+	It("should reject a GitLab push with a wrong token", func() {
+		rec := send(gitlabPush, map[string]string{
+			"X-Gitlab-Event": "Push Hook",
+			"X-Gitlab-Token": "wrong",
+		})
+
+		Expect(rec.Code).To(Equal(http.StatusUnauthorized))
+		Expect(pushes).To(BeEmpty())
+	})
+
+	It("should acknowledge other events and tag pushes without handling them", func() {
+		ping := `{"zen": "Keep it logically awesome."}`
+		Expect(send(ping, map[string]string{
+			"X-GitHub-Event":      "ping",
+			"X-Hub-Signature-256": "sha256=" + sign(ping),
+		}).Code).To(Equal(http.StatusOK))
+
+		tag := strings.Replace(githubPush, "refs/heads/main", "refs/tags/v1.0.0", 1)
+		Expect(send(tag, map[string]string{
+			"X-GitHub-Event":      "push",
+			"X-Hub-Signature-256": "sha256=" + sign(tag),
+		}).Code).To(Equal(http.StatusOK))
+
+		Expect(pushes).To(BeEmpty())
+	})
+
+	It("should reject requests from unknown senders", func() {
+		Expect(send(githubPush, nil).Code).To(Equal(http.StatusBadRequest))
+	})
+})
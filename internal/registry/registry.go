@@ -0,0 +1,283 @@
+package registry
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/smarter-contracts/pulsepro-operator/internal/command"
+)
+
+// Credentials authenticate to a registry
+type Credentials struct {
+	Username string
+	Password string
+}
+
+// Provider returns the credentials for a registry host, or nil when it has none for the host
+type Provider interface {
+	Credentials(ctx context.Context, host string) (*Credentials, error)
+}
+
+// Keychain holds static credentials per registry host, e.g. from a dockerconfigjson Secret
+type Keychain map[string]Credentials
+
+// Credentials implements Provider
+func (k Keychain) Credentials(_ context.Context, host string) (*Credentials, error) {
+	if creds, ok := k[host]; ok {
+		return &creds, nil
+	}
+	return nil, nil
+}
+
+// Hosts returns the registry hosts of the keychain in order
+func (k Keychain) Hosts() []string {
+	hosts := make([]string, 0, len(k))
+	for host := range k {
+		hosts = append(hosts, host)
+	}
+	sort.Strings(hosts)
+	return hosts
+}
+
+// ParseDockerConfig reads the credentials of a dockerconfigjson document
+func ParseDockerConfig(data []byte) (Keychain, error) {
+	var config struct {
+		Auths map[string]struct {
+			Auth     string `json:"auth"`
+			Username string `json:"username"`
+			Password string `json:"password"`
+		} `json:"auths"`
+	}
+	if err := json.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("invalid docker config: %v", err)
+	}
+
+	keychain := Keychain{}
+	for server, auth := range config.Auths {
+		creds := Credentials{Username: auth.Username, Password: auth.Password}
+		if auth.Auth != "" {
+			decoded, err := base64.StdEncoding.DecodeString(auth.Auth)
+			if err != nil {
+				return nil, fmt.Errorf("invalid auth of %s: %v", server, err)
+			}
+			username, password, ok := strings.Cut(string(decoded), ":")
+			if !ok {
+				return nil, fmt.Errorf("invalid auth of %s: expected username:password", server)
+			}
+			creds = Credentials{Username: username, Password: password}
+		}
+		keychain[Host(server)] = creds
+	}
+	return keychain, nil
+}
+
+// Host returns the registry host of a server address such as "https://registry.example.com/v1/"
+func Host(server string) string {
+	host := server
+	if i := strings.Index(host, "://"); i >= 0 {
+		host = host[i+3:]
+	}
+	host, _, _ = strings.Cut(host, "/")
+	return host
+}
+
+// ChartHost returns the registry host of an OCI chart reference such as "oci://registry.example.com/charts/app",
+// or "" for charts that are not pulled from an OCI registry
+func ChartHost(chart string) string {
+	if !strings.HasPrefix(chart, "oci://") {
+		return ""
+	}
+	return Host(chart)
+}
+
+// Chain asks its providers in order and returns the first credentials found
+type Chain []Provider
+
+// Credentials implements Provider
+func (c Chain) Credentials(ctx context.Context, host string) (*Credentials, error) {
+	for _, provider := range c {
+		if provider == nil {
+			continue
+		}
+		creds, err := provider.Credentials(ctx, host)
+		if err != nil || creds != nil {
+			return creds, err
+		}
+	}
+	return nil, nil
+}
+
+// HostProviders uses one provider per registry host, e.g. a token provider for a cloud registry
+type HostProviders map[string]Provider
+
+// Credentials implements Provider
+func (h HostProviders) Credentials(ctx context.Context, host string) (*Credentials, error) {
+	if provider, ok := h[host]; ok {
+		return provider.Credentials(ctx, host)
+	}
+	return nil, nil
+}
+
+// TokenProvider runs a command printing an access token, which is used as the password of Username
+type TokenProvider struct {
+	Username string
+	Command  string
+	Args     []string
+	Timeout  time.Duration
+}
+
+// Credentials implements Provider
+func (t *TokenProvider) Credentials(ctx context.Context, _ string) (*Credentials, error) {
+	output, err := command.New(ctx, t.Timeout, t.Command, t.Args...).Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get registry token from %s: %v", t.Command, err)
+	}
+	token := strings.TrimSpace(string(output))
+	if token == "" {
+		return nil, fmt.Errorf("failed to get registry token from %s: empty output", t.Command)
+	}
+	return &Credentials{Username: t.Username, Password: token}, nil
+}
+
+// tokenPlugins are the token providers that can be configured by name
+var tokenPlugins = map[string]TokenProvider{
+	// gcloud authenticates to Google Artifact Registry with the operator's Google credentials
+	"gcloud": {Username: "oauth2accesstoken", Command: "gcloud", Args: []string{"auth", "print-access-token"}},
+}
+
+// NewTokenProvider returns the token provider plugin with the given name
+func NewTokenProvider(name string, timeout time.Duration) (Provider, error) {
+	plugin, ok := tokenPlugins[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown registry token provider %q", name)
+	}
+	plugin.Timeout = timeout
+	return &plugin, nil
+}
+
+// WriteConfig writes credentials per host as a registry config file, the format helm reads from
+// HELM_REGISTRY_CONFIG. The file is only readable by its owner.
+func WriteConfig(path string, credentials map[string]Credentials) error {
+	type auth struct {
+		Auth string `json:"auth"`
+	}
+	config := struct {
+		Auths map[string]auth `json:"auths"`
+	}{Auths: map[string]auth{}}
+	for host, creds := range credentials {
+		config.Auths[host] = auth{Auth: base64.StdEncoding.EncodeToString([]byte(creds.Username + ":" + creds.Password))}
+	}
+	data, err := json.Marshal(config)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o600)
+}
+
+// UnauthorizedError is returned by Ping and Tags when the registry rejects the credentials
+type UnauthorizedError struct {
+	Host string
+}
+
+func (e *UnauthorizedError) Error() string {
+	return fmt.Sprintf("registry %s rejected the credentials", e.Host)
+}
+
+// Ping checks that the registry at host accepts the credentials, following the token authentication of
+// the OCI distribution spec. Registries on localhost are reached over plain HTTP.
+func Ping(ctx context.Context, client *http.Client, host string, creds Credentials) error {
+	scheme := "https"
+	if IsLocal(host) {
+		scheme = "http"
+	}
+
+	resp, err := get(ctx, client, scheme+"://"+host+"/v2/", creds)
+	if err != nil {
+		return err
+	}
+	challenge := resp.Header.Get("WWW-Authenticate")
+	_ = resp.Body.Close()
+	switch {
+	case resp.StatusCode == http.StatusOK:
+		return nil
+	case resp.StatusCode != http.StatusUnauthorized:
+		return fmt.Errorf("registry %s returned %s", host, resp.Status)
+	case !strings.HasPrefix(strings.ToLower(challenge), "bearer "):
+		return &UnauthorizedError{Host: host}
+	}
+
+	// Exchange the credentials for a token at the realm of the bearer challenge
+	params := parseChallenge(challenge[len("bearer "):])
+	realm, err := url.Parse(params["realm"])
+	if err != nil || params["realm"] == "" {
+		return fmt.Errorf("registry %s sent an invalid authentication challenge", host)
+	}
+	if service := params["service"]; service != "" {
+		query := realm.Query()
+		query.Set("service", service)
+		realm.RawQuery = query.Encode()
+	}
+	resp, err = get(ctx, client, realm.String(), creds)
+	if err != nil {
+		return err
+	}
+	_ = resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return nil
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return &UnauthorizedError{Host: host}
+	default:
+		return fmt.Errorf("registry %s token endpoint returned %s", host, resp.Status)
+	}
+}
+
+func get(ctx context.Context, client *http.Client, rawURL string, creds Credentials) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.SetBasicAuth(creds.Username, creds.Password)
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("registry request failed: %v", err)
+	}
+	// Drain a little of the body so the connection can be reused
+	_, _ = io.CopyN(io.Discard, resp.Body, 4096)
+	return resp, nil
+}
+
+// parseChallenge parses the comma-separated key="value" parameters of an authentication challenge
+func parseChallenge(s string) map[string]string {
+	params := map[string]string{}
+	for _, part := range strings.Split(s, ",") {
+		key, value, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if ok {
+			params[strings.ToLower(key)] = strings.Trim(value, `"`)
+		}
+	}
+	return params
+}
+
+// IsLocal reports whether the registry host is on the loopback interface, where registries are served over plain HTTP
+func IsLocal(host string) bool {
+	hostname := host
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		hostname = h
+	}
+	if hostname == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(hostname)
+	return ip != nil && ip.IsLoopback()
+}
@@ -0,0 +1,153 @@
+package registry
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("ParseDockerConfig", func() {
+	It("should read auth and username/password entries", func() {
+		keychain, err := ParseDockerConfig([]byte(`{"auths": {
+			"https://registry.example.com/v1/": {"auth": "` + base64.StdEncoding.EncodeToString([]byte("robot:s3cr3t")) + `"},
+			"localhost:5000": {"username": "dev", "password": "dev-password"}
+		}}`))
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(keychain).To(Equal(Keychain{
+			"registry.example.com": {Username: "robot", Password: "s3cr3t"},
+			"localhost:5000":       {Username: "dev", Password: "dev-password"},
+		}))
+		Expect(keychain.Hosts()).To(Equal([]string{"localhost:5000", "registry.example.com"}))
+	})
+
+	It("should reject malformed auth entries", func() {
+		_, err := ParseDockerConfig([]byte(`{"auths": {"registry.example.com": {"auth": "` + base64.StdEncoding.EncodeToString([]byte("no-colon")) + `"}}}`))
+		Expect(err).To(MatchError(ContainSubstring("expected username:password")))
+	})
+})
+
+var _ = Describe("ChartHost", func() {
+	It("should return the registry of OCI charts only", func() {
+		Expect(ChartHost("oci://europe-docker.pkg.dev/acme/charts/pulse-pro")).To(Equal("europe-docker.pkg.dev"))
+		Expect(ChartHost("oci://localhost:5000/charts/pulse-pro")).To(Equal("localhost:5000"))
+		Expect(ChartHost("acme/pulse-pro")).To(BeEmpty())
+	})
+})
+
+var _ = Describe("Providers", func() {
+	ctx := context.Background()
+
+	It("should return the first credentials of a chain", func() {
+		chain := Chain{
+			Keychain{"a.example.com": {Username: "a", Password: "a-password"}},
+			nil,
+			HostProviders{"b.example.com": Keychain{"b.example.com": {Username: "b", Password: "b-password"}}},
+		}
+
+		creds, err := chain.Credentials(ctx, "b.example.com")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(creds).To(Equal(&Credentials{Username: "b", Password: "b-password"}))
+
+		creds, err = chain.Credentials(ctx, "c.example.com")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(creds).To(BeNil())
+	})
+
+	It("should use the output of a token command as password", func() {
+		provider := &TokenProvider{Username: "oauth2accesstoken", Command: "echo", Args: []string{"token-123"}, Timeout: time.Minute}
+
+		creds, err := provider.Credentials(ctx, "europe-docker.pkg.dev")
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(creds).To(Equal(&Credentials{Username: "oauth2accesstoken", Password: "token-123"}))
+	})
+
+	It("should only know the registered token plugins", func() {
+		_, err := NewTokenProvider("gcloud", time.Minute)
+		Expect(err).NotTo(HaveOccurred())
+
+		_, err = NewTokenProvider("unknown", time.Minute)
+		Expect(err).To(MatchError(`unknown registry token provider "unknown"`))
+	})
+})
+
+var _ = Describe("WriteConfig", func() {
+	It("should write a registry config only its owner can read", func() {
+		path := filepath.Join(GinkgoT().TempDir(), "config.json")
+
+		Expect(WriteConfig(path, map[string]Credentials{"localhost:5000": {Username: "dev", Password: "dev-password"}})).To(Succeed())
+
+		info, err := os.Stat(path)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(info.Mode().Perm()).To(Equal(os.FileMode(0o600)))
+		data, err := os.ReadFile(path)
+		Expect(err).NotTo(HaveOccurred())
+		keychain, err := ParseDockerConfig(data)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(keychain).To(Equal(Keychain{"localhost:5000": {Username: "dev", Password: "dev-password"}}))
+	})
+})
+
+var _ = Describe("Ping", func() {
+	ctx := context.Background()
+	valid := Credentials{Username: "dev", Password: "dev-password"}
+
+	// basicAuth accepts only the valid credentials
+	basicAuth := func(r *http.Request) bool {
+		username, password, ok := r.BasicAuth()
+		return ok && username == valid.Username && password == valid.Password
+	}
+
+	It("should accept credentials of a local registry with basic authentication", func() {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !basicAuth(r) {
+				w.Header().Set("WWW-Authenticate", `Basic realm="registry"`)
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			w.WriteHeader(http.StatusOK)
+		}))
+		defer server.Close()
+		host := strings.TrimPrefix(server.URL, "http://")
+
+		Expect(Ping(ctx, server.Client(), host, valid)).To(Succeed())
+
+		err := Ping(ctx, server.Client(), host, Credentials{Username: "dev", Password: "wrong"})
+		var unauthorized *UnauthorizedError
+		Expect(errors.As(err, &unauthorized)).To(BeTrue())
+	})
+
+	It("should exchange credentials for a token at the bearer realm", func() {
+		var server *httptest.Server
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch r.URL.Path {
+			case "/v2/":
+				w.Header().Set("WWW-Authenticate", `Bearer realm="`+server.URL+`/token",service="registry.local"`)
+				w.WriteHeader(http.StatusUnauthorized)
+			case "/token":
+				if r.URL.Query().Get("service") != "registry.local" || !basicAuth(r) {
+					w.WriteHeader(http.StatusUnauthorized)
+					return
+				}
+				_, _ = w.Write([]byte(`{"token": "abc"}`))
+			}
+		}))
+		defer server.Close()
+		host := strings.TrimPrefix(server.URL, "http://")
+
+		Expect(Ping(ctx, server.Client(), host, valid)).To(Succeed())
+
+		err := Ping(ctx, server.Client(), host, Credentials{Username: "dev", Password: "wrong"})
+		Expect(err).To(MatchError(`registry ` + host + ` rejected the credentials`))
+	})
+})
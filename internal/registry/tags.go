@@ -0,0 +1,163 @@
+package registry
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// maxTagPages bounds the pages of a tag list, so that a misbehaving registry cannot loop forever
+const maxTagPages = 100
+
+// Tags lists the tags of an OCI repository such as "registry.example.com/pulsepro/pulse-pro" or
+// "oci://registry.example.com/charts/pulse-pro", following the pagination of the registry. Without
+// credentials, the tags are listed anonymously. Registries on localhost are reached over plain HTTP.
+func Tags(ctx context.Context, client *http.Client, repository string, creds *Credentials) ([]string, error) {
+	host := Host(repository)
+	name := strings.TrimPrefix(repository, "oci://")
+	name = strings.Trim(strings.TrimPrefix(name, host), "/")
+	if host == "" || name == "" {
+		return nil, fmt.Errorf("invalid repository %q", repository)
+	}
+	scheme := "https"
+	if IsLocal(host) {
+		scheme = "http"
+	}
+
+	var tags []string
+	authorization := ""
+	next := scheme + "://" + host + "/v2/" + name + "/tags/list"
+	for pages := 0; next != ""; {
+		resp, err := request(ctx, client, next, authorization)
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode == http.StatusUnauthorized && authorization == "" {
+			challenge := resp.Header.Get("WWW-Authenticate")
+			_ = resp.Body.Close()
+			authorization, err = authorize(ctx, client, host, challenge, creds, "repository:"+name+":pull")
+			if err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if pages++; pages > maxTagPages {
+			_ = resp.Body.Close()
+			return nil, fmt.Errorf("registry %s returned more than %d pages of tags", host, maxTagPages)
+		}
+
+		var list struct {
+			Tags []string `json:"tags"`
+		}
+		err = decode(resp, host, &list)
+		if err != nil {
+			return nil, err
+		}
+		tags = append(tags, list.Tags...)
+		next = nextPage(resp)
+	}
+	return tags, nil
+}
+
+// authorize answers an authentication challenge with the value of an Authorization header. Bearer challenges
+// exchange the credentials, if any, for a token with scope at the realm of the challenge.
+func authorize(ctx context.Context, client *http.Client, host, challenge string, creds *Credentials, scope string) (string, error) {
+	if !strings.HasPrefix(strings.ToLower(challenge), "bearer ") {
+		if creds == nil {
+			return "", &UnauthorizedError{Host: host}
+		}
+		return "Basic " + basic(*creds), nil
+	}
+
+	params := parseChallenge(challenge[len("bearer "):])
+	realm, err := url.Parse(params["realm"])
+	if err != nil || params["realm"] == "" {
+		return "", fmt.Errorf("registry %s sent an invalid authentication challenge", host)
+	}
+	query := realm.Query()
+	if service := params["service"]; service != "" {
+		query.Set("service", service)
+	}
+	query.Set("scope", scope)
+	realm.RawQuery = query.Encode()
+
+	authorization := ""
+	if creds != nil {
+		authorization = "Basic " + basic(*creds)
+	}
+	resp, err := request(ctx, client, realm.String(), authorization)
+	if err != nil {
+		return "", err
+	}
+	var token struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+	if err := decode(resp, host, &token); err != nil {
+		return "", err
+	}
+	if token.Token == "" {
+		token.Token = token.AccessToken
+	}
+	if token.Token == "" {
+		return "", fmt.Errorf("registry %s token endpoint returned no token", host)
+	}
+	return "Bearer " + token.Token, nil
+}
+
+func basic(creds Credentials) string {
+	return base64.StdEncoding.EncodeToString([]byte(creds.Username + ":" + creds.Password))
+}
+
+// request sends a GET request with an optional Authorization header. The caller closes the body.
+func request(ctx context.Context, client *http.Client, rawURL, authorization string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	if authorization != "" {
+		req.Header.Set("Authorization", authorization)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("registry request failed: %v", err)
+	}
+	return resp, nil
+}
+
+// decode reads the JSON body of a successful response and closes it
+func decode(resp *http.Response, host string, v interface{}) error {
+	defer func() { _ = resp.Body.Close() }()
+	switch {
+	case resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden:
+		return &UnauthorizedError{Host: host}
+	case resp.StatusCode != http.StatusOK:
+		return fmt.Errorf("registry %s returned %s", host, resp.Status)
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 10<<20)).Decode(v); err != nil {
+		return fmt.Errorf("registry %s returned an invalid response: %v", host, err)
+	}
+	return nil
+}
+
+// nextPage returns the URL of the next page from the Link header of a response, e.g.
+// `</v2/app/tags/list?n=100&last=1.2.3>; rel="next"`, or "" on the last page
+func nextPage(resp *http.Response) string {
+	for _, link := range resp.Header.Values("Link") {
+		target, params, ok := strings.Cut(link, ";")
+		if !ok || !strings.Contains(strings.ReplaceAll(params, " ", ""), `rel="next"`) {
+			continue
+		}
+		ref, err := url.Parse(strings.Trim(strings.TrimSpace(target), "<>"))
+		if err != nil {
+			return ""
+		}
+		return resp.Request.URL.ResolveReference(ref).String()
+	}
+	return ""
+}
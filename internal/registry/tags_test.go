@@ -0,0 +1,79 @@
+package registry
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Tags", func() {
+	ctx := context.Background()
+
+	It("should list the tags of a local registry page by page", func() {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			Expect(r.URL.Path).To(Equal("/v2/charts/pulse-pro/tags/list"))
+			if r.URL.Query().Get("last") == "" {
+				w.Header().Set("Link", `</v2/charts/pulse-pro/tags/list?n=2&last=2.4.0>; rel="next"`)
+				_, _ = w.Write([]byte(`{"name": "charts/pulse-pro", "tags": ["2.3.0", "2.4.0"]}`))
+				return
+			}
+			_, _ = w.Write([]byte(`{"name": "charts/pulse-pro", "tags": ["2.4.1"]}`))
+		}))
+		defer server.Close()
+		host := strings.TrimPrefix(server.URL, "http://")
+
+		tags, err := Tags(ctx, server.Client(), "oci://"+host+"/charts/pulse-pro", nil)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(tags).To(Equal([]string{"2.3.0", "2.4.0", "2.4.1"}))
+	})
+
+	It("should exchange credentials for a token with the pull scope of the repository", func() {
+		var server *httptest.Server
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch r.URL.Path {
+			case "/v2/pulsepro/pulse-pro/tags/list":
+				if r.Header.Get("Authorization") != "Bearer abc" {
+					w.Header().Set("WWW-Authenticate", `Bearer realm="`+server.URL+`/token",service="registry.local"`)
+					w.WriteHeader(http.StatusUnauthorized)
+					return
+				}
+				_, _ = w.Write([]byte(`{"tags": ["2.4.0"]}`))
+			case "/token":
+				username, password, _ := r.BasicAuth()
+				if r.URL.Query().Get("scope") != "repository:pulsepro/pulse-pro:pull" || password != "dev-password" {
+					w.WriteHeader(http.StatusUnauthorized)
+					return
+				}
+				Expect(username).To(Equal("dev"))
+				_, _ = w.Write([]byte(`{"access_token": "abc"}`))
+			}
+		}))
+		defer server.Close()
+		host := strings.TrimPrefix(server.URL, "http://")
+
+		tags, err := Tags(ctx, server.Client(), host+"/pulsepro/pulse-pro", &Credentials{Username: "dev", Password: "dev-password"})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(tags).To(Equal([]string{"2.4.0"}))
+
+		_, err = Tags(ctx, server.Client(), host+"/pulsepro/pulse-pro", &Credentials{Username: "dev", Password: "wrong"})
+		Expect(err).To(MatchError(`registry ` + host + ` rejected the credentials`))
+	})
+
+	It("should require credentials for basic authentication", func() {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("WWW-Authenticate", `Basic realm="registry"`)
+			w.WriteHeader(http.StatusUnauthorized)
+		}))
+		defer server.Close()
+		host := strings.TrimPrefix(server.URL, "http://")
+
+		_, err := Tags(ctx, server.Client(), host+"/pulsepro/pulse-pro", nil)
+		Expect(err).To(MatchError(`registry ` + host + ` rejected the credentials`))
+		_, err = Tags(ctx, server.Client(), host, nil)
+		Expect(err).To(MatchError(`invalid repository "` + host + `"`))
+	})
+})
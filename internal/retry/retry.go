@@ -0,0 +1,111 @@
+package retry
+
+import (
+	"errors"
+	"time"
+
+	"github.com/smarter-contracts/pulsepro-operator/internal/command"
+)
+
+// Class tells how a failed reconcile is retried
+type Class string
+
+const (
+	// ClassTransient is a failure that is expected to go away by itself, e.g. a network blip or a timeout.
+	// It is retried with an exponential backoff.
+	ClassTransient Class = "Transient"
+
+	// ClassConfiguration is a failure caused by the spec or the GitOps repository, e.g. a missing secrets file.
+	// It is retried when the spec changes, and otherwise only slowly in case the repository was fixed.
+	ClassConfiguration Class = "Configuration"
+
+	// ClassPermanent is a failure that cannot succeed until the spec changes
+	ClassPermanent Class = "Permanent"
+)
+
+// Error is a failure of a reconcile step with its class and a stable reason
+type Error struct {
+	// Class tells how the failure is retried
+	Class Class
+
+	// Reason is a CamelCase identifier of the failure, e.g. "SecretsMissing"
+	Reason string
+
+	// Err is the underlying error
+	Err error
+}
+
+func (e *Error) Error() string {
+	return e.Err.Error()
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Transient classifies err as a transient failure
+func Transient(reason string, err error) error {
+	return &Error{Class: ClassTransient, Reason: reason, Err: err}
+}
+
+// Configuration classifies err as a configuration failure
+func Configuration(reason string, err error) error {
+	return &Error{Class: ClassConfiguration, Reason: reason, Err: err}
+}
+
+// Permanent classifies err as a permanent failure
+func Permanent(reason string, err error) error {
+	return &Error{Class: ClassPermanent, Reason: reason, Err: err}
+}
+
+// Classify returns the classification of err. Timeouts and unclassified errors are transient.
+func Classify(err error) *Error {
+	var classified *Error
+	if errors.As(err, &classified) {
+		if command.IsTimeout(err) {
+			return &Error{Class: ClassTransient, Reason: "TimedOut", Err: classified.Err}
+		}
+		return classified
+	}
+	if command.IsTimeout(err) {
+		return &Error{Class: ClassTransient, Reason: "TimedOut", Err: err}
+	}
+	return &Error{Class: ClassTransient, Reason: "Error", Err: err}
+}
+
+// Policy is the backoff of a class of failures
+type Policy struct {
+	// Base is the delay before the first retry; it doubles with every further retry
+	Base time.Duration
+
+	// Max caps the delay between retries
+	Max time.Duration
+
+	// Retry is false for failures that wait for a spec change
+	Retry bool
+}
+
+// Policies holds the retry policy of each class
+var Policies = map[Class]Policy{
+	ClassTransient:     {Base: 10 * time.Second, Max: 10 * time.Minute, Retry: true},
+	ClassConfiguration: {Base: 5 * time.Minute, Max: time.Hour, Retry: true},
+	ClassPermanent:     {Retry: false},
+}
+
+// Delay returns how long to wait before retry number retryCount (starting at 1) of a failure of the given class.
+// It reports false when the failure is not retried until the spec changes.
+func Delay(class Class, retryCount int32) (time.Duration, bool) {
+	policy, ok := Policies[class]
+	if !ok || !policy.Retry {
+		return 0, false
+	}
+
+	delay := policy.Base
+	for i := int32(1); i < retryCount && delay < policy.Max; i++ {
+		delay *= 2
+	}
+	if delay > policy.Max {
+		delay = policy.Max
+	}
+	return delay, true
+}
@@ -0,0 +1,50 @@
+package retry
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/smarter-contracts/pulsepro-operator/internal/command"
+)
+
+var _ = Describe("Retry", func() {
+	It("should keep the class and reason of wrapped errors", func() {
+		err := fmt.Errorf("reconcile failed: %w", Configuration("SecretsMissing", errors.New("no such file")))
+
+		classified := Classify(err)
+		Expect(classified.Class).To(Equal(ClassConfiguration))
+		Expect(classified.Reason).To(Equal("SecretsMissing"))
+		Expect(classified.Error()).To(Equal("no such file"))
+	})
+
+	It("should treat unclassified errors and timeouts as transient", func() {
+		Expect(Classify(errors.New("connection reset")).Class).To(Equal(ClassTransient))
+
+		timeout := Permanent("HelmfileSyncFailed", &command.TimeoutError{Command: "helmfile", Timeout: time.Minute})
+		classified := Classify(timeout)
+		Expect(classified.Class).To(Equal(ClassTransient))
+		Expect(classified.Reason).To(Equal("TimedOut"))
+	})
+
+	It("should back off exponentially up to the cap", func() {
+		delays := []time.Duration{}
+		for retry := int32(1); retry <= 8; retry++ {
+			delay, ok := Delay(ClassTransient, retry)
+			Expect(ok).To(BeTrue())
+			delays = append(delays, delay)
+		}
+		Expect(delays).To(Equal([]time.Duration{
+			10 * time.Second, 20 * time.Second, 40 * time.Second, 80 * time.Second,
+			160 * time.Second, 320 * time.Second, 10 * time.Minute, 10 * time.Minute,
+		}))
+	})
+
+	It("should not retry permanent failures", func() {
+		_, ok := Delay(ClassPermanent, 1)
+		Expect(ok).To(BeFalse())
+	})
+})
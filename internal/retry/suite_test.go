@@ -0,0 +1,14 @@
+package retry
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func TestRetry(t *testing.T) {
+	RegisterFailHandler(Fail)
+
+	RunSpecs(t, "Retry Suite")
+}
@@ -0,0 +1,130 @@
+package schedule
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/robfig/cron/v3"
+
+	pulseprov1alpha1 "github.com/smarter-contracts/pulsepro-operator/api/v1alpha1"
+)
+
+// Decision tells whether a version-changing release may happen now, and if not, why and when to try again
+type Decision struct {
+	// Allowed is true when no maintenance window or freeze holds the release back
+	Allowed bool
+
+	// Reason is a CamelCase reason suitable for a status condition
+	Reason string
+
+	// Message explains the decision for humans
+	Message string
+
+	// NextAttempt is the earliest time the release may be allowed; zero if unknown
+	NextAttempt time.Time
+}
+
+// cronParser accepts standard five-field cron expressions and descriptors such as @daily
+var cronParser = cron.NewParser(cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow | cron.Descriptor)
+
+// ReleaseDecision evaluates the maintenance windows of a deployment and the cluster's freezes at the given time.
+// Freezes take precedence over maintenance windows.
+func ReleaseDecision(windows []pulseprov1alpha1.MaintenanceWindow, freezes []pulseprov1alpha1.FreezePeriod, category string, now time.Time) (Decision, error) {
+	if freeze := ActiveFreeze(freezes, category, now); freeze != nil {
+		message := fmt.Sprintf("Change freeze %q is active until %s", freeze.Name, freeze.End.UTC().Format(time.RFC3339))
+		if freeze.Reason != "" {
+			message += ": " + freeze.Reason
+		}
+		return Decision{
+			Reason:      pulseprov1alpha1.ReasonChangeFreeze,
+			Message:     message,
+			NextAttempt: freeze.End.Time,
+		}, nil
+	}
+
+	if len(windows) == 0 {
+		return Decision{Allowed: true, Reason: pulseprov1alpha1.ReasonReleaseAllowed, Message: "No maintenance window configured"}, nil
+	}
+
+	var next time.Time
+	for _, window := range windows {
+		open, opensAt, err := WindowOpen(window, now)
+		if err != nil {
+			return Decision{}, err
+		}
+		if open {
+			return Decision{Allowed: true, Reason: pulseprov1alpha1.ReasonReleaseAllowed, Message: fmt.Sprintf("Maintenance window %q is open", window.Schedule)}, nil
+		}
+		if next.IsZero() || opensAt.Before(next) {
+			next = opensAt
+		}
+	}
+
+	return Decision{
+		Reason:      pulseprov1alpha1.ReasonOutsideMaintenanceWindow,
+		Message:     fmt.Sprintf("Outside maintenance windows; the next one opens at %s", next.UTC().Format(time.RFC3339)),
+		NextAttempt: next,
+	}, nil
+}
+
+// WindowOpen reports whether the window is open at the given time, and otherwise when it opens next
+func WindowOpen(window pulseprov1alpha1.MaintenanceWindow, now time.Time) (bool, time.Time, error) {
+	schedule, err := cronParser.Parse(window.Schedule)
+	if err != nil {
+		return false, time.Time{}, fmt.Errorf("invalid maintenance window schedule %q: %v", window.Schedule, err)
+	}
+	duration, err := time.ParseDuration(window.Duration)
+	if err != nil || duration <= 0 {
+		return false, time.Time{}, fmt.Errorf("invalid maintenance window duration %q", window.Duration)
+	}
+	location := time.UTC
+	if window.TimeZone != "" {
+		if location, err = time.LoadLocation(window.TimeZone); err != nil {
+			return false, time.Time{}, fmt.Errorf("invalid maintenance window time zone %q: %v", window.TimeZone, err)
+		}
+	}
+
+	// The only window that can contain now is the first one starting after now-duration
+	start := schedule.Next(now.In(location).Add(-duration))
+	if !start.After(now) {
+		return true, start, nil
+	}
+	return false, start, nil
+}
+
+// ValidateFreeze checks that a freeze ends after it starts
+func ValidateFreeze(freeze pulseprov1alpha1.FreezePeriod) error {
+	if !freeze.End.After(freeze.Start.Time) {
+		return fmt.Errorf("freeze %q ends at %s, which is not later than its start at %s", freeze.Name,
+			freeze.End.UTC().Format(time.RFC3339), freeze.Start.UTC().Format(time.RFC3339))
+	}
+	return nil
+}
+
+// ActiveFreeze returns the freeze covering the category at the given time, if any.
+// When several freezes overlap, the one ending last is returned.
+func ActiveFreeze(freezes []pulseprov1alpha1.FreezePeriod, category string, now time.Time) *pulseprov1alpha1.FreezePeriod {
+	var active *pulseprov1alpha1.FreezePeriod
+	for i := range freezes {
+		freeze := &freezes[i]
+		if now.Before(freeze.Start.Time) || !now.Before(freeze.End.Time) {
+			continue
+		}
+		if len(freeze.Categories) > 0 && !contains(freeze.Categories, category) {
+			continue
+		}
+		if active == nil || freeze.End.After(active.End.Time) {
+			active = freeze
+		}
+	}
+	return active
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
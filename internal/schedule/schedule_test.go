@@ -0,0 +1,85 @@
+package schedule
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	pulseprov1alpha1 "github.com/smarter-contracts/pulsepro-operator/api/v1alpha1"
+)
+
+var _ = Describe("Release schedule", func() {
+	nightly := []pulseprov1alpha1.MaintenanceWindow{{
+		Schedule: "0 1 * * *",
+		Duration: "3h",
+		TimeZone: "Europe/Berlin",
+	}}
+
+	at := func(value string) time.Time {
+		t, err := time.Parse(time.RFC3339, value)
+		Expect(err).NotTo(HaveOccurred())
+		return t
+	}
+
+	It("should allow releases inside a maintenance window", func() {
+		// 02:30 in Berlin (CEST)
+		decision, err := ReleaseDecision(nightly, nil, "production", at("2024-06-10T00:30:00Z"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(decision.Allowed).To(BeTrue())
+	})
+
+	It("should defer releases outside maintenance windows until the next one opens", func() {
+		decision, err := ReleaseDecision(nightly, nil, "production", at("2024-06-10T12:00:00Z"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(decision.Allowed).To(BeFalse())
+		Expect(decision.Reason).To(Equal(pulseprov1alpha1.ReasonOutsideMaintenanceWindow))
+		Expect(decision.NextAttempt.Equal(at("2024-06-10T23:00:00Z"))).To(BeTrue())
+	})
+
+	It("should close the window once its duration has passed", func() {
+		decision, err := ReleaseDecision(nightly, nil, "production", at("2024-06-10T02:00:00Z"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(decision.Allowed).To(BeFalse())
+	})
+
+	It("should defer releases of frozen categories even inside a window", func() {
+		freezes := []pulseprov1alpha1.FreezePeriod{{
+			Name:       "year-end",
+			Start:      metav1.NewTime(at("2024-06-01T00:00:00Z")),
+			End:        metav1.NewTime(at("2024-06-15T00:00:00Z")),
+			Categories: []string{"production"},
+		}}
+
+		decision, err := ReleaseDecision(nightly, freezes, "production", at("2024-06-10T00:30:00Z"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(decision.Allowed).To(BeFalse())
+		Expect(decision.Reason).To(Equal(pulseprov1alpha1.ReasonChangeFreeze))
+		Expect(decision.NextAttempt.Equal(at("2024-06-15T00:00:00Z"))).To(BeTrue())
+
+		decision, err = ReleaseDecision(nil, freezes, "sandbox", at("2024-06-10T00:30:00Z"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(decision.Allowed).To(BeTrue())
+	})
+
+	It("should reject freezes that do not end after they start", func() {
+		freeze := pulseprov1alpha1.FreezePeriod{
+			Name:  "year-end",
+			Start: metav1.NewTime(at("2024-06-15T00:00:00Z")),
+			End:   metav1.NewTime(at("2024-06-01T00:00:00Z")),
+		}
+		Expect(ValidateFreeze(freeze)).To(MatchError(ContainSubstring(`freeze "year-end" ends at 2024-06-01T00:00:00Z`)))
+
+		freeze.End = freeze.Start
+		Expect(ValidateFreeze(freeze)).NotTo(Succeed())
+
+		freeze.End = metav1.NewTime(at("2024-06-16T00:00:00Z"))
+		Expect(ValidateFreeze(freeze)).To(Succeed())
+	})
+
+	It("should reject invalid schedules", func() {
+		_, err := ReleaseDecision([]pulseprov1alpha1.MaintenanceWindow{{Schedule: "nightly", Duration: "1h"}}, nil, "", time.Now())
+		Expect(err).To(HaveOccurred())
+	})
+})
@@ -0,0 +1,215 @@
+package signature
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"golang.org/x/crypto/ssh"
+)
+
+// sshNamespace is the namespace git signs commits in with SSH keys
+const sshNamespace = "git"
+
+var (
+	// ErrUnsigned is returned for commits without a signature
+	ErrUnsigned = errors.New("commit is not signed")
+
+	// ErrUntrusted is returned for commits whose signature is not made by a trusted key
+	ErrUntrusted = errors.New("commit is not signed by a trusted key")
+)
+
+// KeyRing holds the GPG and SSH public keys trusted to sign commits
+type KeyRing struct {
+	gpg openpgp.EntityList
+	ssh []ssh.PublicKey
+}
+
+// ParseKeyRing reads the trusted keys from the values of a Secret. A value is either one or more
+// armored GPG public keys, or SSH public keys in authorized_keys or allowed_signers format.
+func ParseKeyRing(data map[string][]byte) (*KeyRing, error) {
+	// Parse in a stable order, so that errors are reproducible
+	names := make([]string, 0, len(data))
+	for name := range data {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	k := &KeyRing{}
+	for _, name := range names {
+		value := data[name]
+		if bytes.Contains(value, []byte("-----BEGIN PGP PUBLIC KEY BLOCK-----")) {
+			entities, err := openpgp.ReadArmoredKeyRing(bytes.NewReader(value))
+			if err != nil {
+				return nil, fmt.Errorf("invalid GPG keys in %s: %v", name, err)
+			}
+			k.gpg = append(k.gpg, entities...)
+			continue
+		}
+		keys, err := parseSSHKeys(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid SSH keys in %s: %v", name, err)
+		}
+		k.ssh = append(k.ssh, keys...)
+	}
+	if k.Len() == 0 {
+		return nil, fmt.Errorf("no trusted keys found")
+	}
+	return k, nil
+}
+
+// parseSSHKeys parses one SSH public key per line. The principals of an allowed_signers line are
+// parsed like the options of an authorized_keys line.
+func parseSSHKeys(data []byte) ([]ssh.PublicKey, error) {
+	var keys []ssh.PublicKey
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		key, _, _, _, err := ssh.ParseAuthorizedKey([]byte(line))
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	return keys, scanner.Err()
+}
+
+// Len returns the number of trusted keys
+func (k *KeyRing) Len() int {
+	return len(k.gpg) + len(k.ssh)
+}
+
+// Verify checks that commit is signed by a trusted key and returns a description of that key
+func (k *KeyRing) Verify(commit *object.Commit) (string, error) {
+	if commit.PGPSignature == "" {
+		return "", ErrUnsigned
+	}
+
+	encoded := &plumbing.MemoryObject{}
+	if err := commit.EncodeWithoutSignature(encoded); err != nil {
+		return "", fmt.Errorf("failed to encode commit: %v", err)
+	}
+	reader, err := encoded.Reader()
+	if err != nil {
+		return "", fmt.Errorf("failed to encode commit: %v", err)
+	}
+	payload, err := io.ReadAll(reader)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode commit: %v", err)
+	}
+
+	if strings.HasPrefix(commit.PGPSignature, "-----BEGIN SSH SIGNATURE-----") {
+		return k.verifySSH(payload, commit.PGPSignature)
+	}
+	return k.verifyGPG(payload, commit.PGPSignature)
+}
+
+func (k *KeyRing) verifyGPG(payload []byte, signature string) (string, error) {
+	if len(k.gpg) == 0 {
+		return "", ErrUntrusted
+	}
+	entity, err := openpgp.CheckArmoredDetachedSignature(k.gpg, bytes.NewReader(payload), strings.NewReader(signature), nil)
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrUntrusted, err)
+	}
+	if identity := entity.PrimaryIdentity(); identity != nil {
+		return fmt.Sprintf("GPG key %X (%s)", entity.PrimaryKey.Fingerprint, identity.Name), nil
+	}
+	return fmt.Sprintf("GPG key %X", entity.PrimaryKey.Fingerprint), nil
+}
+
+// sshSignature is the blob of an armored SSH signature, see PROTOCOL.sshsig in OpenSSH
+type sshSignature struct {
+	Magic         [6]byte
+	Version       uint32
+	PublicKey     []byte
+	Namespace     string
+	Reserved      string
+	HashAlgorithm string
+	Signature     []byte
+}
+
+// sshSignedData is the data an SSH signature is made over
+type sshSignedData struct {
+	Magic         [6]byte
+	Namespace     string
+	Reserved      string
+	HashAlgorithm string
+	Hash          []byte
+}
+
+func (k *KeyRing) verifySSH(payload []byte, armored string) (string, error) {
+	block, _ := pem.Decode([]byte(armored))
+	if block == nil || block.Type != "SSH SIGNATURE" {
+		return "", fmt.Errorf("invalid SSH signature")
+	}
+	var sig sshSignature
+	if err := ssh.Unmarshal(block.Bytes, &sig); err != nil {
+		return "", fmt.Errorf("invalid SSH signature: %v", err)
+	}
+	if string(sig.Magic[:]) != "SSHSIG" || sig.Version != 1 {
+		return "", fmt.Errorf("unsupported SSH signature version %d", sig.Version)
+	}
+	if sig.Namespace != sshNamespace {
+		return "", fmt.Errorf("%w: SSH signature namespace is %q, not %q", ErrUntrusted, sig.Namespace, sshNamespace)
+	}
+
+	publicKey, err := ssh.ParsePublicKey(sig.PublicKey)
+	if err != nil {
+		return "", fmt.Errorf("invalid SSH signature key: %v", err)
+	}
+	if !k.trustsSSH(publicKey) {
+		return "", fmt.Errorf("%w: SSH key %s", ErrUntrusted, ssh.FingerprintSHA256(publicKey))
+	}
+
+	var h hash.Hash
+	switch sig.HashAlgorithm {
+	case "sha256":
+		h = sha256.New()
+	case "sha512":
+		h = sha512.New()
+	default:
+		return "", fmt.Errorf("unsupported SSH signature hash algorithm %q", sig.HashAlgorithm)
+	}
+	h.Write(payload)
+
+	var signature ssh.Signature
+	if err := ssh.Unmarshal(sig.Signature, &signature); err != nil {
+		return "", fmt.Errorf("invalid SSH signature: %v", err)
+	}
+	signed := ssh.Marshal(sshSignedData{
+		Magic:         sig.Magic,
+		Namespace:     sig.Namespace,
+		Reserved:      sig.Reserved,
+		HashAlgorithm: sig.HashAlgorithm,
+		Hash:          h.Sum(nil),
+	})
+	if err := publicKey.Verify(signed, &signature); err != nil {
+		return "", fmt.Errorf("%w: %v", ErrUntrusted, err)
+	}
+	return fmt.Sprintf("SSH key %s", ssh.FingerprintSHA256(publicKey)), nil
+}
+
+func (k *KeyRing) trustsSSH(key ssh.PublicKey) bool {
+	marshaled := key.Marshal()
+	for _, trusted := range k.ssh {
+		if bytes.Equal(trusted.Marshal(), marshaled) {
+			return true
+		}
+	}
+	return false
+}
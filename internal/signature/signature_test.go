@@ -0,0 +1,165 @@
+package signature
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha512"
+	"encoding/pem"
+	"io"
+	"time"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/armor"
+	"github.com/go-git/go-billy/v5/memfs"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/storage/memory"
+	"golang.org/x/crypto/ssh"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+// sshSigner signs commits like `git commit -S` with gpg.format=ssh
+type sshSigner struct {
+	signer    ssh.Signer
+	namespace string
+}
+
+func (s sshSigner) Sign(message io.Reader) ([]byte, error) {
+	data, err := io.ReadAll(message)
+	if err != nil {
+		return nil, err
+	}
+	magic := [6]byte{'S', 'S', 'H', 'S', 'I', 'G'}
+	sum := sha512.Sum512(data)
+	sig, err := s.signer.Sign(rand.Reader, ssh.Marshal(sshSignedData{
+		Magic:         magic,
+		Namespace:     s.namespace,
+		HashAlgorithm: "sha512",
+		Hash:          sum[:],
+	}))
+	if err != nil {
+		return nil, err
+	}
+	blob := ssh.Marshal(sshSignature{
+		Magic:         magic,
+		Version:       1,
+		PublicKey:     s.signer.PublicKey().Marshal(),
+		Namespace:     s.namespace,
+		HashAlgorithm: "sha512",
+		Signature:     ssh.Marshal(sig),
+	})
+	return pem.EncodeToMemory(&pem.Block{Type: "SSH SIGNATURE", Bytes: blob}), nil
+}
+
+func newSSHSigner() ssh.Signer {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	Expect(err).NotTo(HaveOccurred())
+	signer, err := ssh.NewSignerFromKey(key)
+	Expect(err).NotTo(HaveOccurred())
+	return signer
+}
+
+func newGPGEntity(name string) *openpgp.Entity {
+	entity, err := openpgp.NewEntity(name, "", name+"@example.com", nil)
+	Expect(err).NotTo(HaveOccurred())
+	return entity
+}
+
+func armoredPublicKey(entity *openpgp.Entity) []byte {
+	buf := &bytes.Buffer{}
+	w, err := armor.Encode(buf, openpgp.PublicKeyType, nil)
+	Expect(err).NotTo(HaveOccurred())
+	Expect(entity.Serialize(w)).To(Succeed())
+	Expect(w.Close()).To(Succeed())
+	return buf.Bytes()
+}
+
+// commit creates a commit signed as set up in options
+func commit(options *git.CommitOptions) *object.Commit {
+	repo, err := git.Init(memory.NewStorage(), memfs.New())
+	Expect(err).NotTo(HaveOccurred())
+	w, err := repo.Worktree()
+	Expect(err).NotTo(HaveOccurred())
+	options.Author = &object.Signature{Name: "test", Email: "test@example.com", When: time.Now()}
+	options.AllowEmptyCommits = true
+	hash, err := w.Commit("release 2.4.0", options)
+	Expect(err).NotTo(HaveOccurred())
+	c, err := repo.CommitObject(hash)
+	Expect(err).NotTo(HaveOccurred())
+	return c
+}
+
+var _ = Describe("KeyRing", func() {
+	It("should verify commits signed with a trusted GPG key", func() {
+		entity := newGPGEntity("release")
+		keys, err := ParseKeyRing(map[string][]byte{"release.asc": armoredPublicKey(entity)})
+		Expect(err).NotTo(HaveOccurred())
+
+		signer, err := keys.Verify(commit(&git.CommitOptions{SignKey: entity}))
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(signer).To(HavePrefix("GPG key "))
+		Expect(signer).To(ContainSubstring("release <release@example.com>"))
+	})
+
+	It("should reject commits signed with an untrusted GPG key", func() {
+		keys, err := ParseKeyRing(map[string][]byte{"release.asc": armoredPublicKey(newGPGEntity("release"))})
+		Expect(err).NotTo(HaveOccurred())
+
+		_, err = keys.Verify(commit(&git.CommitOptions{SignKey: newGPGEntity("mallory")}))
+
+		Expect(err).To(MatchError(ErrUntrusted))
+	})
+
+	It("should verify commits signed with a trusted SSH key", func() {
+		signer := newSSHSigner()
+		keys, err := ParseKeyRing(map[string][]byte{
+			"allowed_signers": []byte("# release managers\nrelease@example.com " + string(ssh.MarshalAuthorizedKey(signer.PublicKey()))),
+		})
+		Expect(err).NotTo(HaveOccurred())
+
+		description, err := keys.Verify(commit(&git.CommitOptions{Signer: sshSigner{signer: signer, namespace: "git"}}))
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(description).To(Equal("SSH key " + ssh.FingerprintSHA256(signer.PublicKey())))
+	})
+
+	It("should reject commits signed with an untrusted SSH key", func() {
+		keys, err := ParseKeyRing(map[string][]byte{"authorized_keys": ssh.MarshalAuthorizedKey(newSSHSigner().PublicKey())})
+		Expect(err).NotTo(HaveOccurred())
+
+		_, err = keys.Verify(commit(&git.CommitOptions{Signer: sshSigner{signer: newSSHSigner(), namespace: "git"}}))
+
+		Expect(err).To(MatchError(ErrUntrusted))
+	})
+
+	It("should reject SSH signatures made for another namespace", func() {
+		signer := newSSHSigner()
+		keys, err := ParseKeyRing(map[string][]byte{"authorized_keys": ssh.MarshalAuthorizedKey(signer.PublicKey())})
+		Expect(err).NotTo(HaveOccurred())
+
+		_, err = keys.Verify(commit(&git.CommitOptions{Signer: sshSigner{signer: signer, namespace: "file"}}))
+
+		Expect(err).To(MatchError(ErrUntrusted))
+	})
+
+	It("should reject unsigned commits", func() {
+		keys, err := ParseKeyRing(map[string][]byte{"authorized_keys": ssh.MarshalAuthorizedKey(newSSHSigner().PublicKey())})
+		Expect(err).NotTo(HaveOccurred())
+
+		_, err = keys.Verify(commit(&git.CommitOptions{}))
+
+		Expect(err).To(MatchError(ErrUnsigned))
+	})
+
+	It("should require at least one valid key", func() {
+		_, err := ParseKeyRing(map[string][]byte{})
+		Expect(err).To(MatchError("no trusted keys found"))
+
+		_, err = ParseKeyRing(map[string][]byte{"authorized_keys": []byte("not a key")})
+		Expect(err).To(MatchError(ContainSubstring("invalid SSH keys in authorized_keys")))
+	})
+})
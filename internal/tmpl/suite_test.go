@@ -0,0 +1,14 @@
+package tmpl
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func TestTmpl(t *testing.T) {
+	RegisterFailHandler(Fail)
+
+	RunSpecs(t, "Tmpl Suite")
+}
@@ -0,0 +1,274 @@
+package tmpl
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+
+	sprig "github.com/go-task/slim-sprig/v3"
+	"gopkg.in/yaml.v2"
+)
+
+// Renderer renders helmfile values templates such as values.yaml.gotmpl, with the sprig functions and
+// the functions helmfile adds to them
+type Renderer struct {
+	// BaseDir is the directory relative paths of readFile, readDir, isFile and isDir are resolved against
+	BaseDir string
+	// Root is the directory templates may read files from; empty for BaseDir
+	Root string
+	// LookupEnv looks up environment variables; nil uses os.LookupEnv
+	LookupEnv func(key string) (string, bool)
+	// OnEnv is called with each environment variable a template reads, e.g. to mask its value; optional
+	OnEnv func(key, value string)
+}
+
+// Render executes the template text named name with data. Missing map keys are errors, like in helmfile.
+func (r *Renderer) Render(name string, text []byte, data interface{}) ([]byte, error) {
+	t, err := r.parse(name, string(text))
+	if err != nil {
+		return nil, err
+	}
+	var out bytes.Buffer
+	if err := t.Execute(&out, data); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+func (r *Renderer) parse(name, text string) (*template.Template, error) {
+	return template.New(name).Option("missingkey=error").Funcs(r.funcs()).Parse(text)
+}
+
+// funcs returns the sprig functions with helmfile's additions and overrides
+func (r *Renderer) funcs() template.FuncMap {
+	funcs := sprig.TxtFuncMap()
+	funcs["env"] = r.env
+	funcs["requiredEnv"] = r.requiredEnv
+	funcs["readFile"] = r.readFile
+	funcs["readDir"] = r.readDir
+	funcs["isFile"] = r.isFile
+	funcs["isDir"] = r.isDir
+	funcs["toYaml"] = toYaml
+	funcs["fromYaml"] = fromYaml
+	funcs["get"] = get
+	funcs["getOrNil"] = getOrNil
+	funcs["setValueAtPath"] = setValueAtPath
+	funcs["required"] = required
+	funcs["tpl"] = r.tpl
+	for _, name := range []string{"exec", "envExec", "fetchSecretValue", "expandSecretRefs"} {
+		funcs[name] = unsupported(name)
+	}
+	return funcs
+}
+
+// unsupported returns a function failing templates that use a helmfile function the operator does not run
+func unsupported(name string) func(...interface{}) (interface{}, error) {
+	return func(...interface{}) (interface{}, error) {
+		return nil, fmt.Errorf("%s is not supported when the operator renders values templates", name)
+	}
+}
+
+func (r *Renderer) lookupEnv(key string) (string, bool) {
+	lookup := r.LookupEnv
+	if lookup == nil {
+		lookup = os.LookupEnv
+	}
+	value, ok := lookup(key)
+	if ok && r.OnEnv != nil {
+		r.OnEnv(key, value)
+	}
+	return value, ok
+}
+
+func (r *Renderer) env(key string) string {
+	value, _ := r.lookupEnv(key)
+	return value
+}
+
+func (r *Renderer) requiredEnv(key string) (string, error) {
+	if value, ok := r.lookupEnv(key); ok && value != "" {
+		return value, nil
+	}
+	return "", fmt.Errorf("required env var `%s` is not set", key)
+}
+
+// path resolves a path of a template against BaseDir, refusing paths outside of Root
+func (r *Renderer) path(name string) (string, error) {
+	path := name
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(r.BaseDir, path)
+	}
+	root := r.Root
+	if root == "" {
+		root = r.BaseDir
+	}
+	rel, err := filepath.Rel(root, filepath.Clean(path))
+	if err != nil || !filepath.IsLocal(rel) {
+		return "", fmt.Errorf("%s is outside of %s", name, root)
+	}
+	return path, nil
+}
+
+func (r *Renderer) readFile(name string) (string, error) {
+	path, err := r.path(name)
+	if err != nil {
+		return "", err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+func (r *Renderer) readDir(name string) ([]string, error) {
+	path, err := r.path(name)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		return nil, err
+	}
+	var files []string
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			files = append(files, filepath.Join(name, entry.Name()))
+		}
+	}
+	return files, nil
+}
+
+func (r *Renderer) isFile(name string) (bool, error) {
+	path, err := r.path(name)
+	if err != nil {
+		return false, err
+	}
+	info, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	return err == nil && info.Mode().IsRegular(), err
+}
+
+func (r *Renderer) isDir(name string) (bool, error) {
+	path, err := r.path(name)
+	if err != nil {
+		return false, err
+	}
+	info, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	return err == nil && info.IsDir(), err
+}
+
+// tpl renders text as a template with the same functions
+func (r *Renderer) tpl(text string, data interface{}) (string, error) {
+	out, err := r.Render("tpl", []byte(text), data)
+	return string(out), err
+}
+
+func toYaml(v interface{}) (string, error) {
+	data, err := yaml.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+func fromYaml(s string) (map[string]interface{}, error) {
+	var m map[string]interface{}
+	if err := yaml.Unmarshal([]byte(s), &m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func required(message string, v interface{}) (interface{}, error) {
+	if v == nil {
+		return nil, fmt.Errorf("%s", message)
+	}
+	if s, ok := v.(string); ok && s == "" {
+		return nil, fmt.Errorf("%s", message)
+	}
+	return v, nil
+}
+
+// get returns the value at a dotted path of a map, e.g. `.Values | get "image.tag" "latest"`. Without a
+// default, a missing value is an error.
+func get(path string, args ...interface{}) (interface{}, error) {
+	var defaultValue interface{}
+	var obj interface{}
+	switch len(args) {
+	case 1:
+		obj = args[0]
+	case 2:
+		defaultValue, obj = args[0], args[1]
+	default:
+		return nil, fmt.Errorf("get expects 2 or 3 arguments, got %d", len(args)+1)
+	}
+	value, found := lookup(path, obj)
+	if !found {
+		if len(args) == 2 {
+			return defaultValue, nil
+		}
+		return nil, fmt.Errorf("no value at %s", path)
+	}
+	return value, nil
+}
+
+// getOrNil returns the value at a dotted path of a map, or nil when there is none
+func getOrNil(path string, obj interface{}) interface{} {
+	value, _ := lookup(path, obj)
+	return value
+}
+
+func lookup(path string, obj interface{}) (interface{}, bool) {
+	if path == "" {
+		return obj, true
+	}
+	current := obj
+	for _, key := range strings.Split(path, ".") {
+		switch m := current.(type) {
+		case map[string]interface{}:
+			value, ok := m[key]
+			if !ok {
+				return nil, false
+			}
+			current = value
+		case map[interface{}]interface{}:
+			value, ok := m[key]
+			if !ok {
+				return nil, false
+			}
+			current = value
+		default:
+			return nil, false
+		}
+	}
+	return current, true
+}
+
+// setValueAtPath sets the value at a dotted path of a map, creating the maps along the path
+func setValueAtPath(path string, value interface{}, obj map[string]interface{}) (map[string]interface{}, error) {
+	keys := strings.Split(path, ".")
+	current := obj
+	for _, key := range keys[:len(keys)-1] {
+		switch next := current[key].(type) {
+		case map[string]interface{}:
+			current = next
+		case nil:
+			child := map[string]interface{}{}
+			current[key] = child
+			current = child
+		default:
+			return nil, fmt.Errorf("cannot set %s: %s is not a map", path, key)
+		}
+	}
+	current[keys[len(keys)-1]] = value
+	return obj, nil
+}
@@ -0,0 +1,100 @@
+package tmpl
+
+import (
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Renderer", func() {
+	var (
+		root     string
+		renderer *Renderer
+		env      map[string]string
+	)
+
+	BeforeEach(func() {
+		root = GinkgoT().TempDir()
+		Expect(os.MkdirAll(filepath.Join(root, "helmfiles", "gke"), 0o755)).To(Succeed())
+		Expect(os.WriteFile(filepath.Join(root, "helmfiles", "gke", "banner.txt"), []byte("hello\n"), 0o644)).To(Succeed())
+		Expect(os.WriteFile(filepath.Join(root, "common.yaml"), []byte("region: eu\n"), 0o644)).To(Succeed())
+		env = map[string]string{"CLUSTER": "prod-1"}
+		renderer = &Renderer{
+			BaseDir: filepath.Join(root, "helmfiles", "gke"),
+			Root:    root,
+			LookupEnv: func(key string) (string, bool) {
+				value, ok := env[key]
+				return value, ok
+			},
+		}
+	})
+
+	render := func(text string, data interface{}) (string, error) {
+		out, err := renderer.Render("values.yaml.gotmpl", []byte(text), data)
+		return string(out), err
+	}
+
+	It("renders helmfile functions with the values", func() {
+		data := map[string]interface{}{
+			"Values": map[string]interface{}{"image": map[string]interface{}{"tag": "1.2.3"}},
+		}
+		out, err := render(`cluster: {{ requiredEnv "CLUSTER" }}
+tag: {{ .Values | get "image.tag" }}
+pullPolicy: {{ .Values | get "image.pullPolicy" "IfNotPresent" }}
+banner: {{ readFile "banner.txt" | trim | quote }}
+{{ readFile "../../common.yaml" | fromYaml | toYaml }}
+{{- if isFile "banner.txt" }}hasBanner: true{{ end }}
+`, data)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(out).To(Equal(`cluster: prod-1
+tag: 1.2.3
+pullPolicy: IfNotPresent
+banner: "hello"
+region: eu
+hasBanner: true
+`))
+	})
+
+	It("reports the environment variables it reads", func() {
+		read := map[string]string{}
+		renderer.OnEnv = func(key, value string) { read[key] = value }
+		_, err := render(`{{ env "CLUSTER" }}{{ env "UNSET" }}`, nil)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(read).To(Equal(map[string]string{"CLUSTER": "prod-1"}))
+	})
+
+	It("fails on missing environment variables and values", func() {
+		_, err := render(`{{ requiredEnv "MISSING" }}`, nil)
+		Expect(err).To(MatchError(ContainSubstring("required env var `MISSING` is not set")))
+
+		_, err = render(`{{ .Values.missing }}`, map[string]interface{}{"Values": map[string]interface{}{}})
+		Expect(err).To(MatchError(ContainSubstring("missing")))
+
+		_, err = render(`{{ .Values | get "a.b" }}`, map[string]interface{}{"Values": map[string]interface{}{}})
+		Expect(err).To(MatchError(ContainSubstring("no value at a.b")))
+
+		_, err = render(`{{ required "db.host is required" "" }}`, nil)
+		Expect(err).To(MatchError(ContainSubstring("db.host is required")))
+	})
+
+	It("refuses to read files outside of the root", func() {
+		_, err := render(`{{ readFile "../../../etc/passwd" }}`, nil)
+		Expect(err).To(MatchError(ContainSubstring("is outside of")))
+		_, err = render(`{{ readFile "/etc/passwd" }}`, nil)
+		Expect(err).To(MatchError(ContainSubstring("is outside of")))
+	})
+
+	It("does not run commands", func() {
+		_, err := render(`{{ exec "whoami" (list) }}`, nil)
+		Expect(err).To(MatchError(ContainSubstring("exec is not supported")))
+	})
+
+	It("renders nested templates and sets values at paths", func() {
+		data := map[string]interface{}{"Environment": map[string]interface{}{"Name": "acme-prod"}}
+		out, err := render(`{{ tpl "env-{{ .Environment.Name }}" . }} {{ dict | setValueAtPath "a.b" 1 | toJson }} {{ getOrNil "x.y" dict | toJson }}`, data)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(out).To(Equal(`env-acme-prod {"a":{"b":1}} null`))
+	})
+})
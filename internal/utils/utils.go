@@ -27,3 +27,16 @@ func MatchesCategory(deploymentCategory, rolloutCategory string) bool {
 	}
 	return deploymentCategory == rolloutCategory
 }
+
+// MatchesEnvironment checks if the deployment's environment is one of the rollout's environments
+func MatchesEnvironment(deploymentEnvironment string, rolloutEnvironments []string) bool {
+	if len(rolloutEnvironments) == 0 {
+		return true
+	}
+	for _, environment := range rolloutEnvironments {
+		if environment == deploymentEnvironment {
+			return true
+		}
+	}
+	return false
+}
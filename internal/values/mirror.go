@@ -0,0 +1,85 @@
+package values
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// maxMirrorSize is the largest total size of mirrored files, the size limit of a ConfigMap
+const maxMirrorSize = 1 << 20
+
+// ReadFiles reads the files at paths, relative to root, for a ConfigMap. Directories are read recursively.
+// Each file is keyed by its path relative to root with "/" replaced by ".", e.g. "values.pulse-pro.values.yaml".
+func ReadFiles(root string, paths []string) (map[string]string, error) {
+	data := map[string]string{}
+	sources := map[string]string{}
+	size := 0
+
+	add := func(path string) error {
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		key := strings.ReplaceAll(rel, "/", ".")
+		if errs := validation.IsConfigMapKey(key); len(errs) > 0 {
+			return fmt.Errorf("%s cannot be mirrored: %s", rel, strings.Join(errs, ", "))
+		}
+		if other, ok := sources[key]; ok {
+			return fmt.Errorf("%s and %s are both mirrored as %s", other, rel, key)
+		}
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %v", rel, err)
+		}
+		if !utf8.Valid(content) {
+			return fmt.Errorf("%s cannot be mirrored: not UTF-8 text", rel)
+		}
+		size += len(content)
+		if size > maxMirrorSize {
+			return fmt.Errorf("mirrored files exceed the ConfigMap size limit of %d bytes", maxMirrorSize)
+		}
+		data[key], sources[key] = string(content), rel
+		return nil
+	}
+
+	// Read in a stable order, so that errors are reproducible
+	sorted := append([]string(nil), paths...)
+	sort.Strings(sorted)
+	for _, p := range sorted {
+		if !filepath.IsLocal(p) {
+			return nil, fmt.Errorf("%s is outside of the repository", p)
+		}
+		path := filepath.Join(root, p)
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %v", p, err)
+		}
+		if !info.IsDir() {
+			if err := add(path); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		err = filepath.WalkDir(path, func(file string, entry fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if entry.Type().IsRegular() {
+				return add(file)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return data, nil
+}
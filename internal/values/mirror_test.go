@@ -0,0 +1,63 @@
+package values
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("ReadFiles", func() {
+	var root string
+
+	write := func(path, content string) {
+		path = filepath.Join(root, path)
+		Expect(os.MkdirAll(filepath.Dir(path), 0o755)).To(Succeed())
+		Expect(os.WriteFile(path, []byte(content), 0o644)).To(Succeed())
+	}
+
+	BeforeEach(func() {
+		root = GinkgoT().TempDir()
+		write("environments/acme-prod/values/values.yaml", "replicas: 3\n")
+		write("environments/acme-prod/values/pulse-pro/values.yaml.gotmpl", "tag: {{ .Values.tag }}\n")
+		write("environments/acme-prod/secrets/secrets.yaml", "password: x\n")
+	})
+
+	It("keys the files of directories and files by their path", func() {
+		data, err := ReadFiles(root, []string{"environments/acme-prod/values", "environments/acme-prod/secrets/secrets.yaml"})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(data).To(Equal(map[string]string{
+			"environments.acme-prod.values.values.yaml":                  "replicas: 3\n",
+			"environments.acme-prod.values.pulse-pro.values.yaml.gotmpl": "tag: {{ .Values.tag }}\n",
+			"environments.acme-prod.secrets.secrets.yaml":                "password: x\n",
+		}))
+	})
+
+	It("fails on missing paths and paths outside of the repository", func() {
+		_, err := ReadFiles(root, []string{"environments/missing"})
+		Expect(err).To(MatchError(ContainSubstring("failed to read environments/missing")))
+		_, err = ReadFiles(root, []string{"../etc"})
+		Expect(err).To(MatchError("../etc is outside of the repository"))
+	})
+
+	It("fails on files that cannot be ConfigMap keys", func() {
+		write("values/a b.yaml", "a: 1\n")
+		_, err := ReadFiles(root, []string{"values"})
+		Expect(err).To(MatchError(ContainSubstring("values/a b.yaml cannot be mirrored")))
+	})
+
+	It("fails on files mirrored as the same key", func() {
+		write("values/a/b.yaml", "a: 1\n")
+		write("values/a.b.yaml", "a: 2\n")
+		_, err := ReadFiles(root, []string{"values"})
+		Expect(err).To(MatchError(ContainSubstring("are both mirrored as values.a.b.yaml")))
+	})
+
+	It("fails when the files exceed the size of a ConfigMap", func() {
+		write("values/big.yaml", strings.Repeat("a", maxMirrorSize+1))
+		_, err := ReadFiles(root, []string{"values"})
+		Expect(err).To(MatchError(ContainSubstring("size limit")))
+	})
+})
@@ -0,0 +1,82 @@
+package values
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+
+	"gopkg.in/yaml.v2"
+)
+
+// Layer is one YAML document of Helm values and the source it was read from
+type Layer struct {
+	Source string
+	Data   []byte
+}
+
+// Merge deep-merges the layers in order: maps are merged key by key, and any other value of a later
+// layer, including lists and null, replaces the value of an earlier one
+func Merge(layers []Layer) (map[string]interface{}, error) {
+	merged := map[string]interface{}{}
+	for _, layer := range layers {
+		var document interface{}
+		if err := yaml.Unmarshal(layer.Data, &document); err != nil {
+			return nil, fmt.Errorf("invalid values in %s: %v", layer.Source, err)
+		}
+		if document == nil {
+			continue
+		}
+		mapping, ok := normalize(document).(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("invalid values in %s: expected a mapping", layer.Source)
+		}
+		merge(merged, mapping)
+	}
+	return merged, nil
+}
+
+// merge merges src into dst
+func merge(dst, src map[string]interface{}) {
+	for key, value := range src {
+		srcMap, srcIsMap := value.(map[string]interface{})
+		dstMap, dstIsMap := dst[key].(map[string]interface{})
+		if srcIsMap && dstIsMap {
+			merge(dstMap, srcMap)
+			continue
+		}
+		dst[key] = value
+	}
+}
+
+// normalize turns the map[interface{}]interface{} of decoded YAML into map[string]interface{}
+func normalize(node interface{}) interface{} {
+	switch n := node.(type) {
+	case map[interface{}]interface{}:
+		mapping := make(map[string]interface{}, len(n))
+		for key, value := range n {
+			mapping[fmt.Sprint(key)] = normalize(value)
+		}
+		return mapping
+	case []interface{}:
+		for i, value := range n {
+			n[i] = normalize(value)
+		}
+		return n
+	default:
+		return node
+	}
+}
+
+// Marshal returns the YAML document of values with sorted keys, so that equal values give equal documents
+func Marshal(values map[string]interface{}) ([]byte, error) {
+	if len(values) == 0 {
+		return []byte{}, nil
+	}
+	return yaml.Marshal(values)
+}
+
+// Hash returns the digest of a values document, e.g. "sha256:..."
+func Hash(document []byte) string {
+	sum := sha256.Sum256(document)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
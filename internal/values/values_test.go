@@ -0,0 +1,63 @@
+package values
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Merge", func() {
+	It("deep-merges maps and lets later layers win", func() {
+		merged, err := Merge([]Layer{
+			{Source: "ConfigMap base", Data: []byte("image:\n  tag: 1.0.0\n  pullPolicy: IfNotPresent\nreplicas: 1\nhosts: [a, b]\n")},
+			{Source: "Secret creds", Data: []byte("vault:\n  token: s3cr3t\n")},
+			{Source: "inline", Data: []byte("image:\n  tag: 1.1.0\nreplicas: 3\nhosts: [c]\n")},
+		})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(merged).To(Equal(map[string]interface{}{
+			"image":    map[string]interface{}{"tag": "1.1.0", "pullPolicy": "IfNotPresent"},
+			"replicas": 3,
+			"hosts":    []interface{}{"c"},
+			"vault":    map[string]interface{}{"token": "s3cr3t"},
+		}))
+	})
+
+	It("replaces a map by a scalar and skips empty layers", func() {
+		merged, err := Merge([]Layer{
+			{Source: "a", Data: []byte("resources:\n  limits:\n    cpu: 1\n")},
+			{Source: "empty", Data: []byte("")},
+			{Source: "b", Data: []byte("resources: null\n")},
+		})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(merged).To(HaveKeyWithValue("resources", BeNil()))
+	})
+
+	It("names the layer of invalid values", func() {
+		_, err := Merge([]Layer{{Source: "Git file values.yaml", Data: []byte("- a list\n")}})
+		Expect(err).To(MatchError("invalid values in Git file values.yaml: expected a mapping"))
+		_, err = Merge([]Layer{{Source: "inline", Data: []byte("a: [")}})
+		Expect(err).To(MatchError(ContainSubstring("invalid values in inline")))
+	})
+})
+
+var _ = Describe("Marshal and Hash", func() {
+	It("gives equal documents and hashes for equal values", func() {
+		a, err := Merge([]Layer{{Source: "a", Data: []byte("b: 2\na: 1\n")}})
+		Expect(err).NotTo(HaveOccurred())
+		b, err := Merge([]Layer{{Source: "b", Data: []byte("a: 1\nb: 2\n")}})
+		Expect(err).NotTo(HaveOccurred())
+
+		docA, err := Marshal(a)
+		Expect(err).NotTo(HaveOccurred())
+		docB, err := Marshal(b)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(docA)).To(Equal("a: 1\nb: 2\n"))
+		Expect(Hash(docA)).To(Equal(Hash(docB)))
+		Expect(Hash(docA)).To(HavePrefix("sha256:"))
+	})
+
+	It("marshals no values as an empty document", func() {
+		doc, err := Marshal(map[string]interface{}{})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(doc).To(BeEmpty())
+	})
+})
@@ -0,0 +1,14 @@
+package versions
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func TestVersions(t *testing.T) {
+	RegisterFailHandler(Fail)
+
+	RunSpecs(t, "Versions Suite")
+}
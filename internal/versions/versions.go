@@ -0,0 +1,91 @@
+package versions
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/Masterminds/semver/v3"
+)
+
+// NoMatchError is returned by Latest when no version satisfies the constraint
+type NoMatchError struct {
+	Constraint string
+}
+
+func (e *NoMatchError) Error() string {
+	return fmt.Sprintf("no version matches %q", e.Constraint)
+}
+
+// IsConstraint reports whether version is a semver range such as "~2.4", ">=2.3 <3" or "2.4.x" rather than an
+// exact version. Versions without range operators or wildcards are exact, even if they are not semver.
+func IsConstraint(version string) bool {
+	if strings.ContainsAny(version, "<>=!~^*|, ") {
+		return true
+	}
+	for _, part := range strings.Split(version, ".") {
+		if part == "x" || part == "X" {
+			return true
+		}
+	}
+	return false
+}
+
+// Validate checks that a constraint can be parsed
+func Validate(constraint string) error {
+	if _, err := semver.NewConstraint(constraint); err != nil {
+		return fmt.Errorf("invalid version constraint %q: %v", constraint, err)
+	}
+	return nil
+}
+
+// Latest returns the highest of versions that satisfies constraint. Versions that are not semver are ignored,
+// and so are pre-releases unless the constraint names one. Helm stores the "+" of chart versions as "_" in
+// OCI tags, so "_" is read as "+".
+func Latest(constraint string, versions []string) (string, error) {
+	c, err := semver.NewConstraint(constraint)
+	if err != nil {
+		return "", fmt.Errorf("invalid version constraint %q: %v", constraint, err)
+	}
+
+	var latest *semver.Version
+	match := ""
+	for _, version := range versions {
+		v, err := semver.NewVersion(strings.ReplaceAll(version, "_", "+"))
+		if err != nil || !c.Check(v) {
+			continue
+		}
+		if latest == nil || v.GreaterThan(latest) {
+			latest, match = v, version
+		}
+	}
+	if latest == nil {
+		return "", &NoMatchError{Constraint: constraint}
+	}
+	return match, nil
+}
+
+// Newer reports whether tag a is newer than tag b. Semver tags are ordered by precedence and are newer than
+// tags that are not semver, which are ordered lexically, e.g. date-stamped tags such as "build-20240601".
+func Newer(a, b string) bool {
+	va, errA := semver.NewVersion(a)
+	vb, errB := semver.NewVersion(b)
+	switch {
+	case errA == nil && errB == nil:
+		return va.GreaterThan(vb)
+	case errA == nil || errB == nil:
+		return errA == nil
+	default:
+		return a > b
+	}
+}
+
+// Newest returns the newest of tags as ordered by Newer, or "" when there are none
+func Newest(tags []string) string {
+	newest := ""
+	for i, tag := range tags {
+		if i == 0 || Newer(tag, newest) {
+			newest = tag
+		}
+	}
+	return newest
+}
@@ -0,0 +1,51 @@
+package versions
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("IsConstraint", func() {
+	It("tells ranges from exact versions", func() {
+		for _, constraint := range []string{"~2.4", ">=2.3 <3", "^2", "2.4.x", "2.*", ">=2.3, <3", "1.x || 2.x"} {
+			Expect(IsConstraint(constraint)).To(BeTrue(), constraint)
+		}
+		for _, exact := range []string{"2.4.1", "v2.4.1", "2.4", "2024.05-hotfix", "latest", ""} {
+			Expect(IsConstraint(exact)).To(BeFalse(), exact)
+		}
+	})
+})
+
+var _ = Describe("Latest", func() {
+	tags := []string{"2.3.9", "2.4.0", "2.4.3", "v2.4.10", "2.5.0-rc.1", "2.5.0", "3.0.0", "latest", "2.4.11_build.7"}
+
+	It("returns the highest version matching the constraint", func() {
+		Expect(Latest("~2.4", tags)).To(Equal("2.4.11_build.7"))
+		Expect(Latest(">=2.3 <2.5", []string{"2.3.9", "2.4.3", "v2.4.10", "2.5.0"})).To(Equal("v2.4.10"))
+		Expect(Latest(">=2.3 <3", tags)).To(Equal("2.5.0"))
+		Expect(Latest("^3", tags)).To(Equal("3.0.0"))
+	})
+
+	It("ignores pre-releases unless the constraint names one", func() {
+		Expect(Latest(">=2.5.0-0", []string{"2.4.3", "2.5.0-rc.1"})).To(Equal("2.5.0-rc.1"))
+		Expect(Latest("~2.5", []string{"2.5.0-rc.1"})).Error().To(MatchError(&NoMatchError{Constraint: "~2.5"}))
+	})
+
+	It("fails on invalid constraints and when nothing matches", func() {
+		Expect(Validate(">=2.3 <3")).To(Succeed())
+		Expect(Validate("~two")).To(MatchError(ContainSubstring(`invalid version constraint "~two"`)))
+		Expect(Latest("^4", tags)).Error().To(MatchError(`no version matches "^4"`))
+	})
+})
+
+var _ = Describe("Newest", func() {
+	It("orders semver tags by precedence above other tags", func() {
+		Expect(Newest([]string{"2.4.9", "2.4.10", "v2.4.2", "2.5.0-rc.1"})).To(Equal("2.5.0-rc.1"))
+		Expect(Newest([]string{"2024.06.01-2", "2024.06.01-10", "2024.05.30-1"})).To(Equal("2024.06.01-10"))
+		Expect(Newest([]string{"build-20240530", "build-20240601", "build-20240415"})).To(Equal("build-20240601"))
+		Expect(Newest([]string{"nightly", "1.0.0"})).To(Equal("1.0.0"))
+		Expect(Newest(nil)).To(BeEmpty())
+		Expect(Newer("2.4.10", "2.4.9")).To(BeTrue())
+		Expect(Newer("2.4.9", "2.4.9")).To(BeFalse())
+	})
+})